@@ -19,7 +19,9 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/libopenstorage/sdk-test/pkg/sanity"
 	yaml "gopkg.in/yaml.v2"
@@ -29,56 +31,133 @@ const (
 	prefix string = "sdk."
 )
 
+const (
+	defaultIssuer = "openstorage.io"
+)
+
 var (
 	VERSION                 = "(dev)"
-	endpoint                string
-	mountpath               string
 	version                 bool
+	configPath              string
 	cloudProviderConfigPath string
-	sharedSecret            string
-	issuer                  string
 )
 
 func init() {
-	flag.StringVar(&endpoint, prefix+"endpoint", "", "OpenStorage SDK endpoint")
-	flag.StringVar(&mountpath, prefix+"mountpath", "", "Mount path for volumes")
+	flag.StringVar(&configPath, prefix+"config", "", "YAML file with the configuration, optional. Flags and SDK_* environment variables override its values")
+	flag.String(prefix+"endpoint", "", "OpenStorage SDK endpoint")
+	flag.String(prefix+"mountpath", "", "Mount path for volumes")
 	flag.BoolVar(&version, prefix+"version", false, "Version of this program")
 	flag.StringVar(&cloudProviderConfigPath, prefix+"cpg", "", "Cloud Provider config file , optional")
-	flag.StringVar(&sharedSecret, prefix+"sharedsecret", "", "Shared secret for auth, ownership, and role testing")
-	flag.StringVar(&issuer, prefix+"issuer", "openstorage.io", "Issuer of token")
+	flag.String(prefix+"sharedsecret", "", "Shared secret for auth, ownership, and role testing. Prefer the SDK_SHAREDSECRET environment variable")
+	flag.String(prefix+"issuer", defaultIssuer, "Issuer of token")
+	flag.String(prefix+"junit-report", "", "File to save a JUnit XML report of the results, optional")
+	flag.String(prefix+"json-report", "", "File to save a JSON report of the results, optional")
+	flag.String(prefix+"services", "", "Comma separated list of services to test, like volume,snapshot. Default is all")
+	flag.String(prefix+"exclude-tags", "", "Comma separated list of tags to skip, like Buggy")
+	flag.Bool(prefix+"list", false, "List the selected tests, their tags, and the capability they need without running them")
+	flag.String(prefix+"run-id", "", "ID used in the names of the resources created by the tests. Default is a random ID")
+	flag.Bool(prefix+"cleanup-only", false, "Delete the resources left behind by previous runs with --sdk.run-id, which is required, instead of running the tests")
+	flag.String(prefix+"transport", "grpc", "Transport used by the tests, grpc or rest to test the REST gateway")
+	flag.String(prefix+"gateway", "", "Address of the REST gateway, like 127.0.0.1:9110, needed by --sdk.transport=rest")
+	flag.String(prefix+"error-code-report", "", "File to save the calls of the error code tests which returned unexpected codes, and the RPCs they do not cover, as JSON, optional")
+	flag.Bool(prefix+"fuzz", false, "Send random requests to every RPC instead of running the tests, saving the ones which crash or hang the server")
+	flag.Int(prefix+"fuzz-iterations", 100, "Number of random requests sent to each RPC in fuzz mode")
+	flag.Int64(prefix+"fuzz-seed", 0, "Seed of the random requests in fuzz mode, to repeat a previous run. Default is random")
+	flag.Duration(prefix+"fuzz-timeout", 30*time.Second, "Time after which a request is reported as hung in fuzz mode")
+	flag.String(prefix+"fuzz-dir", "fuzz-findings", "Directory where the requests found in fuzz mode are saved as JSON")
+	flag.Bool(prefix+"fuzz-destructive", false, "Also fuzz the RPCs which delete or move data, like volume delete. Only use on a cluster dedicated to the tests")
+	flag.Float64(prefix+"coverage-threshold", 0, "Fail when a lower percentage of the RPCs of the SDK was called successfully by the tests, optional")
+	flag.String(prefix+"traffic-dir", "", "Directory to save the gRPC calls of each test as JSON, optional. The calls of failed tests are always printed")
+	flag.String(prefix+"record", "", "Directory to save every call of the run as a fixture for --sdk.replay, optional")
+	flag.String(prefix+"replay", "", "Serve the calls recorded in the directory on --sdk.endpoint instead of running the tests")
+	flag.Bool(prefix+"fault-proxy", false, "Send the calls through a local proxy injecting network faults, needed by the NetworkFaults tests")
+	flag.String(prefix+"faults", "", "Network faults injected by the proxy during the whole run, like latency=100ms,bandwidth=65536,reset-every=1m. Enables the proxy")
+	flag.Bool(prefix+"bench", false, "Run benchmark workloads instead of the tests, reporting the throughput and latency percentiles of each RPC")
+	flag.String(prefix+"bench-workloads", "", "Comma separated list of benchmark workloads to run: volume-lifecycle, snapshot-storm, attach-detach, enumerate. Default is all")
+	flag.Int(prefix+"bench-concurrency", 8, "Number of concurrent workers of each benchmark workload")
+	flag.Duration(prefix+"bench-duration", time.Minute, "Duration of each benchmark workload")
+	flag.Int(prefix+"bench-volumes", 100, "Number of volumes enumerated by the enumerate benchmark workload")
+	flag.String(prefix+"bench-report", "", "File where the benchmark results are saved as JSON")
+	flag.Bool(prefix+"soak", false, "Repeat the lifecycle of volumes instead of running the tests, failing if leaked resources or error rates grow over time")
+	flag.Duration(prefix+"soak-duration", 2*time.Hour, "Duration of the soak")
+	flag.Duration(prefix+"soak-window", 10*time.Minute, "Duration of the windows over which the soak measures leaks and error rates")
+	flag.Float64(prefix+"soak-error-rate-increase", 5, "Percentage points by which the error rate may grow during the soak")
+	flag.String(prefix+"soak-report", "", "File where the windows of the soak are saved as JSON")
+	flag.Duration(prefix+"token-lifetime", time.Hour, "Lifetime of the tokens refreshed during the soak")
+	flag.String(prefix+"phase", "", "Upgrade phase to run instead of the tests: pre-upgrade creates resources and saves them to the state file, post-upgrade checks them and deletes them")
+	flag.String(prefix+"state-file", "sdk-test-upgrade-state.json", "File where the pre-upgrade phase saves the resources checked by the post-upgrade phase")
+	flag.Int(prefix+"max-recv-msg-size", 4*1024*1024, "Maximum size in bytes of the responses received from the server")
+	flag.Int(prefix+"scale-volumes", 0, "Number of volumes created by the Scale tests, which are skipped if zero")
+	flag.Int(prefix+"scale-snapshots", 0, "Number of snapshots created by the Scale tests, which are skipped if zero")
+	flag.Duration(prefix+"backup-schedule-interval", 0, "Period of the cloud backup schedules whose retention is checked, at least one second, which are skipped if zero")
+	flag.String(prefix+"backup-type-key", "", "Key in the metadata of cloud backups whose value is full for full backups")
 	flag.Parse()
 }
 
 func TestSanity(t *testing.T) {
 
-	var cfg *sanity.CloudProviderConfig
-	var err error
-
 	if version {
 		fmt.Printf("Version = %s\n", VERSION)
 		return
 	}
-	if len(endpoint) == 0 {
-		t.Fatalf("--%s.endpoint must be provided with an OpenStorage SDK endpoint", prefix)
-	}
 
-	if len(cloudProviderConfigPath) == 0 {
+	c, err := loadConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Address) == 0 && !c.ListSpecs {
+		t.Fatalf("--%sendpoint must be provided with an OpenStorage SDK endpoint", prefix)
+	}
+	if c.ProviderConfig == nil {
 		t.Logf("No Cloud provider config file provided , Cloud related Tests will be skipped")
 	}
 
-	if len(cloudProviderConfigPath) != 0 {
-		cfg, err = cloudProviderConfigParse(cloudProviderConfigPath)
-		if err != nil {
-			t.Logf("Error parsing cloud provider Config , skipping cloud related tests")
+	sanity.Test(t, c)
+}
+
+// loadConfiguration reads the configuration file if one was given, then
+// overrides it with the SDK_* environment variables, and finally with the
+// flags set in the command line
+func loadConfiguration() (*sanity.SanityConfiguration, error) {
+	c := &sanity.SanityConfiguration{}
+	if len(configPath) != 0 {
+		var err error
+		if c, err = sanity.LoadConfiguration(configPath); err != nil {
+			return nil, err
 		}
 	}
-	sanity.Test(t, &sanity.SanityConfiguration{
-		Address:        endpoint,
-		MountPath:      mountpath,
-		SharedSecret:   sharedSecret,
-		Issuer:         issuer,
-		ProviderConfig: cfg,
+
+	if err := c.LoadEnvironment(); err != nil {
+		return nil, err
+	}
+
+	options := make(map[string]bool)
+	for _, key := range sanity.OptionNames() {
+		options[key] = true
+	}
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		key := strings.TrimPrefix(f.Name, prefix)
+		if err != nil || !strings.HasPrefix(f.Name, prefix) || !options[key] {
+			return
+		}
+		if err = c.SetOption(key, f.Value.String()); err != nil {
+			err = fmt.Errorf("Invalid flag --%s: %v", f.Name, err)
+		}
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cloudProviderConfigPath) != 0 {
+		if c.ProviderConfig, err = cloudProviderConfigParse(cloudProviderConfigPath); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.Issuer) == 0 {
+		c.Issuer = defaultIssuer
+	}
+	return c, nil
 }
 
 // cloudProviderConfigParse parses the config file of cloud provider
@@ -19,5 +19,10 @@ echo ">>> VERSION"
 timeout 30 sh -c 'until curl --silent -X GET -d {} http://localhost:8181/v1/identities/version; do sleep 1; done'
 echo ""
 ./cmd/sdk-test/sdk-test --sdk.endpoint=127.0.0.1:${PORT} --sdk.cpg=./cmd/sdk-test/cb.yaml ; ret=$?
+if [ $ret -eq 0 ] ; then
+	echo ">>> REST GATEWAY"
+	./cmd/sdk-test/sdk-test --sdk.endpoint=127.0.0.1:${PORT} --sdk.cpg=./cmd/sdk-test/cb.yaml \
+		--sdk.transport=rest --sdk.gateway=127.0.0.1:${GWPORT} ; ret=$?
+fi
 docker stop ${MOCKSDK} > /dev/null 2>&1
 exit $ret
@@ -0,0 +1,440 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	benchTag = "[Bench]"
+
+	defaultBenchConcurrency = 8
+	defaultBenchDuration    = time.Minute
+	defaultBenchVolumes     = 100
+
+	// benchLabel labels the volumes enumerated by the enumerate workload
+	benchLabel = "sdk-test-bench"
+)
+
+// benchWorkloads are the workloads of the benchmark mode, in the order they
+// run
+var benchWorkloads = []string{
+	"volume-lifecycle",
+	"snapshot-storm",
+	"attach-detach",
+	"enumerate",
+}
+
+// benchBuckets are the upper bounds of the latency histograms
+var benchBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// BenchBucket counts the calls with a latency up to UpperMs milliseconds, and
+// above the bound of the previous bucket. The last bucket has no bound.
+type BenchBucket struct {
+	UpperMs float64 `json:"upper_ms,omitempty"`
+	Count   int     `json:"count"`
+}
+
+// BenchRPCResult is the throughput and latency of an RPC in a workload.
+// Latencies are in milliseconds.
+type BenchRPCResult struct {
+	Method     string        `json:"method"`
+	Calls      int           `json:"calls"`
+	Errors     int           `json:"errors"`
+	Throughput float64       `json:"calls_per_second"`
+	P50        float64       `json:"p50_ms"`
+	P95        float64       `json:"p95_ms"`
+	P99        float64       `json:"p99_ms"`
+	Max        float64       `json:"max_ms"`
+	Histogram  []BenchBucket `json:"histogram"`
+}
+
+// BenchResult is the outcome of a workload of the benchmark mode
+type BenchResult struct {
+	Workload    string           `json:"workload"`
+	Concurrency int              `json:"concurrency"`
+	Seconds     float64          `json:"seconds"`
+	RPCs        []BenchRPCResult `json:"rpcs"`
+}
+
+// benchRecorder measures the latency of the calls made while a workload is
+// running
+type benchRecorder struct {
+	lock sync.Mutex
+	// workload is the name of the running workload, empty when none is
+	workload  string
+	start     time.Time
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	results   []BenchResult
+}
+
+func newBenchRecorder() *benchRecorder {
+	return &benchRecorder{}
+}
+
+// startWorkload records the calls of a workload
+func (b *benchRecorder) startWorkload(workload string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.workload = workload
+	b.start = time.Now()
+	b.latencies = make(map[string][]time.Duration)
+	b.errors = make(map[string]int)
+}
+
+// stopWorkload stops recording and computes the results of the workload
+func (b *benchRecorder) stopWorkload(concurrency int) BenchResult {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	elapsed := time.Since(b.start)
+	result := BenchResult{
+		Workload:    b.workload,
+		Concurrency: concurrency,
+		Seconds:     elapsed.Seconds(),
+		RPCs:        []BenchRPCResult{},
+	}
+	methods := make([]string, 0, len(b.latencies))
+	for method := range b.latencies {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		result.RPCs = append(result.RPCs, benchRPCResult(method, b.latencies[method], b.errors[method], elapsed))
+	}
+	b.results = append(b.results, result)
+	b.workload = ""
+	return result
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// percentile returns the latency below which are p percent of the sorted
+// latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func benchRPCResult(method string, latencies []time.Duration, errors int, elapsed time.Duration) BenchRPCResult {
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	r := BenchRPCResult{
+		Method:     method,
+		Calls:      len(sorted),
+		Errors:     errors,
+		Throughput: float64(len(sorted)) / elapsed.Seconds(),
+		P50:        milliseconds(percentile(sorted, 50)),
+		P95:        milliseconds(percentile(sorted, 95)),
+		P99:        milliseconds(percentile(sorted, 99)),
+		Max:        milliseconds(sorted[len(sorted)-1]),
+	}
+	i := 0
+	for _, upper := range benchBuckets {
+		bucket := BenchBucket{UpperMs: milliseconds(upper)}
+		for ; i < len(sorted) && sorted[i] <= upper; i++ {
+			bucket.Count++
+		}
+		r.Histogram = append(r.Histogram, bucket)
+	}
+	r.Histogram = append(r.Histogram, BenchBucket{Count: len(sorted) - i})
+	return r
+}
+
+func (b *benchRecorder) unaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	latency := time.Since(start)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.workload) != 0 {
+		// The method is like /openstorage.api.OpenStorageVolume/Create
+		name := method[strings.LastIndex(method[:strings.LastIndex(method, "/")], ".")+1:]
+		b.latencies[name] = append(b.latencies[name], latency)
+		if err != nil {
+			b.errors[name]++
+		}
+	}
+	return err
+}
+
+// report prints the results of the workloads, and saves them as JSON in the
+// file if it is set
+func (b *benchRecorder) report(w io.Writer, filename string) error {
+	b.lock.Lock()
+	results := append([]BenchResult{}, b.results...)
+	b.lock.Unlock()
+
+	if len(results) == 0 {
+		return nil
+	}
+	for _, result := range results {
+		fmt.Fprintf(w, "\nWorkload %s: %d workers for %.1fs\n", result.Workload, result.Concurrency, result.Seconds)
+		fmt.Fprintf(w, "  %-40s %8s %7s %9s %9s %9s %9s %9s\n",
+			"RPC", "calls", "errors", "calls/s", "p50 ms", "p95 ms", "p99 ms", "max ms")
+		for _, r := range result.RPCs {
+			fmt.Fprintf(w, "  %-40s %8d %7d %9.1f %9.2f %9.2f %9.2f %9.2f\n",
+				r.Method, r.Calls, r.Errors, r.Throughput, r.P50, r.P95, r.P99, r.Max)
+		}
+	}
+
+	if len(filename) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(reportFilename(filename), data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to write benchmark report %s: %v", filename, err)
+	}
+	return nil
+}
+
+// benchSettings returns the concurrency and duration of the workloads
+func benchSettings(c *SanityConfiguration) (int, time.Duration) {
+	concurrency, duration := c.BenchConcurrency, c.BenchDuration
+	if concurrency <= 0 {
+		concurrency = defaultBenchConcurrency
+	}
+	if duration <= 0 {
+		duration = defaultBenchDuration
+	}
+	return concurrency, duration
+}
+
+// checkBenchWorkloads returns an error for unknown workloads
+func checkBenchWorkloads(workloads []string) error {
+	for _, name := range workloads {
+		found := false
+		for _, workload := range benchWorkloads {
+			found = found || strings.TrimSpace(name) == workload
+		}
+		if !found {
+			return fmt.Errorf("Unknown benchmark workload %q, must be one of: %s",
+				name, strings.Join(benchWorkloads, ", "))
+		}
+	}
+	return nil
+}
+
+// benchWorkloadSelected returns true if the workload should run
+func benchWorkloadSelected(c *SanityConfiguration, workload string) bool {
+	if len(c.BenchWorkloads) == 0 {
+		return true
+	}
+	for _, name := range c.BenchWorkloads {
+		if strings.TrimSpace(name) == workload {
+			return true
+		}
+	}
+	return false
+}
+
+// runBenchWorkload calls f in a loop from each worker until the duration of
+// the benchmark elapsed, and returns the results of the workload
+func runBenchWorkload(workload string, f func(ctx context.Context, worker int)) BenchResult {
+	concurrency, duration := benchSettings(run.config)
+	ctx := setContextWithToken(context.Background(), run.users["admin"])
+
+	run.bench.startWorkload(workload)
+	end := time.Now().Add(duration)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer GinkgoRecover()
+			defer wg.Done()
+			for time.Now().Before(end) && run.ctx.Err() == nil {
+				f(ctx, worker)
+			}
+		}(i)
+	}
+	wg.Wait()
+	result := run.bench.stopWorkload(concurrency)
+
+	calls, errors := 0, 0
+	for _, r := range result.RPCs {
+		calls += r.Calls
+		errors += r.Errors
+	}
+	Expect(calls).NotTo(BeZero(), "The workload made no calls")
+	Expect(errors).To(BeNumerically("<", calls), "Every call of the workload failed")
+	return result
+}
+
+var _ = Describe("Benchmark "+benchTag, func() {
+	var (
+		vc     api.OpenStorageVolumeClient
+		ma     api.OpenStorageMountAttachClient
+		volIDs []string
+	)
+
+	BeforeEach(func() {
+		skipUnsupportedService("OpenStorageVolume")
+		vc = api.NewOpenStorageVolumeClient(run.conn)
+		ma = api.NewOpenStorageMountAttachClient(run.conn)
+		volIDs = nil
+	})
+
+	AfterEach(func() {
+		ctx := setContextWithToken(context.Background(), run.users["admin"])
+		for _, id := range volIDs {
+			Expect(deleteVol(ctx, vc, id)).NotTo(HaveOccurred())
+		}
+	})
+
+	selectWorkload := func(workload string) {
+		if !benchWorkloadSelected(run.config, workload) {
+			Skip("Workload " + workload + " not selected")
+		}
+	}
+
+	It("should measure volume creates and deletes [OpenStorageVolume]", func() {
+		selectWorkload("volume-lifecycle")
+
+		runBenchWorkload("volume-lifecycle", func(ctx context.Context, worker int) {
+			resp, err := vc.Create(ctx, &api.SdkVolumeCreateRequest{
+				Name: genName("vol"),
+				Spec: &api.VolumeSpec{
+					Size:    uint64(GIGABYTE),
+					HaLevel: 1,
+					Format:  api.FSType_FS_TYPE_EXT4,
+				},
+			})
+			if err != nil {
+				return
+			}
+			vc.Inspect(ctx, &api.SdkVolumeInspectRequest{VolumeId: resp.GetVolumeId()})
+			vc.Delete(ctx, &api.SdkVolumeDeleteRequest{VolumeId: resp.GetVolumeId()})
+		})
+	})
+
+	It("should measure snapshot storms on a volume [OpenStorageVolume]", func() {
+		selectWorkload("snapshot-storm")
+		volID := newTestVolume(vc)
+		volIDs = append(volIDs, volID)
+
+		runBenchWorkload("snapshot-storm", func(ctx context.Context, worker int) {
+			resp, err := vc.SnapshotCreate(ctx, &api.SdkVolumeSnapshotCreateRequest{
+				VolumeId: volID,
+				Name:     genName("snap"),
+			})
+			if err != nil {
+				return
+			}
+			vc.Delete(ctx, &api.SdkVolumeDeleteRequest{VolumeId: resp.GetSnapshotId()})
+		})
+	})
+
+	It("should measure attach and detach churn [OpenStorageVolume]", func() {
+		selectWorkload("attach-detach")
+		concurrency, _ := benchSettings(run.config)
+		workerVols := make([]string, concurrency)
+		for i := range workerVols {
+			workerVols[i] = newTestVolume(vc)
+			volIDs = append(volIDs, workerVols[i])
+		}
+
+		runBenchWorkload("attach-detach", func(ctx context.Context, worker int) {
+			if _, err := ma.Attach(ctx, &api.SdkVolumeAttachRequest{VolumeId: workerVols[worker]}); err != nil {
+				return
+			}
+			ma.Detach(ctx, &api.SdkVolumeDetachRequest{
+				VolumeId: workerVols[worker],
+				Options: &api.SdkVolumeDetachOptions{
+					UnmountBeforeDetach: true,
+				},
+			})
+		})
+	})
+
+	It("should measure enumerating many volumes [OpenStorageVolume]", func() {
+		selectWorkload("enumerate")
+		count := run.config.BenchVolumes
+		if count <= 0 {
+			count = defaultBenchVolumes
+		}
+
+		By(fmt.Sprintf("creating %d volumes", count))
+		ctx := setContextWithToken(context.Background(), run.users["admin"])
+		labels := map[string]string{benchLabel: run.id}
+		for i := 0; i < count; i++ {
+			resp, err := vc.Create(ctx, &api.SdkVolumeCreateRequest{
+				Name:   genName("vol"),
+				Labels: labels,
+				Spec: &api.VolumeSpec{
+					Size:    uint64(GIGABYTE),
+					HaLevel: 1,
+					Format:  api.FSType_FS_TYPE_EXT4,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			volIDs = append(volIDs, resp.GetVolumeId())
+		}
+
+		runBenchWorkload("enumerate", func(ctx context.Context, worker int) {
+			vc.Enumerate(ctx, &api.SdkVolumeEnumerateRequest{})
+			vc.EnumerateWithFilters(ctx, &api.SdkVolumeEnumerateWithFiltersRequest{Labels: labels})
+		})
+	})
+})
@@ -18,6 +18,7 @@ package sanity
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
@@ -35,7 +36,7 @@ func getBackupId(bc api.OpenStorageCloudBackupClient, clusterId, volumeId, crede
 		SrcVolumeId:  volumeId,
 		CredentialId: credentialId,
 	}
-	enumerateResp, err := bc.EnumerateWithFilters(setContextWithToken(context.Background(), users["admin"]), enumerateReq)
+	enumerateResp, err := bc.EnumerateWithFilters(setContextWithToken(context.Background(), run.users["admin"]), enumerateReq)
 	Expect(err).NotTo(HaveOccurred())
 	Expect(enumerateResp).NotTo(BeNil())
 	Expect(enumerateResp.GetBackups()).NotTo(BeEmpty())
@@ -52,7 +53,132 @@ func getBackupId(bc api.OpenStorageCloudBackupClient, clusterId, volumeId, crede
 	return backupId
 }
 
-var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
+// getVolumeBackupId returns the id of a backup of the volume in the current
+// cluster
+func getVolumeBackupId(bc api.OpenStorageCloudBackupClient, volumeId, credentialId string) string {
+	cluster, err := api.NewOpenStorageClusterClient(run.conn).InspectCurrent(
+		setContextWithToken(context.Background(), run.users["admin"]),
+		&api.SdkClusterInspectCurrentRequest{},
+	)
+	Expect(err).NotTo(HaveOccurred())
+	return getBackupId(bc, cluster.GetCluster().GetId(), volumeId, credentialId)
+}
+
+// getPairedClusterId returns the cluster to migrate volumes to, which is the
+// default cluster pair, and skips the test when there is no cluster pair
+func getPairedClusterId() string {
+	pairs, err := api.NewOpenStorageClusterPairClient(run.conn).Enumerate(
+		setContextWithToken(context.Background(), run.users["admin"]),
+		&api.SdkClusterPairEnumerateRequest{},
+	)
+	Expect(err).NotTo(HaveOccurred())
+	clusterId := pairs.GetResult().GetDefaultId()
+	if clusterId == "" {
+		for id := range pairs.GetResult().GetPairs() {
+			clusterId = id
+			break
+		}
+	}
+	if clusterId == "" {
+		Skip("Migrations need a cluster pair")
+	}
+	return clusterId
+}
+
+// skipUnlessCloudBackup skips the tests of cloud backups when the server
+// does not support them or there is no cloud provider configuration
+func skipUnlessCloudBackup(ic api.OpenStorageIdentityClient) {
+	if !isCapabilitySupported(ic, api.SdkServiceCapability_OpenStorageService_CLOUD_BACKUP) {
+		Skip("Cloud Backup capability not supported , skipping related tests")
+	}
+	if run.config.ProviderConfig == nil {
+		Skip("Skipping cloud backup tests")
+	}
+}
+
+// waitForCloudBackupTask waits until the cloud backup or restore task is
+// done, and returns an error if it failed. newContext returns the context of
+// each status call. The errors of the calls for which retry returns true are
+// retried, and retry may be nil to never retry.
+func waitForCloudBackupTask(
+	bc api.OpenStorageCloudBackupClient,
+	taskID string,
+	timeout time.Duration,
+	newContext func() (context.Context, context.CancelFunc),
+	retry func(error) (bool, error),
+) error {
+	return waitFor(timeout, 5*time.Second, func() (bool, error) {
+		ctx, cancel := newContext()
+		defer cancel()
+		resp, err := bc.Status(ctx, &api.SdkCloudBackupStatusRequest{TaskId: taskID})
+		if err != nil {
+			if retry == nil {
+				return false, err
+			}
+			return retry(err)
+		}
+		s, ok := resp.GetStatuses()[taskID]
+		if !ok {
+			return false, fmt.Errorf("Task %s is unknown", taskID)
+		}
+		switch s.GetStatus() {
+		case api.SdkCloudBackupStatusType_SdkCloudBackupStatusTypeDone:
+			return false, nil
+		case api.SdkCloudBackupStatusType_SdkCloudBackupStatusTypeFailed,
+			api.SdkCloudBackupStatusType_SdkCloudBackupStatusTypeAborted:
+			return false, fmt.Errorf("Task %s ended with status %s", taskID, s.GetStatus())
+		}
+		return true, nil
+	})
+}
+
+// backupVolume is an attached volume and the credentials of the cloud
+// providers of the configuration, to back it up
+type backupVolume struct {
+	volID string
+	// credIDs maps the providers to their credential
+	credIDs map[string]string
+}
+
+func newBackupVolume(vc api.OpenStorageVolumeClient) *backupVolume {
+	b := &backupVolume{
+		volID: newTestVolume(vc),
+	}
+	_, err := api.NewOpenStorageMountAttachClient(run.conn).Attach(
+		setContextWithToken(context.Background(), run.users["admin"]),
+		&api.SdkVolumeAttachRequest{VolumeId: b.volID},
+	)
+	Expect(err).NotTo(HaveOccurred())
+	b.credIDs = parseAndCreateCredentials2(api.NewOpenStorageCredentialsClient(run.conn))
+	return b
+}
+
+// delete deletes the backups of the volume, the credentials and the volume
+func (b *backupVolume) delete(vc api.OpenStorageVolumeClient) {
+	ctx := setContextWithToken(context.Background(), run.users["admin"])
+	bc := api.NewOpenStorageCloudBackupClient(run.conn)
+	cc := api.NewOpenStorageCredentialsClient(run.conn)
+	for _, credID := range b.credIDs {
+		_, err := bc.DeleteAll(ctx, &api.SdkCloudBackupDeleteAllRequest{
+			SrcVolumeId:  b.volID,
+			CredentialId: credID,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = cc.Delete(ctx, &api.SdkCredentialDeleteRequest{CredentialId: credID})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	_, err := api.NewOpenStorageMountAttachClient(run.conn).Detach(ctx, &api.SdkVolumeDetachRequest{
+		VolumeId: b.volID,
+		Options: &api.SdkVolumeDetachOptions{
+			UnmountBeforeDetach: true,
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(deleteVol(ctx, vc, b.volID)).NotTo(HaveOccurred())
+}
+
+var _ = Describe("Cloud backup [OpenStorageCloudBackup]", func() {
 	var (
 		cc api.OpenStorageCredentialsClient
 		vc api.OpenStorageVolumeClient
@@ -73,13 +199,13 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 	BeforeEach(func() {
 
-		cc = api.NewOpenStorageCredentialsClient(conn)
-		bc = api.NewOpenStorageCloudBackupClient(conn)
-		vc = api.NewOpenStorageVolumeClient(conn)
-		c = api.NewOpenStorageClusterClient(conn)
-		nc = api.NewOpenStorageNodeClient(conn)
-		ic = api.NewOpenStorageIdentityClient(conn)
-		ma = api.NewOpenStorageMountAttachClient(conn)
+		cc = api.NewOpenStorageCredentialsClient(run.conn)
+		bc = api.NewOpenStorageCloudBackupClient(run.conn)
+		vc = api.NewOpenStorageVolumeClient(run.conn)
+		c = api.NewOpenStorageClusterClient(run.conn)
+		nc = api.NewOpenStorageNodeClient(run.conn)
+		ic = api.NewOpenStorageIdentityClient(run.conn)
+		ma = api.NewOpenStorageMountAttachClient(run.conn)
 
 		isSupported := isCapabilitySupported(
 			ic,
@@ -94,7 +220,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 		credID = ""
 		credsUUIDMap = make(map[string]string)
 
-		if config.ProviderConfig == nil {
+		if run.config.ProviderConfig == nil {
 			Skip("Skipping cloud backup tests")
 		}
 	})
@@ -107,7 +233,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				if volID != "" {
 
 					_, err := bc.DeleteAll(
-						setContextWithToken(context.Background(), users["admin"]),
+						setContextWithToken(context.Background(), run.users["admin"]),
 						&api.SdkCloudBackupDeleteAllRequest{
 							SrcVolumeId:  volID,
 							CredentialId: credID,
@@ -117,7 +243,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				}
 
 				_, err := cc.Delete(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCredentialDeleteRequest{
 						CredentialId: credID,
 					},
@@ -128,7 +254,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 		if volID != "" {
 			_, err := ma.Detach(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeDetachRequest{
 					VolumeId: volID,
 					Options: &api.SdkVolumeDetachOptions{
@@ -138,7 +264,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 			)
 			Expect(err).NotTo(HaveOccurred())
 			err = deleteVol(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				vc,
 				volID,
 			)
@@ -160,7 +286,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 				By("Attaching the created volume")
 				str, err := ma.Attach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeAttachRequest{
 						VolumeId: volID,
 					},
@@ -176,7 +302,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					Full:         false,
 				}
 
-				backup, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+				backup, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(backup).NotTo(BeNil())
 				Expect(backup.GetTaskId()).NotTo(BeEmpty())
@@ -188,7 +314,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					bkpStatusReq = &api.SdkCloudBackupStatusRequest{
 						VolumeId: volID,
 					}
-					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), users["admin"]), bkpStatusReq)
+					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), run.users["admin"]), bkpStatusReq)
 					Expect(err).To(BeNil())
 
 					bkpStatus = bkpStatusResp.Statuses[backup.TaskId]
@@ -223,7 +349,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					Full:         false,
 				}
 
-				_, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+				_, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 				Expect(err).To(HaveOccurred())
 
 				serverError, ok := status.FromError(err)
@@ -247,7 +373,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					CredentialId: credID,
 					Full:         false,
 				}
-				_, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+				_, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 				Expect(err).To(HaveOccurred())
 
 				serverError, ok := status.FromError(err)
@@ -268,7 +394,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				CredentialId: "cred-uuid-doesnt-exist",
 				Full:         false,
 			}
-			_, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+			_, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 			Expect(err).To(HaveOccurred())
 			serverError, ok := status.FromError(err)
 			Expect(ok).To(BeTrue())
@@ -287,7 +413,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				CredentialId: "",
 				Full:         false,
 			}
-			_, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+			_, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 			Expect(err).To(HaveOccurred())
 			serverError, ok := status.FromError(err)
 			Expect(ok).To(BeTrue())
@@ -304,7 +430,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 			By("Getting the cluster id of the cluster")
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 
@@ -319,7 +445,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 				By("Attaching the created volume")
 				str, err := ma.Attach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeAttachRequest{
 						VolumeId: volID,
 					},
@@ -335,7 +461,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					Full:         false,
 				}
 
-				backup, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+				backup, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(backup).NotTo(BeNil())
 				Expect(backup.GetTaskId()).NotTo(BeEmpty())
@@ -347,7 +473,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					bkpStatusReq = &api.SdkCloudBackupStatusRequest{
 						VolumeId: volID,
 					}
-					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), users["admin"]), bkpStatusReq)
+					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), run.users["admin"]), bkpStatusReq)
 					Expect(err).To(BeNil())
 
 					bkpStatus = bkpStatusResp.Statuses[backup.TaskId]
@@ -369,7 +495,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				By("Enumerating the cloud backups")
 
 				enumerateResp, err := bc.EnumerateWithFilters(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupEnumerateWithFiltersRequest{
 						ClusterId:    clusterID,
 						CredentialId: credID,
@@ -385,7 +511,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 			By("Getting the cluster id of the cluster")
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 
@@ -404,7 +530,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					CredentialId: credID,
 				}
 
-				resp, err := bc.EnumerateWithFilters(setContextWithToken(context.Background(), users["admin"]), enumerateReq)
+				resp, err := bc.EnumerateWithFilters(setContextWithToken(context.Background(), run.users["admin"]), enumerateReq)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(resp.GetBackups()).To(BeEmpty())
 			}
@@ -416,7 +542,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 		// 	By("Getting the cluster id of the cluster")
 		// 	inpectResp, err := c.InspectCurrent(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkClusterInspectCurrentRequest{},
 		// 	)
 
@@ -435,7 +561,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 		// 			CredentialId: credID,
 		// 		}
 
-		// 		enumerateResp, err := bc.EnumerateWithFilters(setContextWithToken(context.Background(), users["admin"]), enumerateReq)
+		// 		enumerateResp, err := bc.EnumerateWithFilters(setContextWithToken(context.Background(), run.users["admin"]), enumerateReq)
 		// 		Expect(err).To(HaveOccurred())
 		// 		Expect(enumerateResp).To(BeNil())
 
@@ -448,7 +574,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 		It("Should fail to enumerate back up if non-existent credentials is passed", func() {
 			By("Getting the cluster id of the cluster")
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 			Expect(err).NotTo(HaveOccurred())
@@ -463,7 +589,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				CredentialId: "dummy-credentials",
 			}
 
-			enumerateResp, err := bc.EnumerateWithFilters(setContextWithToken(context.Background(), users["admin"]), enumerateReq)
+			enumerateResp, err := bc.EnumerateWithFilters(setContextWithToken(context.Background(), run.users["admin"]), enumerateReq)
 			Expect(err).To(HaveOccurred())
 			Expect(enumerateResp).To(BeNil())
 
@@ -475,7 +601,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 		It("Should fail to enumerate back up if empty credentials is passed", func() {
 			By("Getting the cluster id of the cluster")
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 
@@ -492,7 +618,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				CredentialId: "",
 			}
 
-			enumerateResp, err := bc.EnumerateWithFilters(setContextWithToken(context.Background(), users["admin"]), enumerateReq)
+			enumerateResp, err := bc.EnumerateWithFilters(setContextWithToken(context.Background(), run.users["admin"]), enumerateReq)
 			Expect(err).To(HaveOccurred())
 			Expect(enumerateResp).To(BeNil())
 
@@ -509,7 +635,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 			By("Getting the cluster id of the cluster")
 
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 
@@ -527,7 +653,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 				By("attaching the created volume")
 				str, err := ma.Attach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeAttachRequest{
 						VolumeId: volID,
 					},
@@ -543,7 +669,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					Full:         false,
 				}
 
-				backup, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+				backup, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(backup).NotTo(BeNil())
 				Expect(backup.GetTaskId()).NotTo(BeEmpty())
@@ -555,7 +681,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					bkpStatusReq = &api.SdkCloudBackupStatusRequest{
 						VolumeId: volID,
 					}
-					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), users["admin"]), bkpStatusReq)
+					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), run.users["admin"]), bkpStatusReq)
 					Expect(err).To(BeNil())
 
 					bkpStatus = bkpStatusResp.Statuses[backup.TaskId]
@@ -574,7 +700,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 				By("checking the catalog")
 				catalogResp, err := bc.Catalog(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupCatalogRequest{
 						BackupId:     getBackupId(bc, clusterID, volID, credID),
 						CredentialId: credID,
@@ -590,7 +716,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 			By("Getting the cluster id of the cluster")
 
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 
@@ -604,7 +730,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				credID = uuid
 
 				catalogResp, err := bc.Catalog(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupCatalogRequest{
 						BackupId:     "dummy-backupid",
 						CredentialId: credID,
@@ -623,7 +749,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 			By("Getting the cluster id of the cluster")
 
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 
@@ -637,7 +763,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				credID = uuid
 
 				catalogResp, err := bc.Catalog(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupCatalogRequest{
 						BackupId:     "",
 						CredentialId: credID,
@@ -653,13 +779,13 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 		})
 	})
 
-	Describe("Cloudbackup History", func() {
+	Describe("Cloudbackup History"+sdkVersionTag("OpenStorageCloudBackup/History"), func() {
 		It("Should successfully list out the history", func() {
 
 			By("Getting the cluster id of the cluster")
 
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 
@@ -677,7 +803,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 				By("Attaching the created volume")
 				str, err := ma.Attach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeAttachRequest{
 						VolumeId: volID,
 					},
@@ -693,7 +819,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					Full:         false,
 				}
 
-				backup, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+				backup, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(backup).NotTo(BeNil())
 				Expect(backup.GetTaskId()).NotTo(BeEmpty())
@@ -705,7 +831,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					bkpStatusReq = &api.SdkCloudBackupStatusRequest{
 						VolumeId: volID,
 					}
-					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), users["admin"]), bkpStatusReq)
+					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), run.users["admin"]), bkpStatusReq)
 					Expect(err).To(BeNil())
 
 					bkpStatus = bkpStatusResp.Statuses[backup.TaskId]
@@ -730,7 +856,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 			time.Sleep(5 * time.Second)
 
 			historyResp, err := bc.History(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkCloudBackupHistoryRequest{
 					SrcVolumeId: volID,
 				},
@@ -745,7 +871,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 		// 	By("Getting cloud backup history of the created volume")
 		// 	_, err := bc.History(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkCloudBackupHistoryRequest{
 		// 			SrcVolumeId: volID,
 		// 		},
@@ -760,7 +886,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 			By("Getting cloud backup history of the created volume")
 			_, err := bc.History(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkCloudBackupHistoryRequest{
 					SrcVolumeId: volID,
 				},
@@ -778,7 +904,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 			By("Getting the cluster id of the cluster")
 
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 
@@ -788,7 +914,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 			By("Getting the node id")
 
 			nodeResp, err := nc.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkNodeInspectCurrentRequest{},
 			)
 			Expect(err).NotTo(HaveOccurred())
@@ -805,7 +931,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 				By("Attaching the created volume")
 				str, err := ma.Attach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeAttachRequest{
 						VolumeId: volID,
 					},
@@ -821,7 +947,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					Full:         false,
 				}
 
-				backup, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+				backup, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(backup).NotTo(BeNil())
 				Expect(backup.GetTaskId()).NotTo(BeEmpty())
@@ -833,7 +959,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					bkpStatusReq = &api.SdkCloudBackupStatusRequest{
 						VolumeId: volID,
 					}
-					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), users["admin"]), bkpStatusReq)
+					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), run.users["admin"]), bkpStatusReq)
 					Expect(err).To(BeNil())
 
 					bkpStatus = bkpStatusResp.Statuses[backup.TaskId]
@@ -854,7 +980,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 				By("Doing restore of the cloud backup")
 				restoreResp, err := bc.Restore(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupRestoreRequest{
 						BackupId:          getBackupId(bc, clusterID, volID, credID),
 						CredentialId:      credID,
@@ -869,7 +995,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				By("Inspecting the restored volume")
 
 				inspectResp, err := vc.Inspect(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeInspectRequest{
 						VolumeId: restoreResp.RestoreVolumeId,
 					},
@@ -888,7 +1014,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 			By("Getting the cluster id of the cluster")
 
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 
@@ -906,7 +1032,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 				By("Attaching the created volume")
 				str, err := ma.Attach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeAttachRequest{
 						VolumeId: volID,
 					},
@@ -922,7 +1048,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					Full:         false,
 				}
 
-				backup, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+				backup, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(backup).NotTo(BeNil())
 				Expect(backup.GetTaskId()).NotTo(BeEmpty())
@@ -934,7 +1060,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					bkpStatusReq = &api.SdkCloudBackupStatusRequest{
 						VolumeId: volID,
 					}
-					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), users["admin"]), bkpStatusReq)
+					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), run.users["admin"]), bkpStatusReq)
 					Expect(err).To(BeNil())
 
 					bkpStatus = bkpStatusResp.Statuses[backup.TaskId]
@@ -954,7 +1080,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				Expect(bkpStatus.Status).To(BeEquivalentTo(api.SdkCloudBackupStatusType_SdkCloudBackupStatusTypeDone))
 
 				_, err = bc.Delete(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupDeleteRequest{
 						BackupId:     getBackupId(bc, clusterID, volID, credID),
 						CredentialId: credID,
@@ -977,7 +1103,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 		// 		credID = uuid
 
 		// 		_, err := bc.Delete(
-		// 			setContextWithToken(context.Background(), users["admin"]),
+		// 			setContextWithToken(context.Background(), run.users["admin"]),
 		// 			&api.SdkCloudBackupDeleteRequest{
 		// 				BackupId:     "doesnt-exist",
 		// 				CredentialId: credID,
@@ -1001,7 +1127,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				credID = uuid
 
 				_, err := bc.Delete(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupDeleteRequest{
 						BackupId:     "",
 						CredentialId: credID,
@@ -1024,7 +1150,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 			By("Getting the cluster id of the cluster")
 
 			inpectResp, err := c.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkClusterInspectCurrentRequest{},
 			)
 
@@ -1042,7 +1168,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 
 				By("Attaching the created volume")
 				str, err := ma.Attach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeAttachRequest{
 						VolumeId: volID,
 					},
@@ -1058,7 +1184,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					Full:         false,
 				}
 
-				backup, err := bc.Create(setContextWithToken(context.Background(), users["admin"]), backupReq)
+				backup, err := bc.Create(setContextWithToken(context.Background(), run.users["admin"]), backupReq)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(backup).NotTo(BeNil())
 				Expect(backup.GetTaskId()).NotTo(BeEmpty())
@@ -1070,7 +1196,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 					bkpStatusReq = &api.SdkCloudBackupStatusRequest{
 						VolumeId: volID,
 					}
-					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), users["admin"]), bkpStatusReq)
+					bkpStatusResp, err := bc.Status(setContextWithToken(context.Background(), run.users["admin"]), bkpStatusReq)
 					Expect(err).To(BeNil())
 
 					bkpStatus = bkpStatusResp.Statuses[backup.TaskId]
@@ -1090,7 +1216,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				Expect(bkpStatus.Status).To(BeEquivalentTo(api.SdkCloudBackupStatusType_SdkCloudBackupStatusTypeDone))
 
 				_, err = bc.DeleteAll(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupDeleteAllRequest{
 						SrcVolumeId:  volID,
 						CredentialId: credID,
@@ -1113,7 +1239,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 		// 		credID = uuid
 
 		// 		_, err := bc.DeleteAll(
-		// 			setContextWithToken(context.Background(), users["admin"]),
+		// 			setContextWithToken(context.Background(), run.users["admin"]),
 		// 			&api.SdkCloudBackupDeleteAllRequest{
 		// 				SrcVolumeId:     "doesnt-exist",
 		// 				CredentialId: credID,
@@ -1137,7 +1263,7 @@ var _ = Describe("Cloud backup [OpenStorageClusterBackup]", func() {
 				credID = uuid
 
 				_, err := bc.DeleteAll(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupDeleteAllRequest{
 						SrcVolumeId:  "",
 						CredentialId: credID,
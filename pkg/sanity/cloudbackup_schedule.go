@@ -18,6 +18,10 @@ package sanity
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
 	"google.golang.org/grpc/codes"
@@ -27,7 +31,75 @@ import (
 	. "github.com/onsi/gomega"
 )
 
-var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
+const (
+	// backupScheduleMaxBackups is the retention of the schedules of the
+	// retention tests, which wait for backupScheduleRuns runs of them
+	backupScheduleMaxBackups = 2
+	backupScheduleRuns       = backupScheduleMaxBackups + 2
+	// backupScheduleTaskTimeout is the time given to a scheduled backup to
+	// finish, and to the retention to delete the backups over MaxBackups
+	backupScheduleTaskTimeout = 10 * time.Minute
+)
+
+// completedBackupRuns returns the number of completed backups in the history
+// of the volume, and an error if one of them failed
+func completedBackupRuns(bc api.OpenStorageCloudBackupClient, volID string) (int, error) {
+	resp, err := bc.History(
+		setContextWithToken(context.Background(), run.users["admin"]),
+		&api.SdkCloudBackupHistoryRequest{SrcVolumeId: volID},
+	)
+	if err != nil {
+		return 0, err
+	}
+	done := 0
+	for _, item := range resp.GetHistoryList() {
+		switch item.GetStatus() {
+		case api.SdkCloudBackupStatusType_SdkCloudBackupStatusTypeDone:
+			done++
+		case api.SdkCloudBackupStatusType_SdkCloudBackupStatusTypeFailed:
+			return 0, fmt.Errorf("A scheduled backup of %s failed at %v", volID, item.GetTimestamp())
+		}
+	}
+	return done, nil
+}
+
+// completedBackups returns the completed backups of the volume in the cloud,
+// oldest first
+func completedBackups(bc api.OpenStorageCloudBackupClient, clusterID, volID, credID string) ([]*api.SdkCloudBackupInfo, error) {
+	resp, err := bc.EnumerateWithFilters(
+		setContextWithToken(context.Background(), run.users["admin"]),
+		&api.SdkCloudBackupEnumerateWithFiltersRequest{
+			ClusterId:    clusterID,
+			SrcVolumeId:  volID,
+			CredentialId: credID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	backups := []*api.SdkCloudBackupInfo{}
+	for _, backup := range resp.GetBackups() {
+		if backup.GetSrcVolumeId() == volID &&
+			backup.GetStatus() == api.SdkCloudBackupStatusType_SdkCloudBackupStatusTypeDone {
+			backups = append(backups, backup)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		ti, tj := backups[i].GetTimestamp(), backups[j].GetTimestamp()
+		if ti.GetSeconds() != tj.GetSeconds() {
+			return ti.GetSeconds() < tj.GetSeconds()
+		}
+		return ti.GetNanos() < tj.GetNanos()
+	})
+	return backups, nil
+}
+
+// isFullBackup tells if the metadata of the backup has the full type
+func isFullBackup(backup *api.SdkCloudBackupInfo) bool {
+	return strings.EqualFold(backup.GetMetadata()[run.config.BackupTypeKey], "full")
+}
+
+var _ = Describe("Cloud backup schedule [OpenStorageCloudBackup]"+sdkVersionTag("OpenStorageCloudBackup/Sched"), func() {
 	var (
 		cc api.OpenStorageCredentialsClient
 		vc api.OpenStorageVolumeClient
@@ -42,11 +114,11 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 
 	BeforeEach(func() {
 
-		cc = api.NewOpenStorageCredentialsClient(conn)
-		bc = api.NewOpenStorageCloudBackupClient(conn)
-		vc = api.NewOpenStorageVolumeClient(conn)
-		ic = api.NewOpenStorageIdentityClient(conn)
-		ma = api.NewOpenStorageMountAttachClient(conn)
+		cc = api.NewOpenStorageCredentialsClient(run.conn)
+		bc = api.NewOpenStorageCloudBackupClient(run.conn)
+		vc = api.NewOpenStorageVolumeClient(run.conn)
+		ic = api.NewOpenStorageIdentityClient(run.conn)
+		ma = api.NewOpenStorageMountAttachClient(run.conn)
 
 		isSupported := isCapabilitySupported(
 			ic,
@@ -61,7 +133,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 		credID = ""
 		credsUUIDMap = make(map[string]string)
 
-		if config.ProviderConfig == nil {
+		if run.config.ProviderConfig == nil {
 			Skip("Skipping cloud backup tests")
 		}
 	})
@@ -72,7 +144,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 				credID = uuid
 
 				cc.Delete(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCredentialDeleteRequest{
 						CredentialId: credID,
 					},
@@ -82,7 +154,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 
 		if volID != "" {
 			_, err := ma.Detach(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeDetachRequest{
 					VolumeId: volID,
 					Options: &api.SdkVolumeDetachOptions{
@@ -92,7 +164,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 			)
 			Expect(err).NotTo(HaveOccurred())
 			_, err = vc.Delete(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeDeleteRequest{VolumeId: volID},
 			)
 			Expect(err).NotTo(HaveOccurred())
@@ -113,7 +185,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 
 				By("Attaching the created volume")
 				str, err := ma.Attach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeAttachRequest{
 						VolumeId: volID,
 					},
@@ -136,7 +208,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 				}
 
 				schedCreateResp, err := bc.SchedCreate(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupSchedCreateRequest{
 						CloudSchedInfo: &api.SdkCloudBackupScheduleInfo{
 							CredentialId: credID,
@@ -152,7 +224,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 
 				By("Deleting the schedule")
 				_, err = bc.SchedDelete(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupSchedDeleteRequest{
 						BackupScheduleId: schedCreateResp.BackupScheduleId,
 					},
@@ -183,7 +255,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 				}
 
 				schedCreateResp, err := bc.SchedCreate(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupSchedCreateRequest{
 						CloudSchedInfo: &api.SdkCloudBackupScheduleInfo{
 							CredentialId: credID,
@@ -225,7 +297,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 				}
 
 				schedCreateResp, err := bc.SchedCreate(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupSchedCreateRequest{
 						CloudSchedInfo: &api.SdkCloudBackupScheduleInfo{
 							CredentialId: credID,
@@ -266,7 +338,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 				}
 
 				schedCreateResp, err := bc.SchedCreate(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupSchedCreateRequest{
 						CloudSchedInfo: &api.SdkCloudBackupScheduleInfo{
 							CredentialId: credID,
@@ -301,7 +373,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 
 				By("Attaching the created volume")
 				str, err := ma.Attach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeAttachRequest{
 						VolumeId: volID,
 					},
@@ -324,7 +396,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 				}
 
 				schedCreateResp, err := bc.SchedCreate(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupSchedCreateRequest{
 						CloudSchedInfo: &api.SdkCloudBackupScheduleInfo{
 							CredentialId: credID,
@@ -340,7 +412,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 			}
 
 			enumResp, err := bc.SchedEnumerate(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkCloudBackupSchedEnumerateRequest{},
 			)
 
@@ -363,7 +435,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 
 				By("Attaching the created volume")
 				str, err := ma.Attach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeAttachRequest{
 						VolumeId: volID,
 					},
@@ -386,7 +458,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 				}
 
 				schedCreateResp, err := bc.SchedCreate(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupSchedCreateRequest{
 						CloudSchedInfo: &api.SdkCloudBackupScheduleInfo{
 							CredentialId: credID,
@@ -401,7 +473,7 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 				Expect(schedCreateResp.BackupScheduleId).NotTo(BeNil())
 
 				_, err = bc.SchedDelete(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkCloudBackupSchedDeleteRequest{
 						BackupScheduleId: schedCreateResp.BackupScheduleId,
 					},
@@ -411,4 +483,140 @@ var _ = Describe("Cloud backup schedule [OpenStorageCluster]", func() {
 			}
 		})
 	})
+
+	Describe("Cloudbackup Schedule Retention"+sdkVersionTag("OpenStorageCloudBackup/History"), func() {
+
+		var (
+			b *backupVolume
+		)
+
+		BeforeEach(func() {
+			if run.config.BackupScheduleInterval <= 0 {
+				Skip("Cloud backup schedule retention tests need --sdk.backup-schedule-interval")
+			}
+			b = nil
+		})
+
+		AfterEach(func() {
+			if b != nil {
+				b.delete(vc)
+			}
+		})
+
+		// runSchedule backs up a volume on every cloud provider with a
+		// periodic schedule until it ran more than its MaxBackups, then
+		// checks the retained backups
+		runSchedule := func(full bool) {
+			ctx := setContextWithToken(context.Background(), run.users["admin"])
+			interval := run.config.BackupScheduleInterval
+			cluster, err := api.NewOpenStorageClusterClient(run.conn).InspectCurrent(
+				ctx,
+				&api.SdkClusterInspectCurrentRequest{},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			clusterID := cluster.GetCluster().GetId()
+
+			By("Creating an attached volume and the credentials of the cloud providers")
+			b = newBackupVolume(vc)
+
+			for provider, credID := range b.credIDs {
+				// The history of the volume keeps the runs of the
+				// schedules of the previous providers
+				previousRuns, err := completedBackupRuns(bc, b.volID)
+				Expect(err).NotTo(HaveOccurred())
+
+				By(fmt.Sprintf("Creating a backup schedule on %s every %v", provider, interval))
+				schedCreateResp, err := bc.SchedCreate(ctx, &api.SdkCloudBackupSchedCreateRequest{
+					CloudSchedInfo: &api.SdkCloudBackupScheduleInfo{
+						CredentialId: credID,
+						MaxBackups:   backupScheduleMaxBackups,
+						SrcVolumeId:  b.volID,
+						Full:         full,
+						Schedules: []*api.SdkSchedulePolicyInterval{
+							&api.SdkSchedulePolicyInterval{
+								Retain: 1,
+								PeriodType: &api.SdkSchedulePolicyInterval_Periodic{
+									Periodic: &api.SdkSchedulePolicyIntervalPeriodic{
+										Seconds: int64(interval / time.Second),
+									},
+								},
+							},
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				schedID := schedCreateResp.GetBackupScheduleId()
+				deleteSchedule := func() {
+					_, err := bc.SchedDelete(ctx, &api.SdkCloudBackupSchedDeleteRequest{
+						BackupScheduleId: schedID,
+					})
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				By("Waiting for the first scheduled backup")
+				var first string
+				err = waitFor(interval+backupScheduleTaskTimeout, 5*time.Second, func() (bool, error) {
+					backups, err := completedBackups(bc, clusterID, b.volID, credID)
+					if err != nil || len(backups) == 0 {
+						return err == nil, err
+					}
+					first = backups[0].GetId()
+					return false, nil
+				})
+				if err != nil {
+					deleteSchedule()
+				}
+				Expect(err).NotTo(HaveOccurred())
+
+				By(fmt.Sprintf("Waiting for %d scheduled backups", backupScheduleRuns))
+				runs := 0
+				err = waitFor(backupScheduleRuns*interval+backupScheduleTaskTimeout, 5*time.Second, func() (bool, error) {
+					done, err := completedBackupRuns(bc, b.volID)
+					runs = done - previousRuns
+					return err == nil && runs < backupScheduleRuns, err
+				})
+				deleteSchedule()
+				Expect(err).NotTo(HaveOccurred(), "%d of %d scheduled backups completed", runs, backupScheduleRuns)
+
+				By("Checking the oldest backups were pruned")
+				var backups []*api.SdkCloudBackupInfo
+				err = waitFor(backupScheduleTaskTimeout, 5*time.Second, func() (bool, error) {
+					backups, err = completedBackups(bc, clusterID, b.volID, credID)
+					return err == nil && len(backups) > backupScheduleMaxBackups, err
+				})
+				Expect(err).NotTo(HaveOccurred(),
+					"%d backups are retained instead of %d", len(backups), backupScheduleMaxBackups)
+				Expect(backups).NotTo(BeEmpty())
+				for _, backup := range backups {
+					Expect(backup.GetId()).NotTo(Equal(first), "The first scheduled backup was not pruned")
+				}
+
+				if len(run.config.BackupTypeKey) == 0 {
+					By("Skipping the check of the backup types, --sdk.backup-type-key is not set")
+					continue
+				}
+
+				By("Checking the types of the retained backups")
+				incremental := 0
+				for _, backup := range backups {
+					if !isFullBackup(backup) {
+						incremental++
+					}
+				}
+				if full {
+					Expect(incremental).To(BeZero(), "Incremental backups were taken by a schedule of full backups")
+				} else {
+					Expect(incremental).NotTo(BeZero(), "Only full backups were taken by a schedule of incremental backups")
+				}
+			}
+		}
+
+		It("Should prune incremental scheduled backups beyond MaxBackups", func() {
+			runSchedule(false)
+		})
+
+		It("Should only take full backups when the schedule is Full", func() {
+			runSchedule(true)
+		})
+	})
 })
@@ -38,10 +38,10 @@ var _ = Describe("Cluster [OpenStorageCluster]", func() {
 	)
 
 	BeforeEach(func() {
-		c = api.NewOpenStorageClusterClient(conn)
-		v = api.NewOpenStorageVolumeClient(conn)
-		n = api.NewOpenStorageNodeClient(conn)
-		ic = api.NewOpenStorageIdentityClient(conn)
+		c = api.NewOpenStorageClusterClient(run.conn)
+		v = api.NewOpenStorageVolumeClient(run.conn)
+		n = api.NewOpenStorageNodeClient(run.conn)
+		ic = api.NewOpenStorageIdentityClient(run.conn)
 
 		isSupported := isCapabilitySupported(
 			ic,
@@ -58,7 +58,7 @@ var _ = Describe("Cluster [OpenStorageCluster]", func() {
 	AfterEach(func() {
 		if volID != "" {
 			err := deleteVol(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				v,
 				volID)
 			Expect(err).NotTo(HaveOccurred())
@@ -66,18 +66,18 @@ var _ = Describe("Cluster [OpenStorageCluster]", func() {
 	})
 
 	It("should return a cluster id", func() {
-		info, err := c.InspectCurrent(setContextWithToken(context.Background(), users["admin"]),
+		info, err := c.InspectCurrent(setContextWithToken(context.Background(), run.users["admin"]),
 			&api.SdkClusterInspectCurrentRequest{})
 		Expect(err).NotTo(HaveOccurred())
 		Expect(info.Cluster).NotTo(BeNil())
 	})
 
-	Describe("Node Enumerate", func() {
+	Describe("Node Enumerate [OpenStorageNode]", func() {
 
 		It("Should successfully enumerate nodes", func() {
 
 			enumResp, err := n.Enumerate(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkNodeEnumerateRequest{},
 			)
 
@@ -86,12 +86,12 @@ var _ = Describe("Cluster [OpenStorageCluster]", func() {
 		})
 	})
 
-	Describe("Node Inspect", func() {
+	Describe("Node Inspect [OpenStorageNode]", func() {
 
 		It("Should inspect all the nodes Successfully", func() {
 			By("Enumerating the nodes and getting the node id")
 			enumResp, err := n.Enumerate(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkNodeEnumerateRequest{},
 			)
 			Expect(err).NotTo(HaveOccurred())
@@ -100,7 +100,7 @@ var _ = Describe("Cluster [OpenStorageCluster]", func() {
 			for _, nodeID := range enumResp.NodeIds {
 
 				inspectResp, err := n.Inspect(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkNodeInspectRequest{
 						NodeId: nodeID,
 					},
@@ -115,7 +115,7 @@ var _ = Describe("Cluster [OpenStorageCluster]", func() {
 		It("Should fail to inspect a a non-existent node id", func() {
 
 			inspectResp, err := n.Inspect(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkNodeInspectRequest{
 					NodeId: "node-id-doesnt-exist",
 				},
@@ -130,7 +130,7 @@ var _ = Describe("Cluster [OpenStorageCluster]", func() {
 
 		It("Should fail to inspect an empty node id", func() {
 			inspectResp, err := n.Inspect(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkNodeInspectRequest{
 					NodeId: "",
 				},
@@ -144,10 +144,10 @@ var _ = Describe("Cluster [OpenStorageCluster]", func() {
 		})
 	})
 
-	Describe("Node InspectCurrent", func() {
+	Describe("Node InspectCurrent [OpenStorageNode]", func() {
 		It("Should inspect the current node successfully", func() {
 			resp, err := n.InspectCurrent(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkNodeInspectCurrentRequest{},
 			)
 
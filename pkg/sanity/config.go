@@ -0,0 +1,175 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// envPrefix starts the environment variables which override the options of
+// the configuration, like SDK_SHAREDSECRET
+const envPrefix = "SDK_"
+
+// LoadConfiguration reads a configuration from a YAML file. Unknown keys are
+// reported as errors so that misspelled options are not silently ignored.
+func LoadConfiguration(filename string) (*SanityConfiguration, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the configuration file %s: %v", filename, err)
+	}
+
+	c := &SanityConfiguration{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("Unable to parse the configuration file %s: %v", filename, err)
+	}
+	return c, nil
+}
+
+func yamlKey(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("yaml"), ",")[0]
+}
+
+// configOption returns the field of the configuration for the YAML key
+func (c *SanityConfiguration) configOption(key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if yamlKey(v.Type().Field(i)) == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// OptionNames returns the YAML keys of the options which can be set with
+// SetOption, sorted
+func OptionNames() []string {
+	names := []string{}
+	v := reflect.ValueOf(SanityConfiguration{})
+	for i := 0; i < v.NumField(); i++ {
+		if key := yamlKey(v.Type().Field(i)); len(key) != 0 && isSettable(v.Field(i)) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func isSettable(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Uint64, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return field.Type().Elem().Kind() == reflect.String
+	case reflect.Ptr:
+		// Options whose zero value is valid are pointers, nil when unset
+		return field.Type().Elem().Kind() != reflect.Ptr && isSettable(reflect.Zero(field.Type().Elem()))
+	}
+	return false
+}
+
+// SetOption sets an option from its string form, using its YAML key like
+// sharedsecret or junit-report. Lists are comma separated, and durations use
+// the format of time.ParseDuration.
+func (c *SanityConfiguration) SetOption(key, value string) error {
+	field, ok := c.configOption(key)
+	if !ok || !isSettable(field) {
+		return fmt.Errorf("Unknown configuration option %q", key)
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Option %s must be true or false: %v", key, err)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		if field.Type() == durationType {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("Option %s must be a duration like 30s: %v", key, err)
+			}
+			field.SetInt(int64(d))
+			break
+		}
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Option %s must be a number: %v", key, err)
+		}
+		field.SetInt(i)
+	case reflect.Uint64:
+		i, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Option %s must be a positive number: %v", key, err)
+		}
+		field.SetUint(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("Option %s must be a number: %v", key, err)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); len(item) != 0 {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	}
+	return nil
+}
+
+// EnvironmentVariable returns the name of the environment variable which
+// overrides an option, like SDK_JUNIT_REPORT for junit-report
+func EnvironmentVariable(key string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(key, "-", "_", -1))
+}
+
+// LoadEnvironment overrides the options which have their environment
+// variable set. This keeps secrets like SDK_SHAREDSECRET out of the
+// configuration file and the process listing.
+func (c *SanityConfiguration) LoadEnvironment() error {
+	for _, key := range OptionNames() {
+		value, ok := os.LookupEnv(EnvironmentVariable(key))
+		if !ok {
+			continue
+		}
+		if err := c.SetOption(key, value); err != nil {
+			return fmt.Errorf("Invalid environment variable %s: %v", EnvironmentVariable(key), err)
+		}
+	}
+	return nil
+}
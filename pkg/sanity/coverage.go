@@ -0,0 +1,221 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// internalCallKey marks the context of the calls the suite makes for itself,
+// like checking the capabilities of the server or sweeping leftovers
+type internalCallKey struct{}
+
+// internalContext returns a context whose calls are not counted as covering
+// the API
+func internalContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalCallKey{}, true)
+}
+
+// setupNodes are the Ginkgo nodes which run fixtures instead of specs, like
+// BeforeEach, AfterEach and BeforeSuite
+var setupNodes = []string{
+	"ginkgo/internal/leafnodes.(*SetupNode)",
+	"ginkgo/internal/leafnodes.(*simpleSuiteNode)",
+	"ginkgo/internal/leafnodes.(*synchronizedBeforeSuiteNode)",
+	"ginkgo/internal/leafnodes.(*synchronizedAfterSuiteNode)",
+}
+
+// isSpecCall returns false for the calls which do not test the API: the
+// internal calls, and the calls made by fixtures. Calls made by goroutines
+// started in the body of a spec are counted.
+func isSpecCall(ctx context.Context) bool {
+	if internal, _ := ctx.Value(internalCallKey{}).(bool); internal {
+		return false
+	}
+	pcs := make([]uintptr, 128)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		for _, node := range setupNodes {
+			if strings.Contains(frame.Function, node) {
+				return false
+			}
+		}
+		if !more {
+			return true
+		}
+	}
+}
+
+// methodCalls counts the calls of an RPC which succeeded and failed
+type methodCalls struct {
+	succeeded int
+	failed    int
+}
+
+// apiCoverage records the RPCs called by the bodies of the specs, to find the
+// ones which are never called, or only called with requests which fail
+type apiCoverage struct {
+	lock  sync.Mutex
+	calls map[string]*methodCalls
+}
+
+func newAPICoverage() *apiCoverage {
+	return &apiCoverage{
+		calls: make(map[string]*methodCalls),
+	}
+}
+
+func (c *apiCoverage) record(method string, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	calls, ok := c.calls[method]
+	if !ok {
+		calls = &methodCalls{}
+		c.calls[method] = calls
+	}
+	if err == nil {
+		calls.succeeded++
+	} else {
+		calls.failed++
+	}
+}
+
+func (c *apiCoverage) unaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if isSpecCall(ctx) {
+		c.record(method, err)
+	}
+	return err
+}
+
+// coverageStream records a streaming call when its first response arrives,
+// or when it fails
+type coverageStream struct {
+	grpc.ClientStream
+	coverage *apiCoverage
+	method   string
+	once     sync.Once
+}
+
+func (s *coverageStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	s.once.Do(func() {
+		if err == io.EOF {
+			err = nil
+		}
+		s.coverage.record(s.method, err)
+	})
+	return err
+}
+
+func (c *apiCoverage) streamInterceptor(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if !isSpecCall(ctx) {
+		return stream, err
+	}
+	if err != nil {
+		c.record(method, err)
+		return nil, err
+	}
+	return &coverageStream{ClientStream: stream, coverage: c, method: method}, nil
+}
+
+// serviceCoverage lists the RPCs of a service by how they were called
+type serviceCoverage struct {
+	service      string
+	covered      []string
+	onlyNegative []string
+	uncovered    []string
+}
+
+// services returns the coverage of each service of the SDK, in the order of
+// api.proto
+func (c *apiCoverage) services(d *sdkDescriptors) []*serviceCoverage {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	list := []*serviceCoverage{}
+	byName := make(map[string]*serviceCoverage)
+	for _, m := range d.methods {
+		s, ok := byName[m.service]
+		if !ok {
+			s = &serviceCoverage{service: m.service}
+			byName[m.service] = s
+			list = append(list, s)
+		}
+
+		calls := c.calls[m.fullMethod]
+		switch {
+		case calls == nil:
+			s.uncovered = append(s.uncovered, m.name)
+		case calls.succeeded == 0:
+			s.onlyNegative = append(s.onlyNegative, m.name)
+		default:
+			s.covered = append(s.covered, m.name)
+		}
+	}
+	return list
+}
+
+// report prints the coverage of each service and returns the percentage of
+// the RPCs of the SDK called successfully at least once
+func (c *apiCoverage) report(w io.Writer, d *sdkDescriptors) float64 {
+	services := c.services(d)
+
+	covered := 0
+	for _, s := range services {
+		covered += len(s.covered)
+	}
+	percent := 100 * float64(covered) / float64(len(d.methods))
+
+	fmt.Fprintf(w, "\nSDK API coverage: %d of %d RPCs called successfully (%.1f%%)\n",
+		covered, len(d.methods), percent)
+	for _, s := range services {
+		total := len(s.covered) + len(s.onlyNegative) + len(s.uncovered)
+		fmt.Fprintf(w, "  %s: %d of %d covered\n", s.service, len(s.covered), total)
+		if len(s.onlyNegative) != 0 {
+			fmt.Fprintf(w, "    only negative: %s\n", strings.Join(s.onlyNegative, ", "))
+		}
+		if len(s.uncovered) != 0 {
+			fmt.Fprintf(w, "    uncovered: %s\n", strings.Join(s.uncovered, ", "))
+		}
+	}
+	return percent
+}
@@ -31,11 +31,13 @@ var _ = Describe("Credentials [OpenStorageCredentials]", func() {
 	var (
 		credClient api.OpenStorageCredentialsClient
 		ic         api.OpenStorageIdentityClient
+		credIDs    []string
 	)
 
 	BeforeEach(func() {
+		credIDs = nil
 
-		ic = api.NewOpenStorageIdentityClient(conn)
+		ic = api.NewOpenStorageIdentityClient(run.conn)
 
 		isSupported := isCapabilitySupported(
 			ic,
@@ -46,52 +48,48 @@ var _ = Describe("Credentials [OpenStorageCredentials]", func() {
 			Skip("Credentials capability not supported , skipping related tests")
 		}
 
-		credClient = api.NewOpenStorageCredentialsClient(conn)
-		if config.ProviderConfig == nil {
+		credClient = api.NewOpenStorageCredentialsClient(run.conn)
+		if run.config.ProviderConfig == nil {
 			Skip("Skipping credentials tests")
 		}
 	})
 
 	AfterEach(func() {
-		// Delete all credential stored after each test
-		credEnumReq := &api.SdkCredentialEnumerateRequest{}
-		credEnumResp, err := credClient.Enumerate(setContextWithToken(context.Background(), users["admin"]), credEnumReq)
-		Expect(err).NotTo(HaveOccurred())
-
-		for _, credID := range credEnumResp.GetCredentialIds() {
-			credClient.Delete(setContextWithToken(context.Background(), users["admin"]), &api.SdkCredentialDeleteRequest{CredentialId: credID})
+		// Delete only the credentials created by the test, other runs may
+		// be using the same cluster
+		for _, credID := range credIDs {
+			credClient.Delete(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkCredentialDeleteRequest{CredentialId: credID})
 		}
 	})
 
 	Describe("Credentials Create [Buggy]", func() {
 		It("Should Create Credentials", func() {
-			credEnumReq := &api.SdkCredentialEnumerateRequest{}
-			credEnumResp, err := credClient.Enumerate(setContextWithToken(context.Background(), users["admin"]), credEnumReq)
-
-			By("checking what is there already")
-			Expect(err).NotTo(HaveOccurred())
-			numCreds := len(credEnumResp.GetCredentialIds())
-
 			By("creating new credentials")
-			numCredCreated := parseAndCreateCredentials(credClient)
-			credEnumResp, err = credClient.Enumerate(setContextWithToken(context.Background(), users["admin"]), credEnumReq)
+			for _, credID := range parseAndCreateCredentials2(credClient) {
+				credIDs = append(credIDs, credID)
+			}
+
+			credEnumReq := &api.SdkCredentialEnumerateRequest{}
+			credEnumResp, err := credClient.Enumerate(setContextWithToken(context.Background(), run.users["admin"]), credEnumReq)
 
 			By("checking the new credentials were created")
 			Expect(err).NotTo(HaveOccurred())
-			Expect(len(credEnumResp.GetCredentialIds())).To(Equal(numCredCreated + numCreds))
+			for _, credID := range credIDs {
+				Expect(credEnumResp.GetCredentialIds()).To(ContainElement(credID))
+			}
 
 		})
 
-		It("Should provide detail,verify, and delete given credential ID", func() {
+		It("Should provide detail,verify, and delete given credential ID"+sdkVersionTag("OpenStorageCredentials/Validate"), func() {
 			credID := ""
 			accessKey := ""
 			region := ""
 
 			// Create credential from cb.yaml
-			for provider, providerParams := range config.ProviderConfig.CloudProviders {
+			for provider, providerParams := range run.config.ProviderConfig.CloudProviders {
 				if provider == "aws" {
 					credReq := &api.SdkCredentialCreateRequest{
-						Name: providerParams["CredName"],
+						Name: genName(providerParams["CredName"]),
 						CredentialType: &api.SdkCredentialCreateRequest_AwsCredential{
 							AwsCredential: &api.SdkAwsCredentialRequest{
 								AccessKey:  providerParams["CredAccessKey"],
@@ -104,26 +102,27 @@ var _ = Describe("Credentials [OpenStorageCredentials]", func() {
 					}
 
 					By("creating credentials")
-					credResp, err := credClient.Create(setContextWithToken(context.Background(), users["admin"]), credReq)
+					credResp, err := credClient.Create(setContextWithToken(context.Background(), run.users["admin"]), credReq)
 					Expect(err).NotTo(HaveOccurred())
 					credID = credResp.GetCredentialId()
 					Expect(credID).NotTo(BeEmpty())
+					credIDs = append(credIDs, credID)
 					accessKey = credReq.GetAwsCredential().GetAccessKey()
 					region = credReq.GetAwsCredential().GetRegion()
 
 					By("verfiying credentials")
-					_, err = credClient.Validate(setContextWithToken(context.Background(), users["admin"]), &api.SdkCredentialValidateRequest{CredentialId: credID})
+					_, err = credClient.Validate(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkCredentialValidateRequest{CredentialId: credID})
 					Expect(err).NotTo(HaveOccurred())
 
 					By("inspecting credentials")
 					inspectReq := &api.SdkCredentialInspectRequest{CredentialId: credID}
-					inspectResp, err := credClient.Inspect(setContextWithToken(context.Background(), users["admin"]), inspectReq)
+					inspectResp, err := credClient.Inspect(setContextWithToken(context.Background(), run.users["admin"]), inspectReq)
 					Expect(err).NotTo(HaveOccurred())
 					Expect(inspectResp.GetAwsCredential().GetAccessKey()).To(BeEquivalentTo(accessKey))
 					Expect(inspectResp.GetAwsCredential().GetRegion()).To(BeEquivalentTo(region))
 
 					By("deleting credentials")
-					_, err = credClient.Delete(setContextWithToken(context.Background(), users["admin"]), &api.SdkCredentialDeleteRequest{CredentialId: credID})
+					_, err = credClient.Delete(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkCredentialDeleteRequest{CredentialId: credID})
 					Expect(err).NotTo(HaveOccurred())
 					break
 				}
@@ -134,7 +133,7 @@ var _ = Describe("Credentials [OpenStorageCredentials]", func() {
 	Describe("Credentials Delete", func() {
 		It("Should failed to delete non-existanant Credentials", func() {
 
-			_, err := credClient.Delete(setContextWithToken(context.Background(), users["admin"]), &api.SdkCredentialDeleteRequest{CredentialId: ""})
+			_, err := credClient.Delete(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkCredentialDeleteRequest{CredentialId: ""})
 			Expect(err).To(HaveOccurred())
 
 			serverError, ok := status.FromError(err)
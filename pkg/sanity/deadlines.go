@@ -0,0 +1,547 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	// createDeadlines are the deadlines of the creates which should time
+	// out, from failing before the request is sent to failing while the
+	// server creates the resource
+	createDeadlines = []time.Duration{
+		time.Microsecond,
+		100 * time.Microsecond,
+		time.Millisecond,
+		10 * time.Millisecond,
+	}
+
+	// deadlineSettleTime is the time given to the server to finish a create
+	// which timed out, before looking for the resource
+	deadlineSettleTime = 3 * time.Second
+
+	// interruptDelays are the delays after which calls are cancelled or
+	// their deadline expires. They are positive so that the calls are sent
+	// to the server, and short so that most end while the server handles
+	// them.
+	interruptDelays = []time.Duration{
+		time.Millisecond,
+		10 * time.Millisecond,
+	}
+)
+
+// interruptedCallLabel is added to the requests of interrupted calls which
+// create labelled resources, to find the resources they left behind
+const interruptedCallLabel = "sdk-test-interrupted-call"
+
+// interruptedCall sends requests of an RPC which are cancelled or time out
+// after each of the interruptDelays
+type interruptedCall struct {
+	m sdkMethod
+	// code is the code of an interrupted call, Canceled or DeadlineExceeded
+	code codes.Code
+	// interrupt returns a context which is cancelled or expires after the
+	// delay
+	interrupt func(ctx context.Context, delay time.Duration) (context.Context, context.CancelFunc)
+}
+
+// request returns the empty request of the RPC, labelled with the label if
+// the RPC creates labelled resources
+func (c *interruptedCall) request(label string) (proto.Message, error) {
+	req, err := newMessage(c.m.input)
+	if err != nil {
+		return nil, err
+	}
+	switch req := req.(type) {
+	case *api.SdkVolumeCreateRequest:
+		req.Labels = map[string]string{interruptedCallLabel: label}
+	case *api.SdkVolumeSnapshotCreateRequest:
+		req.Labels = map[string]string{interruptedCallLabel: label}
+	}
+	return req, nil
+}
+
+// check sends the request without a deadline, then interrupted after each
+// delay. An interrupted call must return the code of interrupted calls, or
+// the answer of the server to the request if it was faster. The calls must
+// not leave resources behind which the tests do not know about.
+func (c *interruptedCall) check() {
+	ctx := setContextWithToken(context.Background(), run.users["admin"])
+	label := genName("interrupted")
+	req, err := c.request(label)
+	Expect(err).NotTo(HaveOccurred())
+
+	callCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	_, err = invokeMethod(callCtx, c.m, req)
+	cancel()
+	answer := status.Code(err)
+
+	for _, delay := range interruptDelays {
+		callCtx, cancel := c.interrupt(ctx, delay)
+		_, err := invokeMethod(callCtx, c.m, req)
+		cancel()
+		Expect(status.Code(err)).To(beOneOfCodes(c.code, answer),
+			"%s interrupted after %v returned %v", c.m.fullMethod, delay, err)
+	}
+
+	if _, ok := req.(*api.SdkVolumeCreateRequest); ok {
+		c.checkLeftovers(label)
+	} else if _, ok := req.(*api.SdkVolumeSnapshotCreateRequest); ok {
+		c.checkLeftovers(label)
+	}
+}
+
+// checkLeftovers fails if a volume or snapshot with the label was created
+// but is not in the ledger, because the call which created it was
+// interrupted. The resources are deleted.
+func (c *interruptedCall) checkLeftovers(label string) {
+	// The server may still be creating the resource
+	time.Sleep(deadlineSettleTime)
+
+	ctx := setContextWithToken(context.Background(), run.users["admin"])
+	vc := api.NewOpenStorageVolumeClient(run.conn)
+	labels := map[string]string{interruptedCallLabel: label}
+	volumes, err := vc.EnumerateWithFilters(ctx, &api.SdkVolumeEnumerateWithFiltersRequest{Labels: labels})
+	Expect(err).NotTo(HaveOccurred())
+	snapshots, err := vc.SnapshotEnumerateWithFilters(ctx, &api.SdkVolumeSnapshotEnumerateWithFiltersRequest{Labels: labels})
+	Expect(err).NotTo(HaveOccurred())
+
+	known := make(map[string]bool)
+	for _, kind := range []resourceKind{kindVolume, kindSnapshot} {
+		for _, r := range run.ledger.list(kind) {
+			known[r.id] = true
+		}
+	}
+	leftovers := []string{}
+	for _, id := range append(volumes.GetVolumeIds(), snapshots.GetVolumeSnapshotIds()...) {
+		if !known[id] {
+			known[id] = true
+			leftovers = append(leftovers, id)
+		}
+	}
+	for _, id := range leftovers {
+		Expect(deleteVol(ctx, vc, id)).NotTo(HaveOccurred())
+	}
+	Expect(leftovers).To(BeEmpty(), "%s created resources after it was interrupted", c.m.fullMethod)
+}
+
+// timedOutCreate creates a kind of resource with deadlines too short to
+// succeed
+type timedOutCreate struct {
+	kind string
+	// idempotent creates return the resource created by a previous call
+	// with the same name or task id
+	idempotent bool
+	create     func(ctx context.Context, name string) (string, error)
+	// find returns the id of the resource with the name, or an empty id
+	find   func(name string) (string, error)
+	remove func(id string) error
+}
+
+// check creates resources with each deadline of createDeadlines. A create
+// which times out must return DeadlineExceeded, and must not leave a resource
+// behind unless creating it again with the same name returns it.
+func (c *timedOutCreate) check() {
+	ctx := setContextWithToken(context.Background(), run.users["admin"])
+	for _, timeout := range createDeadlines {
+		name := genName(c.kind)
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		id, err := c.create(callCtx, name)
+		cancel()
+		if err == nil {
+			// The server was faster than the deadline
+			Expect(c.remove(id)).NotTo(HaveOccurred())
+			continue
+		}
+		Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded),
+			"Create of a %s with a deadline of %v returned %v", c.kind, timeout, err)
+
+		// The server may still be creating the resource
+		time.Sleep(deadlineSettleTime)
+		leftover, err := c.find(name)
+		Expect(err).NotTo(HaveOccurred())
+		if leftover == "" {
+			continue
+		}
+		if !c.idempotent {
+			Expect(c.remove(leftover)).NotTo(HaveOccurred())
+			Fail(fmt.Sprintf("The %s %s was created after its Create returned DeadlineExceeded after %v",
+				c.kind, name, timeout))
+		}
+
+		By(fmt.Sprintf("creating the %s %s left by the create which timed out again", c.kind, name))
+		id, err = c.create(ctx, name)
+		Expect(c.remove(leftover)).NotTo(HaveOccurred())
+		Expect(err).NotTo(HaveOccurred(),
+			"The %s %s was created after its Create returned DeadlineExceeded, and creating it again failed",
+			c.kind, name)
+		Expect(id).To(Equal(leftover))
+	}
+}
+
+// findVolumeByName returns the id of the volume with the name, or an empty
+// id if there is none
+func findVolumeByName(vc api.OpenStorageVolumeClient, name string) (string, error) {
+	resp, err := vc.EnumerateWithFilters(
+		setContextWithToken(context.Background(), run.users["admin"]),
+		&api.SdkVolumeEnumerateWithFiltersRequest{Name: name},
+	)
+	if err != nil || len(resp.GetVolumeIds()) == 0 {
+		return "", err
+	}
+	return resp.GetVolumeIds()[0], nil
+}
+
+// notFoundIsEmpty returns an empty id for NotFound errors
+func notFoundIsEmpty(id string, err error) (string, error) {
+	if status.Code(err) == codes.NotFound {
+		return "", nil
+	}
+	return id, err
+}
+
+var _ = Describe("Deadlines [Deadlines]", func() {
+
+	Describe("Interrupted calls", func() {
+		d, err := getSdkDescriptors()
+		if err != nil {
+			panic(err)
+		}
+
+		services := []string{}
+		methods := make(map[string][]sdkMethod)
+		for _, m := range d.methods {
+			if _, ok := methods[m.service]; !ok {
+				services = append(services, m.service)
+			}
+			methods[m.service] = append(methods[m.service], m)
+		}
+
+		for _, service := range services {
+			service := service
+			list := methods[service]
+
+			Describe(fmt.Sprintf("%s [%s]", service, service), func() {
+				BeforeEach(func() {
+					skipUnsupportedService(service)
+				})
+
+				for _, m := range list {
+					m := m
+
+					It(fmt.Sprintf("should return Canceled for %s when cancelled while it runs%s", m.name, sdkVersionTag(service+"/"+m.name)), func() {
+						if reason, ok := fuzzExclusions[service+"/"+m.name]; ok {
+							Skip("Not called because " + reason)
+						}
+						c := &interruptedCall{
+							m:    m,
+							code: codes.Canceled,
+							interrupt: func(ctx context.Context, delay time.Duration) (context.Context, context.CancelFunc) {
+								ctx, cancel := context.WithCancel(ctx)
+								time.AfterFunc(delay, cancel)
+								return ctx, cancel
+							},
+						}
+						c.check()
+					})
+
+					It(fmt.Sprintf("should return DeadlineExceeded for %s when its deadline expires while it runs%s", m.name, sdkVersionTag(service+"/"+m.name)), func() {
+						if reason, ok := fuzzExclusions[service+"/"+m.name]; ok {
+							Skip("Not called because " + reason)
+						}
+						c := &interruptedCall{
+							m:         m,
+							code:      codes.DeadlineExceeded,
+							interrupt: context.WithTimeout,
+						}
+						c.check()
+					})
+				}
+			})
+		}
+	})
+
+	Describe("Timed out creates", func() {
+		var (
+			ic api.OpenStorageIdentityClient
+			vc api.OpenStorageVolumeClient
+		)
+
+		BeforeEach(func() {
+			ic = api.NewOpenStorageIdentityClient(run.conn)
+			vc = api.NewOpenStorageVolumeClient(run.conn)
+		})
+
+		removeVolume := func(id string) error {
+			return deleteVol(setContextWithToken(context.Background(), run.users["admin"]), vc, id)
+		}
+
+		Describe("Volumes [OpenStorageVolume]", func() {
+			var volID string
+
+			BeforeEach(func() {
+				skipUnsupportedService("OpenStorageVolume")
+				volID = ""
+			})
+
+			AfterEach(func() {
+				if volID != "" {
+					Expect(removeVolume(volID)).NotTo(HaveOccurred())
+				}
+			})
+
+			It("should not leave a volume behind unless it is created again with the same name", func() {
+				c := &timedOutCreate{
+					kind:       "vol",
+					idempotent: true,
+					create: func(ctx context.Context, name string) (string, error) {
+						resp, err := vc.Create(ctx, &api.SdkVolumeCreateRequest{
+							Name: name,
+							Spec: &api.VolumeSpec{
+								Size:    uint64(5 * GIGABYTE),
+								HaLevel: 1,
+								Format:  api.FSType_FS_TYPE_EXT4,
+							},
+						})
+						return resp.GetVolumeId(), err
+					},
+					find: func(name string) (string, error) {
+						return findVolumeByName(vc, name)
+					},
+					remove: removeVolume,
+				}
+				c.check()
+			})
+
+			It("should not leave a clone behind unless it is created again with the same name", func() {
+				volID = newTestVolume(vc)
+				c := &timedOutCreate{
+					kind:       "clone",
+					idempotent: true,
+					create: func(ctx context.Context, name string) (string, error) {
+						resp, err := vc.Clone(ctx, &api.SdkVolumeCloneRequest{
+							Name:     name,
+							ParentId: volID,
+						})
+						return resp.GetVolumeId(), err
+					},
+					find: func(name string) (string, error) {
+						return findVolumeByName(vc, name)
+					},
+					remove: removeVolume,
+				}
+				c.check()
+			})
+
+			It("should not leave a snapshot behind", func() {
+				volID = newTestVolume(vc)
+				c := &timedOutCreate{
+					kind: "snap",
+					create: func(ctx context.Context, name string) (string, error) {
+						resp, err := vc.SnapshotCreate(ctx, &api.SdkVolumeSnapshotCreateRequest{
+							VolumeId: volID,
+							Name:     name,
+						})
+						return resp.GetSnapshotId(), err
+					},
+					find: func(name string) (string, error) {
+						ctx := setContextWithToken(context.Background(), run.users["admin"])
+						resp, err := vc.SnapshotEnumerateWithFilters(ctx,
+							&api.SdkVolumeSnapshotEnumerateWithFiltersRequest{VolumeId: volID})
+						if err != nil {
+							return "", err
+						}
+						for _, id := range resp.GetVolumeSnapshotIds() {
+							snap, err := vc.Inspect(ctx, &api.SdkVolumeInspectRequest{VolumeId: id})
+							if err != nil {
+								return notFoundIsEmpty("", err)
+							}
+							if snap.GetVolume().GetLocator().GetName() == name {
+								return id, nil
+							}
+						}
+						return "", nil
+					},
+					remove: removeVolume,
+				}
+				c.check()
+			})
+		})
+
+		Describe("Schedule policies [OpenStorageSchedulePolicy]", func() {
+			var sc api.OpenStorageSchedulePolicyClient
+
+			BeforeEach(func() {
+				skipUnsupportedService("OpenStorageSchedulePolicy")
+				sc = api.NewOpenStorageSchedulePolicyClient(run.conn)
+			})
+
+			It("should not leave a schedule policy behind", func() {
+				c := &timedOutCreate{
+					kind: "policy",
+					create: func(ctx context.Context, name string) (string, error) {
+						_, err := sc.Create(ctx, &api.SdkSchedulePolicyCreateRequest{
+							SchedulePolicy: &api.SdkSchedulePolicy{
+								Name: name,
+								Schedules: []*api.SdkSchedulePolicyInterval{
+									&api.SdkSchedulePolicyInterval{
+										Retain: 2,
+										PeriodType: &api.SdkSchedulePolicyInterval_Daily{
+											Daily: &api.SdkSchedulePolicyIntervalDaily{
+												Hour:   12,
+												Minute: 30,
+											},
+										},
+									},
+								},
+							},
+						})
+						return name, err
+					},
+					find: func(name string) (string, error) {
+						_, err := sc.Inspect(
+							setContextWithToken(context.Background(), run.users["admin"]),
+							&api.SdkSchedulePolicyInspectRequest{Name: name},
+						)
+						return notFoundIsEmpty(name, err)
+					},
+					remove: func(name string) error {
+						_, err := sc.Delete(
+							setContextWithToken(context.Background(), run.users["admin"]),
+							&api.SdkSchedulePolicyDeleteRequest{Name: name},
+						)
+						return err
+					},
+				}
+				c.check()
+			})
+		})
+
+		Describe("Roles [OpenStorageRole]"+sdkVersionTag("OpenStorageRole"), func() {
+			var rc api.OpenStorageRoleClient
+
+			BeforeEach(func() {
+				skipUnsupportedService("OpenStorageRole")
+				rc = api.NewOpenStorageRoleClient(run.conn)
+			})
+
+			It("should not leave a role behind", func() {
+				c := &timedOutCreate{
+					kind: "role",
+					create: func(ctx context.Context, name string) (string, error) {
+						_, err := rc.Create(ctx, &api.SdkRoleCreateRequest{
+							Role: &api.SdkRole{
+								Name: name,
+								Rules: []*api.SdkRule{
+									&api.SdkRule{
+										Services: []string{"identity"},
+										Apis:     []string{"*"},
+									},
+								},
+							},
+						})
+						return name, err
+					},
+					find: func(name string) (string, error) {
+						_, err := rc.Inspect(
+							setContextWithToken(context.Background(), run.users["admin"]),
+							&api.SdkRoleInspectRequest{Name: name},
+						)
+						return notFoundIsEmpty(name, err)
+					},
+					remove: func(name string) error {
+						_, err := rc.Delete(
+							setContextWithToken(context.Background(), run.users["admin"]),
+							&api.SdkRoleDeleteRequest{Name: name},
+						)
+						return err
+					},
+				}
+				c.check()
+			})
+		})
+
+		Describe("Cloud backups [OpenStorageCloudBackup]", func() {
+			var (
+				bc  api.OpenStorageCloudBackupClient
+				vol *backupVolume
+			)
+
+			BeforeEach(func() {
+				skipUnlessCloudBackup(ic)
+				bc = api.NewOpenStorageCloudBackupClient(run.conn)
+				vol = nil
+			})
+
+			AfterEach(func() {
+				if vol != nil {
+					vol.delete(vc)
+				}
+			})
+
+			It("should not leave a backup behind unless it is created again with the same task id", func() {
+				vol = newBackupVolume(vc)
+				for provider, credID := range vol.credIDs {
+					credID := credID
+					By("Doing Backup on " + provider)
+					c := &timedOutCreate{
+						kind:       "task",
+						idempotent: true,
+						create: func(ctx context.Context, taskID string) (string, error) {
+							resp, err := bc.Create(ctx, &api.SdkCloudBackupCreateRequest{
+								VolumeId:     vol.volID,
+								CredentialId: credID,
+								TaskId:       taskID,
+							})
+							return resp.GetTaskId(), err
+						},
+						find: func(taskID string) (string, error) {
+							resp, err := bc.Status(
+								setContextWithToken(context.Background(), run.users["admin"]),
+								&api.SdkCloudBackupStatusRequest{TaskId: taskID},
+							)
+							if err != nil {
+								return notFoundIsEmpty("", err)
+							}
+							if _, ok := resp.GetStatuses()[taskID]; !ok {
+								return "", nil
+							}
+							return taskID, nil
+						},
+						// The backups are deleted with the volume
+						remove: func(taskID string) error {
+							waitForBackupTask(bc, taskID)
+							return nil
+						},
+					}
+					c.check()
+				}
+			})
+		})
+	})
+})
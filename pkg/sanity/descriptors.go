@@ -0,0 +1,170 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// sdkProtoFile is the file registered by the vendored SDK client
+const sdkProtoFile = "api.proto"
+
+// sdkMethod describes an RPC of the SDK, read from the descriptor of
+// api.proto. The generated service descriptors are not exported.
+type sdkMethod struct {
+	// service is the name of the service, like OpenStorageVolume
+	service string
+	// name is the name of the RPC, like Create
+	name string
+	// fullMethod is the gRPC method, like
+	// /openstorage.api.OpenStorageVolume/Create
+	fullMethod string
+	// input and output are the full names of the messages, like
+	// openstorage.api.SdkVolumeCreateRequest
+	input  string
+	output string
+
+	clientStreaming bool
+	serverStreaming bool
+
+	// http is the REST gateway route of the RPC, if any
+	http *annotations.HttpRule
+}
+
+// sdkDescriptors holds the parsed descriptor of api.proto
+type sdkDescriptors struct {
+	file     *descriptor.FileDescriptorProto
+	methods  []sdkMethod
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+}
+
+var (
+	sdkDescriptorsOnce  sync.Once
+	sdkDescriptorsCache *sdkDescriptors
+	sdkDescriptorsErr   error
+)
+
+// getSdkDescriptors returns the descriptors of the SDK, parsing them once
+func getSdkDescriptors() (*sdkDescriptors, error) {
+	sdkDescriptorsOnce.Do(func() {
+		sdkDescriptorsCache, sdkDescriptorsErr = parseSdkDescriptors()
+	})
+	return sdkDescriptorsCache, sdkDescriptorsErr
+}
+
+func parseSdkDescriptors() (*sdkDescriptors, error) {
+	gz := proto.FileDescriptor(sdkProtoFile)
+	if gz == nil {
+		return nil, fmt.Errorf("SDK file %s is not registered", sdkProtoFile)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decompress descriptor of %s: %v", sdkProtoFile, err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decompress descriptor of %s: %v", sdkProtoFile, err)
+	}
+	file := &descriptor.FileDescriptorProto{}
+	if err := proto.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("Unable to parse descriptor of %s: %v", sdkProtoFile, err)
+	}
+
+	d := &sdkDescriptors{
+		file:     file,
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+	}
+	pkg := file.GetPackage()
+	for _, m := range file.GetMessageType() {
+		d.addMessage(pkg, m)
+	}
+	for _, e := range file.GetEnumType() {
+		d.enums[pkg+"."+e.GetName()] = e
+	}
+
+	for _, s := range file.GetService() {
+		for _, m := range s.GetMethod() {
+			method := sdkMethod{
+				service:         s.GetName(),
+				name:            m.GetName(),
+				fullMethod:      fmt.Sprintf("/%s.%s/%s", pkg, s.GetName(), m.GetName()),
+				input:           strings.TrimPrefix(m.GetInputType(), "."),
+				output:          strings.TrimPrefix(m.GetOutputType(), "."),
+				clientStreaming: m.GetClientStreaming(),
+				serverStreaming: m.GetServerStreaming(),
+			}
+			if m.GetOptions() != nil && proto.HasExtension(m.GetOptions(), annotations.E_Http) {
+				ext, err := proto.GetExtension(m.GetOptions(), annotations.E_Http)
+				if err != nil {
+					return nil, fmt.Errorf("Unable to read HTTP route of %s: %v", method.fullMethod, err)
+				}
+				method.http = ext.(*annotations.HttpRule)
+			}
+			d.methods = append(d.methods, method)
+		}
+	}
+	return d, nil
+}
+
+func (d *sdkDescriptors) addMessage(prefix string, m *descriptor.DescriptorProto) {
+	name := prefix + "." + m.GetName()
+	d.messages[name] = m
+	for _, nested := range m.GetNestedType() {
+		d.addMessage(name, nested)
+	}
+	for _, e := range m.GetEnumType() {
+		d.enums[name+"."+e.GetName()] = e
+	}
+}
+
+// method returns the RPC with the gRPC method name
+func (d *sdkDescriptors) method(fullMethod string) (sdkMethod, bool) {
+	for _, m := range d.methods {
+		if m.fullMethod == fullMethod {
+			return m, true
+		}
+	}
+	return sdkMethod{}, false
+}
+
+// message returns the descriptor of a message, using its full name with or
+// without the leading dot
+func (d *sdkDescriptors) message(name string) (*descriptor.DescriptorProto, bool) {
+	m, ok := d.messages[strings.TrimPrefix(name, ".")]
+	return m, ok
+}
+
+// newMessage returns a new instance of the Go type of a message
+func newMessage(name string) (proto.Message, error) {
+	t := proto.MessageType(strings.TrimPrefix(name, "."))
+	if t == nil {
+		return nil, fmt.Errorf("Unknown message type %s", name)
+	}
+	return reflect.New(t.Elem()).Interface().(proto.Message), nil
+}
@@ -0,0 +1,566 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// errorCodeCase is a row of the error code matrix: a call to an RPC with a
+// bad input, and the code required by the SDK contract
+type errorCodeCase struct {
+	// method is the service and RPC, like OpenStorageVolume/Inspect
+	method  string
+	input   string
+	request func() proto.Message
+	code    codes.Code
+}
+
+// missingID returns an ID or name which does not exist in the cluster
+func missingID() string {
+	return genName("missing")
+}
+
+var errorCodeMatrix = []errorCodeCase{
+	// OpenStorageAlerts
+	{"OpenStorageAlerts/EnumerateWithFilters", "no queries", func() proto.Message {
+		return &api.SdkAlertsEnumerateWithFiltersRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageAlerts/Delete", "no queries", func() proto.Message {
+		return &api.SdkAlertsDeleteRequest{}
+	}, codes.InvalidArgument},
+
+	// OpenStorageRole
+	{"OpenStorageRole/Create", "no role", func() proto.Message {
+		return &api.SdkRoleCreateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageRole/Inspect", "an empty name", func() proto.Message {
+		return &api.SdkRoleInspectRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageRole/Inspect", "an unknown name", func() proto.Message {
+		return &api.SdkRoleInspectRequest{Name: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageRole/Delete", "an empty name", func() proto.Message {
+		return &api.SdkRoleDeleteRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageRole/Update", "no role", func() proto.Message {
+		return &api.SdkRoleUpdateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageRole/Update", "an unknown role", func() proto.Message {
+		return &api.SdkRoleUpdateRequest{
+			Role: &api.SdkRole{
+				Name: missingID(),
+				Rules: []*api.SdkRule{
+					&api.SdkRule{Services: []string{"identity"}, Apis: []string{"*"}},
+				},
+			},
+		}
+	}, codes.NotFound},
+
+	// OpenStorageClusterPair
+	{"OpenStorageClusterPair/Create", "no request", func() proto.Message {
+		return &api.SdkClusterPairCreateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageClusterPair/Inspect", "an unknown id", func() proto.Message {
+		return &api.SdkClusterPairInspectRequest{Id: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageClusterPair/Delete", "an empty cluster id", func() proto.Message {
+		return &api.SdkClusterPairDeleteRequest{}
+	}, codes.InvalidArgument},
+
+	// OpenStorageNode
+	{"OpenStorageNode/Inspect", "an empty node id", func() proto.Message {
+		return &api.SdkNodeInspectRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageNode/Inspect", "an unknown node id", func() proto.Message {
+		return &api.SdkNodeInspectRequest{NodeId: missingID()}
+	}, codes.NotFound},
+
+	// OpenStorageVolume
+	{"OpenStorageVolume/Create", "an empty name", func() proto.Message {
+		return &api.SdkVolumeCreateRequest{Spec: &api.VolumeSpec{Size: uint64(GIGABYTE)}}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/Create", "no spec", func() proto.Message {
+		return &api.SdkVolumeCreateRequest{Name: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/Clone", "an empty parent id", func() proto.Message {
+		return &api.SdkVolumeCloneRequest{Name: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/Clone", "an unknown parent id", func() proto.Message {
+		return &api.SdkVolumeCloneRequest{Name: missingID(), ParentId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageVolume/Delete", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeDeleteRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/Inspect", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeInspectRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/Inspect", "an unknown volume id", func() proto.Message {
+		return &api.SdkVolumeInspectRequest{VolumeId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageVolume/Update", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeUpdateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/Update", "an unknown volume id", func() proto.Message {
+		return &api.SdkVolumeUpdateRequest{
+			VolumeId: missingID(),
+			Labels:   map[string]string{"sdk-test": "update"},
+		}
+	}, codes.NotFound},
+	{"OpenStorageVolume/Stats", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeStatsRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/Stats", "an unknown volume id", func() proto.Message {
+		return &api.SdkVolumeStatsRequest{VolumeId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageVolume/CapacityUsage", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeCapacityUsageRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/CapacityUsage", "an unknown volume id", func() proto.Message {
+		return &api.SdkVolumeCapacityUsageRequest{VolumeId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageVolume/SnapshotCreate", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeSnapshotCreateRequest{Name: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/SnapshotCreate", "an unknown volume id", func() proto.Message {
+		return &api.SdkVolumeSnapshotCreateRequest{VolumeId: missingID(), Name: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageVolume/SnapshotRestore", "an empty snapshot id", func() proto.Message {
+		return &api.SdkVolumeSnapshotRestoreRequest{VolumeId: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/SnapshotRestore", "an unknown snapshot id", func() proto.Message {
+		return &api.SdkVolumeSnapshotRestoreRequest{VolumeId: missingID(), SnapshotId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageVolume/SnapshotEnumerate", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeSnapshotEnumerateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/SnapshotScheduleUpdate", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeSnapshotScheduleUpdateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageVolume/SnapshotScheduleUpdate", "an unknown volume id", func() proto.Message {
+		return &api.SdkVolumeSnapshotScheduleUpdateRequest{VolumeId: missingID()}
+	}, codes.NotFound},
+
+	// OpenStorageMountAttach
+	{"OpenStorageMountAttach/Attach", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeAttachRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageMountAttach/Attach", "an unknown volume id", func() proto.Message {
+		return &api.SdkVolumeAttachRequest{VolumeId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageMountAttach/Detach", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeDetachRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageMountAttach/Mount", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeMountRequest{MountPath: "/mnt"}
+	}, codes.InvalidArgument},
+	{"OpenStorageMountAttach/Mount", "an empty mount path", func() proto.Message {
+		return &api.SdkVolumeMountRequest{VolumeId: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageMountAttach/Mount", "an unknown volume id", func() proto.Message {
+		return &api.SdkVolumeMountRequest{VolumeId: missingID(), MountPath: "/mnt"}
+	}, codes.NotFound},
+	{"OpenStorageMountAttach/Unmount", "an empty volume id", func() proto.Message {
+		return &api.SdkVolumeUnmountRequest{MountPath: "/mnt"}
+	}, codes.InvalidArgument},
+	{"OpenStorageMountAttach/Unmount", "an empty mount path", func() proto.Message {
+		return &api.SdkVolumeUnmountRequest{VolumeId: missingID()}
+	}, codes.InvalidArgument},
+
+	// OpenStorageMigrate
+	{"OpenStorageMigrate/Start", "an empty cluster id", func() proto.Message {
+		return &api.SdkCloudMigrateStartRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageMigrate/Cancel", "no request", func() proto.Message {
+		return &api.SdkCloudMigrateCancelRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageMigrate/Status", "no request", func() proto.Message {
+		return &api.SdkCloudMigrateStatusRequest{}
+	}, codes.InvalidArgument},
+
+	// OpenStorageObjectstore
+	{"OpenStorageObjectstore/Inspect", "an empty objectstore id", func() proto.Message {
+		return &api.SdkObjectstoreInspectRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageObjectstore/Inspect", "an unknown objectstore id", func() proto.Message {
+		return &api.SdkObjectstoreInspectRequest{ObjectstoreId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageObjectstore/Create", "an empty volume id", func() proto.Message {
+		return &api.SdkObjectstoreCreateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageObjectstore/Create", "an unknown volume id", func() proto.Message {
+		return &api.SdkObjectstoreCreateRequest{VolumeId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageObjectstore/Delete", "an empty objectstore id", func() proto.Message {
+		return &api.SdkObjectstoreDeleteRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageObjectstore/Update", "an empty objectstore id", func() proto.Message {
+		return &api.SdkObjectstoreUpdateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageObjectstore/Update", "an unknown objectstore id", func() proto.Message {
+		return &api.SdkObjectstoreUpdateRequest{ObjectstoreId: missingID()}
+	}, codes.NotFound},
+
+	// OpenStorageCredentials
+	{"OpenStorageCredentials/Create", "no credentials", func() proto.Message {
+		return &api.SdkCredentialCreateRequest{Name: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageCredentials/Inspect", "an empty credential id", func() proto.Message {
+		return &api.SdkCredentialInspectRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageCredentials/Inspect", "an unknown credential id", func() proto.Message {
+		return &api.SdkCredentialInspectRequest{CredentialId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageCredentials/Delete", "an empty credential id", func() proto.Message {
+		return &api.SdkCredentialDeleteRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageCredentials/Validate", "an empty credential id", func() proto.Message {
+		return &api.SdkCredentialValidateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageCredentials/Validate", "an unknown credential id", func() proto.Message {
+		return &api.SdkCredentialValidateRequest{CredentialId: missingID()}
+	}, codes.NotFound},
+
+	// OpenStorageSchedulePolicy
+	{"OpenStorageSchedulePolicy/Create", "no policy", func() proto.Message {
+		return &api.SdkSchedulePolicyCreateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageSchedulePolicy/Update", "no policy", func() proto.Message {
+		return &api.SdkSchedulePolicyUpdateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageSchedulePolicy/Update", "an unknown policy", func() proto.Message {
+		return &api.SdkSchedulePolicyUpdateRequest{
+			SchedulePolicy: &api.SdkSchedulePolicy{
+				Name: missingID(),
+				Schedules: []*api.SdkSchedulePolicyInterval{
+					&api.SdkSchedulePolicyInterval{
+						Retain: 1,
+						PeriodType: &api.SdkSchedulePolicyInterval_Daily{
+							Daily: &api.SdkSchedulePolicyIntervalDaily{Hour: 1, Minute: 0},
+						},
+					},
+				},
+			},
+		}
+	}, codes.NotFound},
+	{"OpenStorageSchedulePolicy/Inspect", "an empty name", func() proto.Message {
+		return &api.SdkSchedulePolicyInspectRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageSchedulePolicy/Inspect", "an unknown name", func() proto.Message {
+		return &api.SdkSchedulePolicyInspectRequest{Name: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageSchedulePolicy/Delete", "an empty name", func() proto.Message {
+		return &api.SdkSchedulePolicyDeleteRequest{}
+	}, codes.InvalidArgument},
+
+	// OpenStorageCloudBackup
+	{"OpenStorageCloudBackup/Create", "an empty volume id", func() proto.Message {
+		return &api.SdkCloudBackupCreateRequest{CredentialId: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageCloudBackup/Create", "an unknown volume id", func() proto.Message {
+		return &api.SdkCloudBackupCreateRequest{VolumeId: missingID(), CredentialId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageCloudBackup/Restore", "an empty backup id", func() proto.Message {
+		return &api.SdkCloudBackupRestoreRequest{CredentialId: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageCloudBackup/Delete", "an empty backup id", func() proto.Message {
+		return &api.SdkCloudBackupDeleteRequest{CredentialId: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageCloudBackup/DeleteAll", "an empty volume id", func() proto.Message {
+		return &api.SdkCloudBackupDeleteAllRequest{CredentialId: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageCloudBackup/EnumerateWithFilters", "an unknown credential id", func() proto.Message {
+		return &api.SdkCloudBackupEnumerateWithFiltersRequest{CredentialId: missingID()}
+	}, codes.NotFound},
+	{"OpenStorageCloudBackup/Catalog", "an empty backup id", func() proto.Message {
+		return &api.SdkCloudBackupCatalogRequest{CredentialId: missingID()}
+	}, codes.InvalidArgument},
+	{"OpenStorageCloudBackup/StateChange", "an empty task id", func() proto.Message {
+		return &api.SdkCloudBackupStateChangeRequest{
+			RequestedState: api.SdkCloudBackupRequestedState_SdkCloudBackupRequestedStatePause,
+		}
+	}, codes.InvalidArgument},
+	{"OpenStorageCloudBackup/StateChange", "an unknown task id", func() proto.Message {
+		return &api.SdkCloudBackupStateChangeRequest{
+			TaskId:         missingID(),
+			RequestedState: api.SdkCloudBackupRequestedState_SdkCloudBackupRequestedStatePause,
+		}
+	}, codes.NotFound},
+	{"OpenStorageCloudBackup/SchedCreate", "no schedule", func() proto.Message {
+		return &api.SdkCloudBackupSchedCreateRequest{}
+	}, codes.InvalidArgument},
+	{"OpenStorageCloudBackup/SchedDelete", "an empty schedule id", func() proto.Message {
+		return &api.SdkCloudBackupSchedDeleteRequest{}
+	}, codes.InvalidArgument},
+}
+
+// errorCodeExemptions are the RPCs which are not in the matrix, and why.
+// They are listed as uncovered in the error code report.
+var errorCodeExemptions = map[string]string{
+	"OpenStorageClusterPair/ResetToken": "it has no input and changes the token of the cluster",
+
+	// The inputs of these RPCs are all optional filters, so no input is
+	// invalid
+	"OpenStorageIdentity/Capabilities":               "it has no input",
+	"OpenStorageIdentity/Version":                    "it has no input",
+	"OpenStorageCluster/InspectCurrent":              "it has no input",
+	"OpenStorageClusterPair/Enumerate":               "it has no input",
+	"OpenStorageClusterPair/GetToken":                "it has no input",
+	"OpenStorageNode/InspectCurrent":                 "it has no input",
+	"OpenStorageNode/Enumerate":                      "it has no input",
+	"OpenStorageRole/Enumerate":                      "it has no input",
+	"OpenStorageCredentials/Enumerate":               "it has no input",
+	"OpenStorageSchedulePolicy/Enumerate":            "it has no input",
+	"OpenStorageCloudBackup/SchedEnumerate":          "it has no input",
+	"OpenStorageVolume/Enumerate":                    "its inputs are optional filters",
+	"OpenStorageVolume/EnumerateWithFilters":         "its inputs are optional filters",
+	"OpenStorageVolume/SnapshotEnumerateWithFilters": "its inputs are optional filters",
+	"OpenStorageCloudBackup/Status":                  "its inputs are optional filters",
+	"OpenStorageCloudBackup/History":                 "its inputs are optional filters",
+}
+
+// serviceCapabilities maps the services of the SDK to their capability.
+// Services which are always available are not listed.
+var serviceCapabilities = map[string]api.SdkServiceCapability_OpenStorageService_Type{
+	"OpenStorageAlerts":         api.SdkServiceCapability_OpenStorageService_ALERTS,
+	"OpenStorageRole":           api.SdkServiceCapability_OpenStorageService_ROLE,
+	"OpenStorageClusterPair":    api.SdkServiceCapability_OpenStorageService_CLUSTER_PAIR,
+	"OpenStorageNode":           api.SdkServiceCapability_OpenStorageService_NODE,
+	"OpenStorageVolume":         api.SdkServiceCapability_OpenStorageService_VOLUME,
+	"OpenStorageMountAttach":    api.SdkServiceCapability_OpenStorageService_MOUNT_ATTACH,
+	"OpenStorageMigrate":        api.SdkServiceCapability_OpenStorageService_MIGRATE,
+	"OpenStorageObjectstore":    api.SdkServiceCapability_OpenStorageService_OBJECT_STORAGE,
+	"OpenStorageCredentials":    api.SdkServiceCapability_OpenStorageService_CREDENTIALS,
+	"OpenStorageSchedulePolicy": api.SdkServiceCapability_OpenStorageService_SCHEDULE_POLICY,
+	"OpenStorageCloudBackup":    api.SdkServiceCapability_OpenStorageService_CLOUD_BACKUP,
+}
+
+// skipUnsupportedService skips the test if the server does not support the
+// service, like OpenStorageVolume
+func skipUnsupportedService(service string) {
+	capability, ok := serviceCapabilities[service]
+	if ok && !isCapabilitySupported(api.NewOpenStorageIdentityClient(run.conn), capability) {
+		Skip(fmt.Sprintf("%s capability not supported", capability))
+	}
+}
+
+// invokeMethod calls an RPC of the SDK without a generated client. The first
+// response of server streaming RPCs is read to get their status.
+func invokeMethod(ctx context.Context, m sdkMethod, req proto.Message) (proto.Message, error) {
+	reply, err := newMessage(m.output)
+	if err != nil {
+		return nil, err
+	}
+	if !m.serverStreaming && !m.clientStreaming {
+		return reply, run.conn.Invoke(ctx, m.fullMethod, req, reply)
+	}
+
+	stream, err := run.conn.NewStream(ctx, &grpc.StreamDesc{
+		ServerStreams: m.serverStreaming,
+		ClientStreams: m.clientStreaming,
+	}, m.fullMethod)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	if err := stream.RecvMsg(reply); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// ErrorCodeDeviation is a call of the error code matrix which returned a
+// different code than the one required by the SDK
+type ErrorCodeDeviation struct {
+	Method   string `json:"method"`
+	Input    string `json:"input"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Message  string `json:"message,omitempty"`
+}
+
+// UncoveredMethod is an RPC which has no row in the error code matrix
+type UncoveredMethod struct {
+	Method string `json:"method"`
+	Reason string `json:"reason"`
+}
+
+// ErrorCodeReport is the report saved by the error code matrix
+type ErrorCodeReport struct {
+	Deviations []ErrorCodeDeviation `json:"deviations"`
+	Uncovered  []UncoveredMethod    `json:"uncovered"`
+}
+
+func (r *sanityRun) recordErrorCodeMatrixRan() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.errorCodeMatrixRan = true
+}
+
+func (r *sanityRun) recordErrorCodeDeviation(d ErrorCodeDeviation) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.errorCodeDeviations = append(r.errorCodeDeviations, d)
+}
+
+// reportErrorCodeDeviations prints the deviations found by the error code
+// matrix and the RPCs it does not cover, and saves them to the error code
+// report if one was requested
+func (r *sanityRun) reportErrorCodeDeviations(w io.Writer) error {
+	r.lock.Lock()
+	ran := r.errorCodeMatrixRan
+	deviations := append([]ErrorCodeDeviation{}, r.errorCodeDeviations...)
+	r.lock.Unlock()
+	if !ran {
+		return nil
+	}
+
+	sort.Slice(deviations, func(i, j int) bool {
+		if deviations[i].Method != deviations[j].Method {
+			return deviations[i].Method < deviations[j].Method
+		}
+		return deviations[i].Input < deviations[j].Input
+	})
+	if len(deviations) != 0 {
+		fmt.Fprintf(w, "\n%d calls returned error codes which deviate from the SDK:\n", len(deviations))
+		for _, d := range deviations {
+			fmt.Fprintf(w, "  %s with %s: expected %s, got %s\n", d.Method, d.Input, d.Expected, d.Actual)
+		}
+	}
+
+	uncovered := make([]UncoveredMethod, 0, len(errorCodeExemptions))
+	for method, reason := range errorCodeExemptions {
+		uncovered = append(uncovered, UncoveredMethod{Method: method, Reason: reason})
+	}
+	sort.Slice(uncovered, func(i, j int) bool {
+		return uncovered[i].Method < uncovered[j].Method
+	})
+	fmt.Fprintf(w, "\n%d RPCs are not covered by the error code matrix:\n", len(uncovered))
+	for _, u := range uncovered {
+		fmt.Fprintf(w, "  %s: %s\n", u.Method, u.Reason)
+	}
+
+	if len(r.config.ErrorCodeReport) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(ErrorCodeReport{
+		Deviations: deviations,
+		Uncovered:  uncovered,
+	}, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(reportFilename(r.config.ErrorCodeReport), data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to write error code report %s: %v", r.config.ErrorCodeReport, err)
+	}
+	return nil
+}
+
+var _ = Describe("Error codes [ErrorCodes]", func() {
+
+	It("should cover every RPC of the SDK", func() {
+		d, err := getSdkDescriptors()
+		Expect(err).NotTo(HaveOccurred())
+
+		covered := make(map[string]bool)
+		for _, c := range errorCodeMatrix {
+			covered[c.method] = true
+		}
+		missing := []string{}
+		for _, m := range d.methods {
+			name := m.service + "/" + m.name
+			if _, exempt := errorCodeExemptions[name]; !covered[name] && !exempt {
+				missing = append(missing, name)
+			}
+		}
+		Expect(missing).To(BeEmpty(), "RPCs missing from the error code matrix")
+	})
+
+	// The rows of each service are grouped so that they can be selected with
+	// the tag of the service
+	services := []string{}
+	rows := make(map[string][]errorCodeCase)
+	for _, c := range errorCodeMatrix {
+		service := strings.Split(c.method, "/")[0]
+		if _, ok := rows[service]; !ok {
+			services = append(services, service)
+		}
+		rows[service] = append(rows[service], c)
+	}
+
+	for _, service := range services {
+		service := service
+		cases := rows[service]
+
+		Describe(fmt.Sprintf("%s [%s]", service, service), func() {
+			BeforeEach(func() {
+				skipUnsupportedService(service)
+				run.recordErrorCodeMatrixRan()
+			})
+
+			for _, c := range cases {
+				c := c
+
+				It(fmt.Sprintf("%s with %s should return %v%s", c.method, c.input, c.code, sdkVersionTag(c.method)), func() {
+					d, err := getSdkDescriptors()
+					Expect(err).NotTo(HaveOccurred())
+					m, ok := d.method("/openstorage.api." + c.method)
+					Expect(ok).To(BeTrue(), "Unknown RPC %s", c.method)
+
+					ctx, cancel := context.WithTimeout(
+						setContextWithToken(context.Background(), run.users["admin"]),
+						30*time.Second)
+					defer cancel()
+					_, err = invokeMethod(ctx, m, c.request())
+
+					s := status.Convert(err)
+					if s.Code() != c.code {
+						run.recordErrorCodeDeviation(ErrorCodeDeviation{
+							Method:   c.method,
+							Input:    c.input,
+							Expected: c.code.String(),
+							Actual:   s.Code().String(),
+							Message:  s.Message(),
+						})
+					}
+					Expect(s.Code()).To(Equal(c.code), "Message: %s", s.Message())
+				})
+			}
+		})
+	}
+})
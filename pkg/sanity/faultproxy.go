@@ -0,0 +1,273 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// faults are the network problems injected by the proxy
+type faults struct {
+	// latency delays every chunk of data forwarded, in both directions
+	latency time.Duration
+	// bandwidth limits each direction of a connection, in bytes per
+	// second. Zero is unlimited.
+	bandwidth int64
+	// halfOpen drops the connections to the server without telling the
+	// client, which gets no answer to its calls
+	halfOpen bool
+	// resetEvery resets all the connections periodically
+	resetEvery time.Duration
+}
+
+// parseFaults reads faults like latency=100ms,bandwidth=65536,half-open=true
+// or reset-every=1m
+func parseFaults(script string) (faults, error) {
+	f := faults{}
+	for _, item := range strings.Split(script, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return f, fmt.Errorf("Fault %q must be like name=value", item)
+		}
+		var err error
+		switch kv[0] {
+		case "latency":
+			f.latency, err = time.ParseDuration(kv[1])
+		case "bandwidth":
+			f.bandwidth, err = strconv.ParseInt(kv[1], 10, 64)
+			if err == nil && f.bandwidth < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		case "half-open":
+			f.halfOpen, err = strconv.ParseBool(kv[1])
+		case "reset-every":
+			f.resetEvery, err = time.ParseDuration(kv[1])
+		default:
+			return f, fmt.Errorf("Unknown fault %q, must be latency, bandwidth, half-open or reset-every", kv[0])
+		}
+		if err != nil {
+			return f, fmt.Errorf("Invalid fault %s: %v", item, err)
+		}
+	}
+	return f, nil
+}
+
+// endpointNetwork returns the network and address to dial an endpoint, which
+// is a unix socket when it is a path
+func endpointNetwork(address string) (string, string) {
+	u, err := url.Parse(address)
+	if err == nil && (!u.IsAbs() || u.Scheme == "unix") {
+		return "unix", u.Path
+	}
+	return "tcp", address
+}
+
+// proxyConn is a connection of a client and its connection to the server
+type proxyConn struct {
+	client net.Conn
+	server net.Conn
+	// halfOpen is set when the connection to the server was dropped
+	// without closing the client
+	halfOpen bool
+}
+
+// faultProxy forwards TCP connections to the server, injecting faults. The
+// faults can be changed while connections are open.
+type faultProxy struct {
+	target   string
+	listener net.Listener
+
+	lock   sync.Mutex
+	faults faults
+	conns  map[*proxyConn]bool
+	done   chan struct{}
+}
+
+// newFaultProxy starts a proxy to the endpoint on a local port
+func newFaultProxy(target string, f faults) (*faultProxy, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &faultProxy{
+		target:   target,
+		listener: l,
+		faults:   f,
+		conns:    make(map[*proxyConn]bool),
+		done:     make(chan struct{}),
+	}
+	go p.accept()
+	go p.resetPeriodically()
+	return p, nil
+}
+
+// address returns the address the clients connect to
+func (p *faultProxy) address() string {
+	return p.listener.Addr().String()
+}
+
+func (p *faultProxy) getFaults() faults {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.faults
+}
+
+// setFaults changes the faults. Making the connections half-open drops the
+// connections to the server.
+func (p *faultProxy) setFaults(f faults) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.faults = f
+	if f.halfOpen {
+		for c := range p.conns {
+			if !c.halfOpen {
+				c.halfOpen = true
+				c.server.Close()
+			}
+		}
+	}
+}
+
+// reset closes all the connections. The clients get a TCP reset instead of
+// the normal end of the connection.
+func (p *faultProxy) reset() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for c := range p.conns {
+		if tcp, ok := c.client.(*net.TCPConn); ok {
+			tcp.SetLinger(0)
+		}
+		c.client.Close()
+		if c.server != nil {
+			c.server.Close()
+		}
+		delete(p.conns, c)
+	}
+}
+
+// close stops the proxy and closes its connections
+func (p *faultProxy) close() {
+	close(p.done)
+	p.listener.Close()
+	p.reset()
+}
+
+func (p *faultProxy) resetPeriodically() {
+	last := time.Now()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+		if f := p.getFaults(); f.resetEvery > 0 && time.Since(last) >= f.resetEvery {
+			p.reset()
+			last = time.Now()
+		}
+	}
+}
+
+func (p *faultProxy) accept() {
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.handle(client)
+	}
+}
+
+func (p *faultProxy) handle(client net.Conn) {
+	c := &proxyConn{client: client}
+
+	// New connections are accepted but never answered while half-open
+	if p.getFaults().halfOpen {
+		p.lock.Lock()
+		c.halfOpen = true
+		p.conns[c] = true
+		p.lock.Unlock()
+		io.Copy(ioutil.Discard, client)
+		return
+	}
+
+	network, address := endpointNetwork(p.target)
+	server, err := net.DialTimeout(network, address, 30*time.Second)
+	if err != nil {
+		client.Close()
+		return
+	}
+	c.server = server
+	p.lock.Lock()
+	p.conns[c] = true
+	p.lock.Unlock()
+
+	go p.pump(c, server, client, true)
+	p.pump(c, client, server, false)
+}
+
+// pump forwards the data from src to dst with the faults of the proxy. When
+// the connection becomes half-open the data of the client is discarded and
+// the client is not closed.
+func (p *faultProxy) pump(c *proxyConn, dst, src net.Conn, fromClient bool) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			f := p.getFaults()
+			if f.latency > 0 {
+				time.Sleep(f.latency)
+			}
+			if _, werr := dst.Write(buf[:n]); werr != nil && err == nil {
+				err = werr
+			}
+			if f.bandwidth > 0 {
+				time.Sleep(time.Duration(int64(n) * int64(time.Second) / f.bandwidth))
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	p.lock.Lock()
+	halfOpen := c.halfOpen
+	p.lock.Unlock()
+	if halfOpen {
+		if fromClient {
+			io.Copy(ioutil.Discard, c.client)
+		}
+		return
+	}
+
+	p.lock.Lock()
+	delete(p.conns, c)
+	p.lock.Unlock()
+	c.client.Close()
+	c.server.Close()
+}
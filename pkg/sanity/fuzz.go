@@ -0,0 +1,433 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	fuzzTag = "[Fuzz]"
+
+	defaultFuzzIterations = 100
+	defaultFuzzTimeout    = 30 * time.Second
+	defaultFuzzDir        = "fuzz-findings"
+
+	// fuzzMaxDepth limits the nesting of the messages generated
+	fuzzMaxDepth = 4
+)
+
+// fuzzExclusions are the RPCs which are not fuzzed, and why
+var fuzzExclusions = map[string]string{
+	"OpenStorageClusterPair/ResetToken": "it changes the token of the cluster",
+	"OpenStorageClusterPair/Create":     "it connects to the remote cluster in the request",
+	"OpenStorageAlerts/Delete":          "it deletes the alerts of the cluster matching the request",
+}
+
+// fuzzDestructive are the RPCs which delete or move data. Random requests,
+// like an empty ID or "*", could match the resources of other users of the
+// cluster, so they are only fuzzed with --sdk.fuzz-destructive.
+var fuzzDestructive = map[string]bool{
+	"OpenStorageVolume/Delete":           true,
+	"OpenStorageCloudBackup/Delete":      true,
+	"OpenStorageCloudBackup/DeleteAll":   true,
+	"OpenStorageCloudBackup/SchedDelete": true,
+	"OpenStorageSchedulePolicy/Delete":   true,
+	"OpenStorageRole/Delete":             true,
+	"OpenStorageCredentials/Delete":      true,
+	"OpenStorageClusterPair/Delete":      true,
+	"OpenStorageMigrate/Start":           true,
+	"OpenStorageMigrate/Cancel":          true,
+	"OpenStorageObjectstore/Delete":      true,
+}
+
+// fuzzStrings are the strings tried besides random ones
+var fuzzStrings = []string{
+	"",
+	" ",
+	"*",
+	"/",
+	"../../../../etc/passwd",
+	"%s%s%s%n",
+	"\x00",
+	"\u00e9\u4e2d\u6587\U0001F600",
+	"'; DROP TABLE volumes; --",
+	"-1",
+	"9223372036854775808",
+	strings.Repeat("a", 4096),
+}
+
+// fuzzer builds random requests which are valid for the types of api.proto
+type fuzzer struct {
+	d    *sdkDescriptors
+	rand *rand.Rand
+}
+
+// fuzzSeed returns the seed of the RPC, so that its requests do not depend on
+// the order in which the RPCs are fuzzed
+func fuzzSeed(seed int64, fullMethod string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(fullMethod))
+	return seed ^ int64(h.Sum64())
+}
+
+func newFuzzer(d *sdkDescriptors, seed int64) *fuzzer {
+	return &fuzzer{
+		d:    d,
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// request returns a random request of the RPC
+func (f *fuzzer) request(m sdkMethod) (proto.Message, error) {
+	data, err := json.Marshal(f.message(m.input, 0))
+	if err != nil {
+		return nil, err
+	}
+	msg, err := newMessage(m.input)
+	if err != nil {
+		return nil, err
+	}
+	if err := jsonpb.Unmarshal(bytes.NewReader(data), msg); err != nil {
+		return nil, fmt.Errorf("Generated an invalid %s: %v: %s", m.input, err, data)
+	}
+	return msg, nil
+}
+
+// message returns the JSON object of a random message. Each field is set
+// with some probability, and at most one field of each oneof.
+func (f *fuzzer) message(name string, depth int) map[string]interface{} {
+	fields := make(map[string]interface{})
+	msg, ok := f.d.message(name)
+	if !ok {
+		return fields
+	}
+
+	// The oneofs are a list so that the values only depend on the seed
+	oneofs := make([][]*descriptor.FieldDescriptorProto, len(msg.GetOneofDecl()))
+	for _, field := range msg.GetField() {
+		if field.OneofIndex != nil {
+			oneofs[field.GetOneofIndex()] = append(oneofs[field.GetOneofIndex()], field)
+			continue
+		}
+		if f.rand.Intn(10) < 7 {
+			if value := f.field(field, depth); value != nil {
+				fields[field.GetName()] = value
+			}
+		}
+	}
+	for _, choices := range oneofs {
+		if len(choices) != 0 && f.rand.Intn(10) < 8 {
+			field := choices[f.rand.Intn(len(choices))]
+			if value := f.value(field, depth); value != nil {
+				fields[field.GetName()] = value
+			}
+		}
+	}
+	return fields
+}
+
+// field returns the value of a field, which is a list or a map when the
+// field is repeated
+func (f *fuzzer) field(field *descriptor.FieldDescriptorProto, depth int) interface{} {
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return f.value(field, depth)
+	}
+
+	n := f.rand.Intn(4)
+	if entry, ok := f.d.message(field.GetTypeName()); ok && entry.GetOptions().GetMapEntry() {
+		// Map keys are always strings in JSON
+		values := make(map[string]interface{})
+		for i := 0; i < n; i++ {
+			key, value := entry.GetField()[0], entry.GetField()[1]
+			if v := f.value(value, depth); v != nil {
+				values[jsonString(f.value(key, depth))] = v
+			}
+		}
+		return values
+	}
+
+	values := []interface{}{}
+	for i := 0; i < n; i++ {
+		if v := f.value(field, depth); v != nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// value returns a single random value of the type of the field, in the JSON
+// form accepted by jsonpb. It returns nil for messages nested too deep.
+func (f *fuzzer) value(field *descriptor.FieldDescriptorProto, depth int) interface{} {
+	r := f.rand
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return f.string()
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		data := make([]byte, r.Intn(64))
+		r.Read(data)
+		return base64.StdEncoding.EncodeToString(data)
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return r.Intn(2) == 0
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return pickInt64(r, 0, 1, -1, math.MaxInt32, math.MinInt32, int64(r.Int31()))
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return pickInt64(r, 0, 1, math.MaxUint32, int64(r.Uint32()))
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		// 64 bit integers are strings in JSON
+		return strconv.FormatInt(pickInt64(r, 0, 1, -1, math.MaxInt64, math.MinInt64, r.Int63()), 10)
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		values := []uint64{0, 1, math.MaxUint64, uint64(r.Int63())}
+		return strconv.FormatUint(values[r.Intn(len(values))], 10)
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		values := []float64{0, -1, math.MaxFloat64, r.NormFloat64() * 1e6}
+		return values[r.Intn(len(values))]
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		values := []float64{0, -1, math.MaxFloat32, r.NormFloat64() * 1e3}
+		return values[r.Intn(len(values))]
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		// Unknown values are valid in proto3
+		enum := f.d.enums[strings.TrimPrefix(field.GetTypeName(), ".")]
+		if enum == nil || r.Intn(10) == 0 {
+			return 1000 + r.Intn(1000)
+		}
+		return enum.GetValue()[r.Intn(len(enum.GetValue()))].GetName()
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if field.GetTypeName() == ".google.protobuf.Timestamp" {
+			// jsonpb only accepts the years 1 to 9999
+			t := time.Unix(r.Int63n(253402300799), int64(r.Intn(1e9)))
+			return t.UTC().Format(time.RFC3339Nano)
+		}
+		if depth >= fuzzMaxDepth {
+			return nil
+		}
+		return f.message(field.GetTypeName(), depth+1)
+	}
+	return nil
+}
+
+func pickInt64(r *rand.Rand, values ...int64) int64 {
+	return values[r.Intn(len(values))]
+}
+
+// string returns a name which does not exist, a random string, or one of
+// the fuzzStrings
+func (f *fuzzer) string() string {
+	switch f.rand.Intn(3) {
+	case 0:
+		// Like genName, but repeatable with the seed
+		return fmt.Sprintf("%s-%s-fuzz-%06x", resourcePrefix, run.id, f.rand.Intn(1<<24))
+	case 1:
+		const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.:/=,"
+		s := make([]byte, 1+f.rand.Intn(32))
+		for i := range s {
+			s[i] = chars[f.rand.Intn(len(chars))]
+		}
+		return string(s)
+	}
+	return fuzzStrings[f.rand.Intn(len(fuzzStrings))]
+}
+
+// FuzzFinding is a request which crashed, hung, or made the server return
+// codes.Internal or codes.Unknown. It is saved as a JSON reproducer.
+type FuzzFinding struct {
+	Method    string          `json:"method"`
+	Seed      int64           `json:"seed"`
+	Iteration int             `json:"iteration"`
+	Problem   string          `json:"problem"`
+	Code      string          `json:"code"`
+	Message   string          `json:"message"`
+	Request   json.RawMessage `json:"request"`
+}
+
+// fuzzProblem returns the problem found by a call, if any
+func fuzzProblem(ctx context.Context, err error) string {
+	s := status.Convert(err)
+	switch {
+	case s.Code() == codes.DeadlineExceeded && ctx.Err() == context.DeadlineExceeded:
+		return "the server did not answer in time"
+	case s.Code() == codes.Unavailable:
+		return "the connection was dropped"
+	case s.Code() == codes.Internal, s.Code() == codes.Unknown:
+		return fmt.Sprintf("the server returned %v", s.Code())
+	}
+	return ""
+}
+
+// saveFuzzFinding writes the reproducer of a finding to the fuzz directory
+// and returns its path
+func saveFuzzFinding(dir string, m sdkMethod, finding FuzzFinding) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(finding, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dir,
+		fmt.Sprintf("%s-%s-%s-%d.json", run.id, m.service, m.name, finding.Iteration))
+	return filename, ioutil.WriteFile(filename, data, 0644)
+}
+
+// fuzzMethod sends random requests to the RPC and returns the paths of the
+// reproducers of the findings. Fuzzing stops when the connection is dropped,
+// since the calls which follow would fail as well.
+func fuzzMethod(m sdkMethod, c *SanityConfiguration, seed int64) ([]string, error) {
+	iterations, timeout, dir := c.FuzzIterations, c.FuzzTimeout, c.FuzzDir
+	if iterations <= 0 {
+		iterations = defaultFuzzIterations
+	}
+	if timeout <= 0 {
+		timeout = defaultFuzzTimeout
+	}
+	if len(dir) == 0 {
+		dir = defaultFuzzDir
+	}
+
+	d, err := getSdkDescriptors()
+	if err != nil {
+		return nil, err
+	}
+	f := newFuzzer(d, fuzzSeed(seed, m.fullMethod))
+	marshaler := jsonpb.Marshaler{OrigName: true}
+
+	reproducers := []string{}
+	for i := 0; i < iterations; i++ {
+		req, err := f.request(m)
+		if err != nil {
+			return reproducers, err
+		}
+
+		ctx, cancel := context.WithTimeout(
+			setContextWithToken(context.Background(), run.users["admin"]),
+			timeout)
+		_, err = invokeMethod(ctx, m, req)
+		problem := fuzzProblem(ctx, err)
+		cancel()
+		if len(problem) == 0 {
+			continue
+		}
+
+		data, err2 := marshaler.MarshalToString(req)
+		if err2 != nil {
+			return reproducers, err2
+		}
+		s := status.Convert(err)
+		filename, err2 := saveFuzzFinding(dir, m, FuzzFinding{
+			Method:    m.fullMethod,
+			Seed:      seed,
+			Iteration: i,
+			Problem:   problem,
+			Code:      s.Code().String(),
+			Message:   s.Message(),
+			Request:   json.RawMessage(data),
+		})
+		if err2 != nil {
+			return reproducers, fmt.Errorf("Unable to save reproducer: %v", err2)
+		}
+		reproducers = append(reproducers, fmt.Sprintf("%s: %s", filename, problem))
+		if s.Code() == codes.Unavailable {
+			break
+		}
+	}
+	return reproducers, nil
+}
+
+// The fuzz specs only run with --sdk.fuzz, and the other specs are skipped
+// then. The RPCs are nested in their service so that --sdk.services can
+// select them.
+var _ = Describe("Fuzz "+fuzzTag, func() {
+	d, err := getSdkDescriptors()
+	if err != nil {
+		panic(err)
+	}
+
+	services := []string{}
+	methods := make(map[string][]sdkMethod)
+	for _, m := range d.methods {
+		if _, ok := methods[m.service]; !ok {
+			services = append(services, m.service)
+		}
+		methods[m.service] = append(methods[m.service], m)
+	}
+
+	for _, service := range services {
+		service := service
+		list := methods[service]
+
+		Describe(fmt.Sprintf("%s [%s]", service, service), func() {
+			BeforeEach(func() {
+				skipUnsupportedService(service)
+			})
+
+			// Random creates may succeed. Their resources are in the ledger
+			// and are deleted after each RPC, so that they are not reported
+			// as left behind by the tests.
+			AfterEach(func() {
+				_, err := run.sweepLedger()
+				Expect(err).NotTo(HaveOccurred(), "Unable to delete the resources created by random %s requests", service)
+			})
+
+			for _, m := range list {
+				m := m
+
+				It(fmt.Sprintf("should handle random %s requests%s", m.name, sdkVersionTag(service+"/"+m.name)), func() {
+					if reason, ok := fuzzExclusions[m.service+"/"+m.name]; ok {
+						Skip("Not fuzzed because " + reason)
+					}
+					if fuzzDestructive[m.service+"/"+m.name] && !run.config.FuzzDestructive {
+						Skip("Not fuzzed because it deletes or moves data, enable with --sdk.fuzz-destructive")
+					}
+
+					reproducers, err := fuzzMethod(m, run.config, run.fuzzSeed)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(reproducers).To(BeEmpty(),
+						"Requests to %s failed, replay them with seed %d:\n%s",
+						m.fullMethod, run.fuzzSeed, strings.Join(reproducers, "\n"))
+				})
+			}
+		})
+	}
+})
@@ -0,0 +1,475 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// beOneOfCodes succeeds if the gRPC code is one of the codes
+func beOneOfCodes(list ...codes.Code) OmegaMatcher {
+	return WithTransform(func(c codes.Code) bool {
+		for _, code := range list {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}, BeTrue())
+}
+
+// repeatedDeletes deletes a resource three times. The first delete must
+// succeed, and the others must return the same code, either OK or NotFound.
+func repeatedDeletes(kind string, del func() error) {
+	Expect(del()).NotTo(HaveOccurred(), "First delete of the %s", kind)
+
+	second := status.Code(del())
+	Expect(second).To(beOneOfCodes(codes.OK, codes.NotFound),
+		"Deleting a deleted %s returned %v", kind, second)
+	third := status.Code(del())
+	Expect(third).To(Equal(second),
+		"Deleting a deleted %s returned %v, then %v", kind, second, third)
+}
+
+var _ = Describe("Idempotency [Idempotency]", func() {
+	var (
+		ic  api.OpenStorageIdentityClient
+		vc  api.OpenStorageVolumeClient
+		ctx context.Context
+	)
+
+	BeforeEach(func() {
+		ic = api.NewOpenStorageIdentityClient(run.conn)
+		vc = api.NewOpenStorageVolumeClient(run.conn)
+		ctx = setContextWithToken(context.Background(), run.users["admin"])
+	})
+
+	Describe("Volumes [OpenStorageVolume]", func() {
+		var volIDs []string
+
+		BeforeEach(func() {
+			skipUnsupportedService("OpenStorageVolume")
+			volIDs = nil
+		})
+
+		AfterEach(func() {
+			// Clones are deleted before their parent
+			for i := len(volIDs) - 1; i >= 0; i-- {
+				Expect(deleteVol(ctx, vc, volIDs[i])).NotTo(HaveOccurred())
+			}
+		})
+
+		newVolumeRequest := func(name string, size uint64) *api.SdkVolumeCreateRequest {
+			return &api.SdkVolumeCreateRequest{
+				Name: name,
+				Spec: &api.VolumeSpec{
+					Size:    size,
+					HaLevel: 1,
+					Format:  api.FSType_FS_TYPE_EXT4,
+				},
+			}
+		}
+
+		It("should return the same volume when it is created again with the same name and spec", func() {
+			req := newVolumeRequest(genName("vol"), uint64(5*GIGABYTE))
+			first, err := vc.Create(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			volIDs = append(volIDs, first.GetVolumeId())
+
+			second, err := vc.Create(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			if id := second.GetVolumeId(); len(id) != 0 && id != first.GetVolumeId() {
+				volIDs = append(volIDs, id)
+			}
+			Expect(second.GetVolumeId()).To(Equal(first.GetVolumeId()))
+
+			By("checking that there is a single volume with the name")
+			Expect(findVolumeByName(vc, req.GetName())).To(Equal(first.GetVolumeId()))
+			enumResp, err := vc.EnumerateWithFilters(ctx, &api.SdkVolumeEnumerateWithFiltersRequest{
+				Name: req.GetName(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(enumResp.GetVolumeIds()).To(ConsistOf(first.GetVolumeId()))
+		})
+
+		It("should return AlreadyExists when a volume is created again with the same name and another spec", func() {
+			req := newVolumeRequest(genName("vol"), uint64(5*GIGABYTE))
+			first, err := vc.Create(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			volIDs = append(volIDs, first.GetVolumeId())
+
+			_, err = vc.Create(ctx, newVolumeRequest(req.GetName(), uint64(10*GIGABYTE)))
+			Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+
+			By("checking that the volume was not changed")
+			inspectResp, err := vc.Inspect(ctx, &api.SdkVolumeInspectRequest{
+				VolumeId: first.GetVolumeId(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inspectResp.GetVolume().GetSpec().GetSize()).To(BeEquivalentTo(5 * GIGABYTE))
+		})
+
+		It("should return the same clone when it is created again with the same name and parent", func() {
+			parentID := newTestVolume(vc)
+			volIDs = append(volIDs, parentID)
+
+			req := &api.SdkVolumeCloneRequest{
+				Name:     genName("clone"),
+				ParentId: parentID,
+			}
+			first, err := vc.Clone(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			volIDs = append(volIDs, first.GetVolumeId())
+
+			second, err := vc.Clone(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			if id := second.GetVolumeId(); len(id) != 0 && id != first.GetVolumeId() {
+				volIDs = append(volIDs, id)
+			}
+			Expect(second.GetVolumeId()).To(Equal(first.GetVolumeId()))
+		})
+
+		It("should return AlreadyExists when a clone is created again with the same name and another parent", func() {
+			parentID := newTestVolume(vc)
+			otherParentID := newTestVolume(vc)
+			volIDs = append(volIDs, parentID, otherParentID)
+
+			name := genName("clone")
+			first, err := vc.Clone(ctx, &api.SdkVolumeCloneRequest{
+				Name:     name,
+				ParentId: parentID,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			volIDs = append(volIDs, first.GetVolumeId())
+
+			_, err = vc.Clone(ctx, &api.SdkVolumeCloneRequest{
+				Name:     name,
+				ParentId: otherParentID,
+			})
+			Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+		})
+
+		It("should return OK for every delete of a volume", func() {
+			volID := newTestVolume(vc)
+			for i := 0; i < 3; i++ {
+				_, err := vc.Delete(ctx, &api.SdkVolumeDeleteRequest{VolumeId: volID})
+				Expect(err).NotTo(HaveOccurred(), "Delete %d of the volume", i+1)
+			}
+		})
+
+		It("should delete a snapshot consistently when it is deleted again", func() {
+			volID := newTestVolume(vc)
+			volIDs = append(volIDs, volID)
+
+			snapResp, err := vc.SnapshotCreate(ctx, &api.SdkVolumeSnapshotCreateRequest{
+				VolumeId: volID,
+				Name:     genName("snap"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			repeatedDeletes("snapshot", func() error {
+				_, err := vc.Delete(ctx, &api.SdkVolumeDeleteRequest{VolumeId: snapResp.GetSnapshotId()})
+				return err
+			})
+		})
+	})
+
+	Describe("Schedule policies [OpenStorageSchedulePolicy]", func() {
+		var sc api.OpenStorageSchedulePolicyClient
+
+		BeforeEach(func() {
+			skipUnsupportedService("OpenStorageSchedulePolicy")
+			sc = api.NewOpenStorageSchedulePolicyClient(run.conn)
+		})
+
+		It("should delete a schedule policy consistently when it is deleted again", func() {
+			name := genName("policy")
+			_, err := sc.Create(ctx, &api.SdkSchedulePolicyCreateRequest{
+				SchedulePolicy: &api.SdkSchedulePolicy{
+					Name: name,
+					Schedules: []*api.SdkSchedulePolicyInterval{
+						&api.SdkSchedulePolicyInterval{
+							Retain: 2,
+							PeriodType: &api.SdkSchedulePolicyInterval_Daily{
+								Daily: &api.SdkSchedulePolicyIntervalDaily{
+									Hour:   12,
+									Minute: 30,
+								},
+							},
+						},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			repeatedDeletes("schedule policy", func() error {
+				_, err := sc.Delete(ctx, &api.SdkSchedulePolicyDeleteRequest{Name: name})
+				return err
+			})
+		})
+	})
+
+	Describe("Roles [OpenStorageRole]"+sdkVersionTag("OpenStorageRole"), func() {
+		var rc api.OpenStorageRoleClient
+
+		BeforeEach(func() {
+			skipUnsupportedService("OpenStorageRole")
+			rc = api.NewOpenStorageRoleClient(run.conn)
+		})
+
+		It("should delete a role consistently when it is deleted again", func() {
+			name := genName("role")
+			_, err := rc.Create(ctx, &api.SdkRoleCreateRequest{
+				Role: &api.SdkRole{
+					Name: name,
+					Rules: []*api.SdkRule{
+						&api.SdkRule{
+							Services: []string{"identity"},
+							Apis:     []string{"*"},
+						},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			repeatedDeletes("role", func() error {
+				_, err := rc.Delete(ctx, &api.SdkRoleDeleteRequest{Name: name})
+				return err
+			})
+		})
+	})
+
+	Describe("Cloud backups [OpenStorageCloudBackup]", func() {
+		var (
+			bc         api.OpenStorageCloudBackupClient
+			vols       []*backupVolume
+			restoreIDs []string
+		)
+
+		BeforeEach(func() {
+			skipUnlessCloudBackup(ic)
+			bc = api.NewOpenStorageCloudBackupClient(run.conn)
+			vols = nil
+			restoreIDs = nil
+		})
+
+		AfterEach(func() {
+			for _, id := range restoreIDs {
+				Expect(deleteVol(ctx, vc, id)).NotTo(HaveOccurred())
+			}
+			for _, vol := range vols {
+				vol.delete(vc)
+			}
+		})
+
+		It("should return the same task when a backup is created again with the same task id", func() {
+			vol := newBackupVolume(vc)
+			vols = append(vols, vol)
+
+			for provider, credID := range vol.credIDs {
+				By("Doing Backup on " + provider)
+				req := &api.SdkCloudBackupCreateRequest{
+					VolumeId:     vol.volID,
+					CredentialId: credID,
+					TaskId:       genName("task"),
+				}
+				first, err := bc.Create(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(first.GetTaskId()).To(Equal(req.GetTaskId()))
+
+				second, err := bc.Create(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(second.GetTaskId()).To(Equal(req.GetTaskId()))
+				waitForBackupTask(bc, req.GetTaskId())
+
+				By("checking that the task made a single backup")
+				enumResp, err := bc.EnumerateWithFilters(ctx, &api.SdkCloudBackupEnumerateWithFiltersRequest{
+					SrcVolumeId:  vol.volID,
+					CredentialId: credID,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(enumResp.GetBackups()).To(HaveLen(1))
+			}
+		})
+
+		It("should return AlreadyExists when a backup of another volume is created with the same task id", func() {
+			vol := newBackupVolume(vc)
+			other := newBackupVolume(vc)
+			vols = append(vols, vol, other)
+
+			for provider, credID := range vol.credIDs {
+				By("Doing Backup on " + provider)
+				taskID := genName("task")
+				_, err := bc.Create(ctx, &api.SdkCloudBackupCreateRequest{
+					VolumeId:     vol.volID,
+					CredentialId: credID,
+					TaskId:       taskID,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = bc.Create(ctx, &api.SdkCloudBackupCreateRequest{
+					VolumeId:     other.volID,
+					CredentialId: other.credIDs[provider],
+					TaskId:       taskID,
+				})
+				Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+				waitForBackupTask(bc, taskID)
+			}
+		})
+
+		It("should return the same restore when it is started again with the same task id", func() {
+			vol := newBackupVolume(vc)
+			vols = append(vols, vol)
+
+			for provider, credID := range vol.credIDs {
+				By("Doing Backup on " + provider)
+				backup, err := bc.Create(ctx, &api.SdkCloudBackupCreateRequest{
+					VolumeId:     vol.volID,
+					CredentialId: credID,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				waitForBackupTask(bc, backup.GetTaskId())
+				backupID := getVolumeBackupId(bc, vol.volID, credID)
+
+				By("restoring the backup twice with the same task id")
+				req := &api.SdkCloudBackupRestoreRequest{
+					BackupId:          backupID,
+					RestoreVolumeName: genName("vol"),
+					CredentialId:      credID,
+					TaskId:            genName("task"),
+				}
+				first, err := bc.Restore(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				restoreIDs = append(restoreIDs, first.GetRestoreVolumeId())
+				Expect(first.GetTaskId()).To(Equal(req.GetTaskId()))
+
+				second, err := bc.Restore(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+				if id := second.GetRestoreVolumeId(); len(id) != 0 && id != first.GetRestoreVolumeId() {
+					restoreIDs = append(restoreIDs, id)
+				}
+				Expect(second.GetTaskId()).To(Equal(req.GetTaskId()))
+				Expect(second.GetRestoreVolumeId()).To(Equal(first.GetRestoreVolumeId()))
+				waitForBackupTask(bc, req.GetTaskId())
+
+				By("restoring the backup to another volume with the same task id")
+				otherName := genName("vol")
+				_, err = bc.Restore(ctx, &api.SdkCloudBackupRestoreRequest{
+					BackupId:          backupID,
+					RestoreVolumeName: otherName,
+					CredentialId:      credID,
+					TaskId:            req.GetTaskId(),
+				})
+				Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+				Expect(findVolumeByName(vc, otherName)).To(BeEmpty())
+			}
+		})
+
+		It("should delete a backup consistently when it is deleted again", func() {
+			vol := newBackupVolume(vc)
+			vols = append(vols, vol)
+
+			for provider, credID := range vol.credIDs {
+				By("Doing Backup on " + provider)
+				backup, err := bc.Create(ctx, &api.SdkCloudBackupCreateRequest{
+					VolumeId:     vol.volID,
+					CredentialId: credID,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				waitForBackupTask(bc, backup.GetTaskId())
+				backupID := getVolumeBackupId(bc, vol.volID, credID)
+
+				repeatedDeletes("cloud backup", func() error {
+					_, err := bc.Delete(ctx, &api.SdkCloudBackupDeleteRequest{
+						BackupId:     backupID,
+						CredentialId: credID,
+					})
+					return err
+				})
+			}
+		})
+	})
+
+	Describe("Migrations [OpenStorageMigrate]", func() {
+		var (
+			mc     api.OpenStorageMigrateClient
+			volIDs []string
+		)
+
+		BeforeEach(func() {
+			skipUnsupportedService("OpenStorageMigrate")
+			mc = api.NewOpenStorageMigrateClient(run.conn)
+			volIDs = nil
+		})
+
+		AfterEach(func() {
+			for _, id := range volIDs {
+				Expect(deleteVol(ctx, vc, id)).NotTo(HaveOccurred())
+			}
+		})
+
+		migrateVolume := func(clusterID, taskID, volID string) (*api.SdkCloudMigrateStartResponse, error) {
+			return mc.Start(ctx, &api.SdkCloudMigrateStartRequest{
+				ClusterId: clusterID,
+				TaskId:    taskID,
+				Opt: &api.SdkCloudMigrateStartRequest_Volume{
+					Volume: &api.SdkCloudMigrateStartRequest_MigrateVolume{
+						VolumeId: volID,
+					},
+				},
+			})
+		}
+
+		It("should return the same task when a migration is started again with the same task id", func() {
+			clusterID := getPairedClusterId()
+			volID := newTestVolume(vc)
+			volIDs = append(volIDs, volID)
+
+			taskID := genName("task")
+			first, err := migrateVolume(clusterID, taskID, volID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first.GetResult().GetTaskId()).To(Equal(taskID))
+
+			second, err := migrateVolume(clusterID, taskID, volID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second.GetResult().GetTaskId()).To(Equal(taskID))
+			waitForMigrateTask(mc, clusterID, taskID)
+		})
+
+		It("should return AlreadyExists when another volume is migrated with the same task id", func() {
+			clusterID := getPairedClusterId()
+			volID := newTestVolume(vc)
+			otherID := newTestVolume(vc)
+			volIDs = append(volIDs, volID, otherID)
+
+			taskID := genName("task")
+			_, err := migrateVolume(clusterID, taskID, volID)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = migrateVolume(clusterID, taskID, otherID)
+			Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+			waitForMigrateTask(mc, clusterID, taskID)
+		})
+	})
+})
@@ -26,19 +26,19 @@ import (
 	. "github.com/onsi/gomega"
 )
 
-var _ = Describe("Identity Service", func() {
+var _ = Describe("Identity [OpenStorageIdentity]", func() {
 
 	var (
 		c api.OpenStorageIdentityClient
 	)
 
 	BeforeEach(func() {
-		c = api.NewOpenStorageIdentityClient(conn)
+		c = api.NewOpenStorageIdentityClient(run.conn)
 	})
 
 	Describe("Version", func() {
 		It("should return version information", func() {
-			res, err := c.Version(setContextWithToken(context.Background(), users["admin"]), &api.SdkIdentityVersionRequest{})
+			res, err := c.Version(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkIdentityVersionRequest{})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(res).NotTo(BeNil())
 			Expect(res.GetSdkVersion()).NotTo(BeNil())
@@ -60,12 +60,28 @@ var _ = Describe("Identity Service", func() {
 			Expect(res.GetVersion().GetDriver()).NotTo(BeEmpty())
 			Expect(res.GetVersion().GetVersion()).NotTo(BeEmpty())
 		})
+
+		It("should report an SDK version compatible with the client", func() {
+			res, err := c.Version(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkIdentityVersionRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.GetSdkVersion()).NotTo(BeNil())
+
+			By("Checking the major version matches the client")
+			Expect(res.GetSdkVersion().GetMajor()).To(BeEquivalentTo(clientSdkVersion.GetMajor()))
+
+			if sdkVersionCompare(res.GetSdkVersion(), clientSdkVersion) < 0 {
+				fmt.Fprintf(GinkgoWriter,
+					"Server SDK version %s is older than the client version %s, tests for newer APIs will be skipped\n",
+					sdkVersionString(res.GetSdkVersion()),
+					sdkVersionString(clientSdkVersion))
+			}
+		})
 	})
 
 	Describe("Capabilities", func() {
 		It("should return appropriate capabilities", func() {
 			req := &api.SdkIdentityCapabilitiesRequest{}
-			res, err := c.Capabilities(setContextWithToken(context.Background(), users["admin"]), req)
+			res, err := c.Capabilities(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(res).NotTo(BeNil())
 
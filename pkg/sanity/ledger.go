@@ -0,0 +1,236 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc"
+)
+
+// runIDLabel is added to the labels of the volumes, snapshots and cloud
+// backups created by the tests. Its value is the run ID.
+const runIDLabel = "sdk-test-run-id"
+
+type resourceKind string
+
+const (
+	kindCloudBackup    resourceKind = "cloud backups"
+	kindBackupSchedule resourceKind = "cloud backup schedule"
+	kindSnapshot       resourceKind = "snapshot"
+	kindVolume         resourceKind = "volume"
+	kindCredential     resourceKind = "credential"
+	kindSchedulePolicy resourceKind = "schedule policy"
+	kindRole           resourceKind = "role"
+)
+
+// sweepOrder is the order in which resources are deleted, so that no
+// resource is deleted while another one still depends on it
+var sweepOrder = []resourceKind{
+	kindCloudBackup,
+	kindBackupSchedule,
+	kindSnapshot,
+	kindVolume,
+	kindCredential,
+	kindSchedulePolicy,
+	kindRole,
+}
+
+// resource is a resource created by the tests. Cloud backups are tracked per
+// source volume and credential, since that is how they can be deleted.
+type resource struct {
+	kind resourceKind
+	id   string
+	// credentialID is the credential used by cloud backups
+	credentialID string
+}
+
+func (r resource) String() string {
+	if r.kind == kindCloudBackup {
+		return fmt.Sprintf("%s of volume %s with credential %s", r.kind, r.id, r.credentialID)
+	}
+	return fmt.Sprintf("%s %s", r.kind, r.id)
+}
+
+// ledger keeps the resources which were created by the run and have not
+// been deleted yet
+type ledger struct {
+	lock      sync.Mutex
+	resources map[resource]bool
+}
+
+func newLedger() *ledger {
+	return &ledger{
+		resources: make(map[resource]bool),
+	}
+}
+
+func (l *ledger) add(r resource) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.resources[r] = true
+}
+
+func (l *ledger) remove(r resource) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.resources, r)
+}
+
+// count returns the number of resources in the ledger
+func (l *ledger) count() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.resources)
+}
+
+// list returns the resources of a kind sorted by ID
+func (l *ledger) list(kind resourceKind) []resource {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	list := []resource{}
+	for r := range l.resources {
+		if r.kind == kind {
+			list = append(list, r)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].id != list[j].id {
+			return list[i].id < list[j].id
+		}
+		return list[i].credentialID < list[j].credentialID
+	})
+	return list
+}
+
+// record updates the ledger after a successful call
+func (l *ledger) record(req, reply interface{}) {
+	switch req := req.(type) {
+	case *api.SdkVolumeCreateRequest:
+		l.add(resource{kind: kindVolume, id: reply.(*api.SdkVolumeCreateResponse).GetVolumeId()})
+	case *api.SdkVolumeCloneRequest:
+		l.add(resource{kind: kindVolume, id: reply.(*api.SdkVolumeCloneResponse).GetVolumeId()})
+	case *api.SdkVolumeSnapshotCreateRequest:
+		l.add(resource{kind: kindSnapshot, id: reply.(*api.SdkVolumeSnapshotCreateResponse).GetSnapshotId()})
+	case *api.SdkCloudBackupRestoreRequest:
+		l.add(resource{kind: kindVolume, id: reply.(*api.SdkCloudBackupRestoreResponse).GetRestoreVolumeId()})
+	case *api.SdkVolumeDeleteRequest:
+		// Snapshots are deleted as volumes
+		l.remove(resource{kind: kindVolume, id: req.GetVolumeId()})
+		l.remove(resource{kind: kindSnapshot, id: req.GetVolumeId()})
+	case *api.SdkCloudBackupCreateRequest:
+		l.add(resource{kind: kindCloudBackup, id: req.GetVolumeId(), credentialID: req.GetCredentialId()})
+	case *api.SdkCloudBackupDeleteAllRequest:
+		l.remove(resource{kind: kindCloudBackup, id: req.GetSrcVolumeId(), credentialID: req.GetCredentialId()})
+	case *api.SdkCloudBackupSchedCreateRequest:
+		l.add(resource{kind: kindBackupSchedule, id: reply.(*api.SdkCloudBackupSchedCreateResponse).GetBackupScheduleId()})
+	case *api.SdkCloudBackupSchedDeleteRequest:
+		l.remove(resource{kind: kindBackupSchedule, id: req.GetBackupScheduleId()})
+	case *api.SdkCredentialCreateRequest:
+		l.add(resource{kind: kindCredential, id: reply.(*api.SdkCredentialCreateResponse).GetCredentialId()})
+	case *api.SdkCredentialDeleteRequest:
+		l.remove(resource{kind: kindCredential, id: req.GetCredentialId()})
+	case *api.SdkSchedulePolicyCreateRequest:
+		l.add(resource{kind: kindSchedulePolicy, id: req.GetSchedulePolicy().GetName()})
+	case *api.SdkSchedulePolicyDeleteRequest:
+		l.remove(resource{kind: kindSchedulePolicy, id: req.GetName()})
+	case *api.SdkRoleCreateRequest:
+		l.add(resource{kind: kindRole, id: req.GetRole().GetName()})
+	case *api.SdkRoleDeleteRequest:
+		l.remove(resource{kind: kindRole, id: req.GetName()})
+	}
+}
+
+// addRunIDLabel returns a copy of the request with the run ID label, for the
+// requests which support labels. Other requests are returned as is.
+func addRunIDLabel(req interface{}, id string) interface{} {
+	switch req := req.(type) {
+	case *api.SdkVolumeCreateRequest:
+		c := proto.Clone(req).(*api.SdkVolumeCreateRequest)
+		c.Labels = withRunIDLabel(c.Labels, id)
+		return c
+	case *api.SdkVolumeSnapshotCreateRequest:
+		c := proto.Clone(req).(*api.SdkVolumeSnapshotCreateRequest)
+		c.Labels = withRunIDLabel(c.Labels, id)
+		return c
+	case *api.SdkCloudBackupCreateRequest:
+		c := proto.Clone(req).(*api.SdkCloudBackupCreateRequest)
+		c.Labels = withRunIDLabel(c.Labels, id)
+		return c
+	}
+	return req
+}
+
+// withRunIDLabel adds the run ID label, unless the test set it already
+func withRunIDLabel(labels map[string]string, id string) map[string]string {
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	if _, ok := labels[runIDLabel]; !ok {
+		labels[runIDLabel] = id
+	}
+	return labels
+}
+
+// ledgerUnaryInterceptor labels the resources created by the tests with the
+// run ID and records them in the ledger of the run
+func (r *sanityRun) ledgerUnaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	req = addRunIDLabel(req, r.id)
+	if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+		return err
+	}
+	r.ledger.record(req, reply)
+	if del, ok := req.(*api.SdkCloudBackupDeleteRequest); ok {
+		r.pruneCloudBackups(ctx, cc, del.GetCredentialId())
+	}
+	return nil
+}
+
+// pruneCloudBackups removes from the ledger the cloud backups with the
+// credential which do not exist anymore. A backup deleted by its ID does not
+// tell which source volume it was taken from, so the ledger cannot remove
+// it directly like with DeleteAll.
+func (r *sanityRun) pruneCloudBackups(ctx context.Context, cc *grpc.ClientConn, credentialID string) {
+	s := &sweeper{
+		ctx:   ctx,
+		conn:  cc,
+		token: r.users["admin"],
+	}
+	for _, b := range r.ledger.list(kindCloudBackup) {
+		if b.credentialID != credentialID {
+			continue
+		}
+		// Backups which cannot be checked stay in the ledger, and are
+		// checked again when it is swept
+		if exists, err := s.exists(b); err == nil && !exists {
+			r.ledger.remove(b)
+		}
+	}
+}
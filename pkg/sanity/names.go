@@ -0,0 +1,58 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+)
+
+// resourcePrefix starts the name of every resource created by the suite
+const resourcePrefix = "sdk"
+
+var runIDRegexp = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// randomSuffix returns n random lowercase hex characters. crypto/rand is used
+// so that processes started at the same time do not generate the same names.
+func randomSuffix(n int) string {
+	b := make([]byte, (n+1)/2)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("Unable to generate a random name: %v", err))
+	}
+	return hex.EncodeToString(b)[:n]
+}
+
+// newRunID returns the ID used to prefix the resources of a run. A run ID set
+// in the configuration is used as given, so that several runs can share it.
+func newRunID(c *SanityConfiguration) (string, error) {
+	if len(c.RunID) == 0 {
+		return randomSuffix(8), nil
+	}
+	if !runIDRegexp.MatchString(c.RunID) {
+		return "", fmt.Errorf("Run ID %q must only contain lowercase letters and digits", c.RunID)
+	}
+	return c.RunID, nil
+}
+
+// genName returns a unique name for a resource of the given kind, like vol
+// or policy. Names look like sdk-<run id>-<kind>-<random> so that the
+// resources of concurrent runs sharing a cluster never collide.
+func genName(kind string) string {
+	return fmt.Sprintf("%s-%s-%s-%s", resourcePrefix, run.id, kind, randomSuffix(6))
+}
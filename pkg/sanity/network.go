@@ -0,0 +1,331 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	// faultCallTimeout is the deadline of the calls made through a faulty
+	// connection, so that a lost answer fails the call instead of hanging
+	faultCallTimeout = 30 * time.Second
+	// faultTaskTimeout is the time given to a long running task, like a
+	// cloud backup, to finish after its connection was reset
+	faultTaskTimeout = 10 * time.Minute
+)
+
+// faultContext returns a context with an admin token and a deadline
+func faultContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(setContextWithToken(context.Background(), run.users["admin"]), timeout)
+}
+
+// waitForServing waits until calls succeed again after the connection was
+// reset. gRPC fails the calls made while it reconnects.
+func waitForServing(ic api.OpenStorageIdentityClient) {
+	err := waitFor(time.Minute, time.Second, func() (bool, error) {
+		ctx, cancel := faultContext(5 * time.Second)
+		defer cancel()
+		_, err := ic.Version(ctx, &api.SdkIdentityVersionRequest{})
+		return err != nil, nil
+	})
+	Expect(err).NotTo(HaveOccurred(), "Server unreachable after the network faults were removed")
+}
+
+// retryUnavailable returns true for the errors of calls which should be
+// retried after a reset, and the other errors
+func retryUnavailable(err error) (bool, error) {
+	if status.Code(err) == codes.Unavailable {
+		return true, nil
+	}
+	return false, err
+}
+
+// waitForBackupTask waits until the cloud backup or restore task is done,
+// reconnecting when the connection was reset
+func waitForBackupTask(bc api.OpenStorageCloudBackupClient, taskID string) {
+	err := waitForCloudBackupTask(bc, taskID, faultTaskTimeout, func() (context.Context, context.CancelFunc) {
+		return faultContext(faultCallTimeout)
+	}, retryUnavailable)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// waitForMigrateTask waits until the migration task is complete,
+// reconnecting when the connection was reset
+func waitForMigrateTask(mc api.OpenStorageMigrateClient, clusterID, taskID string) {
+	err := waitFor(faultTaskTimeout, 5*time.Second, func() (bool, error) {
+		ctx, cancel := faultContext(faultCallTimeout)
+		defer cancel()
+		resp, err := mc.Status(ctx, &api.SdkCloudMigrateStatusRequest{
+			Request: &api.CloudMigrateStatusRequest{
+				TaskId:    taskID,
+				ClusterId: clusterID,
+			},
+		})
+		if err != nil {
+			return retryUnavailable(err)
+		}
+		found := false
+		for _, list := range resp.GetResult().GetInfo() {
+			for _, info := range list.GetList() {
+				if info.GetTaskId() != taskID {
+					continue
+				}
+				found = true
+				switch info.GetStatus() {
+				case api.CloudMigrate_Failed, api.CloudMigrate_Canceled:
+					return false, fmt.Errorf("Migration %s ended with status %s: %s",
+						taskID, info.GetStatus(), info.GetErrorReason())
+				case api.CloudMigrate_Complete:
+				default:
+					return true, nil
+				}
+			}
+		}
+		if !found {
+			return false, fmt.Errorf("Migration %s is unknown after the connection was reset", taskID)
+		}
+		return false, nil
+	})
+	Expect(err).NotTo(HaveOccurred())
+}
+
+var _ = Describe("Network faults [NetworkFaults]", func() {
+	var (
+		ic api.OpenStorageIdentityClient
+		vc api.OpenStorageVolumeClient
+	)
+
+	BeforeEach(func() {
+		if run.proxy == nil {
+			Skip("Network faults need --sdk.fault-proxy")
+		}
+		ic = api.NewOpenStorageIdentityClient(run.conn)
+		vc = api.NewOpenStorageVolumeClient(run.conn)
+	})
+
+	AfterEach(func() {
+		if run.proxy == nil {
+			return
+		}
+		run.proxy.setFaults(run.faults)
+		run.proxy.reset()
+		waitForServing(ic)
+	})
+
+	Describe("Deadlines", func() {
+
+		It("Should return DeadlineExceeded when the connection is half-open", func() {
+			By("dropping the connection to the server without telling the client")
+			run.proxy.setFaults(faults{halfOpen: true})
+
+			start := time.Now()
+			ctx, cancel := faultContext(2 * time.Second)
+			defer cancel()
+			_, err := ic.Version(ctx, &api.SdkIdentityVersionRequest{})
+			Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded))
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+		})
+
+		It("Should return DeadlineExceeded when the answer is later than the deadline", func() {
+			run.proxy.setFaults(faults{latency: 3 * time.Second})
+
+			ctx, cancel := faultContext(time.Second)
+			defer cancel()
+			_, err := ic.Version(ctx, &api.SdkIdentityVersionRequest{})
+			Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded))
+		})
+
+		It("Should answer through a slow connection", func() {
+			run.proxy.setFaults(faults{
+				latency:   200 * time.Millisecond,
+				bandwidth: 16 * 1024,
+			})
+
+			start := time.Now()
+			ctx, cancel := faultContext(faultCallTimeout)
+			defer cancel()
+			_, err := ic.Capabilities(ctx, &api.SdkIdentityCapabilitiesRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically(">=", 200*time.Millisecond))
+
+			By("creating and deleting a volume")
+			volID := newTestVolume(vc)
+			Expect(deleteVol(setContextWithToken(context.Background(), run.users["admin"]), vc, volID)).
+				NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("Resets", func() {
+
+		It("Should return Unavailable when the connection is reset during a call", func() {
+			run.proxy.setFaults(faults{latency: 2 * time.Second})
+
+			errs := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				ctx, cancel := faultContext(faultCallTimeout)
+				defer cancel()
+				_, err := ic.Version(ctx, &api.SdkIdentityVersionRequest{})
+				errs <- err
+			}()
+			time.Sleep(500 * time.Millisecond)
+
+			By("resetting the connection")
+			run.proxy.reset()
+			var err error
+			Eventually(errs, faultCallTimeout).Should(Receive(&err))
+			Expect(status.Code(err)).To(Equal(codes.Unavailable))
+
+			By("reconnecting")
+			run.proxy.setFaults(run.faults)
+			waitForServing(ic)
+		})
+	})
+
+	Describe("Cloud backup", func() {
+		var (
+			bc          api.OpenStorageCloudBackupClient
+			vol         *backupVolume
+			restoredIDs []string
+		)
+
+		BeforeEach(func() {
+			skipUnlessCloudBackup(ic)
+			bc = api.NewOpenStorageCloudBackupClient(run.conn)
+			vol = nil
+			restoredIDs = nil
+		})
+
+		AfterEach(func() {
+			// The connection is reset by the tests
+			run.proxy.setFaults(run.faults)
+			waitForServing(ic)
+
+			for _, id := range restoredIDs {
+				Expect(deleteVol(setContextWithToken(context.Background(), run.users["admin"]), vc, id)).
+					NotTo(HaveOccurred())
+			}
+			if vol != nil {
+				vol.delete(vc)
+			}
+		})
+
+		It("Should resume a backup and a restore by task id after a reset", func() {
+			By("creating and attaching a volume")
+			vol = newBackupVolume(vc)
+
+			for provider, credID := range vol.credIDs {
+				By("starting a backup on " + provider)
+				taskID := genName("task")
+				backup, err := bc.Create(
+					setContextWithToken(context.Background(), run.users["admin"]),
+					&api.SdkCloudBackupCreateRequest{
+						VolumeId:     vol.volID,
+						CredentialId: credID,
+						TaskId:       taskID,
+					},
+				)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(backup.GetTaskId()).To(Equal(taskID))
+
+				By("resetting the connection and following the backup by its task id")
+				run.proxy.reset()
+				waitForBackupTask(bc, taskID)
+
+				By("starting a restore on " + provider)
+				restoreTaskID := genName("task")
+				restore, err := bc.Restore(
+					setContextWithToken(context.Background(), run.users["admin"]),
+					&api.SdkCloudBackupRestoreRequest{
+						BackupId:          getVolumeBackupId(bc, vol.volID, credID),
+						RestoreVolumeName: genName("vol"),
+						CredentialId:      credID,
+						TaskId:            restoreTaskID,
+					},
+				)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(restore.GetTaskId()).To(Equal(restoreTaskID))
+				restoredIDs = append(restoredIDs, restore.GetRestoreVolumeId())
+
+				By("resetting the connection and following the restore by its task id")
+				run.proxy.reset()
+				waitForBackupTask(bc, restoreTaskID)
+			}
+		})
+	})
+
+	Describe("Migrate", func() {
+		var (
+			mc    api.OpenStorageMigrateClient
+			volID string
+		)
+
+		BeforeEach(func() {
+			if !isCapabilitySupported(ic, api.SdkServiceCapability_OpenStorageService_MIGRATE) {
+				Skip("Migrate capability not supported")
+			}
+			mc = api.NewOpenStorageMigrateClient(run.conn)
+			volID = ""
+		})
+
+		AfterEach(func() {
+			run.proxy.setFaults(run.faults)
+			waitForServing(ic)
+			if volID != "" {
+				Expect(deleteVol(setContextWithToken(context.Background(), run.users["admin"]), vc, volID)).
+					NotTo(HaveOccurred())
+			}
+		})
+
+		It("Should resume a migration by task id after a reset [OpenStorageMigrate]", func() {
+			clusterID := getPairedClusterId()
+
+			volID = newTestVolume(vc)
+
+			By("starting a migration")
+			taskID := genName("task")
+			resp, err := mc.Start(
+				setContextWithToken(context.Background(), run.users["admin"]),
+				&api.SdkCloudMigrateStartRequest{
+					ClusterId: clusterID,
+					TaskId:    taskID,
+					Opt: &api.SdkCloudMigrateStartRequest_Volume{
+						Volume: &api.SdkCloudMigrateStartRequest_MigrateVolume{
+							VolumeId: volID,
+						},
+					},
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetResult().GetTaskId()).To(Equal(taskID))
+
+			By("resetting the connection and following the migration by its task id")
+			run.proxy.reset()
+			waitForMigrateTask(mc, clusterID, taskID)
+		})
+	})
+})
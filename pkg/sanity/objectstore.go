@@ -18,8 +18,6 @@ package sanity
 
 import (
 	"context"
-	"fmt"
-	"time"
 
 	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
 	"google.golang.org/grpc/codes"
@@ -29,7 +27,7 @@ import (
 	. "github.com/onsi/gomega"
 )
 
-var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
+var _ = Describe("Objectstore Features [OpenStorageObjectstore]", func() {
 	var (
 		objClient api.OpenStorageObjectstoreClient
 		volClient api.OpenStorageVolumeClient
@@ -38,9 +36,9 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 	)
 
 	BeforeEach(func() {
-		objClient = api.NewOpenStorageObjectstoreClient(conn)
-		volClient = api.NewOpenStorageVolumeClient(conn)
-		ic = api.NewOpenStorageIdentityClient(conn)
+		objClient = api.NewOpenStorageObjectstoreClient(run.conn)
+		volClient = api.NewOpenStorageVolumeClient(run.conn)
+		ic = api.NewOpenStorageIdentityClient(run.conn)
 
 		isSupported := isCapabilitySupported(
 			ic,
@@ -55,7 +53,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 	AfterEach(func() {
 		if volID != "" {
 			err := deleteVol(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				volClient,
 				volID)
 			Expect(err).NotTo(HaveOccurred())
@@ -66,7 +64,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 		It("Should create objectstore with given volume ID", func() {
 			Skip("Not supported yet")
 			volReq := &api.SdkVolumeCreateRequest{
-				Name: "sdk-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					Shared:    false,
@@ -76,7 +74,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 					Format:    api.FSType_FS_TYPE_XFS,
 				},
 			}
-			volResp, err := volClient.Create(setContextWithToken(context.Background(), users["admin"]), volReq)
+			volResp, err := volClient.Create(setContextWithToken(context.Background(), run.users["admin"]), volReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(volResp).NotTo(BeNil())
 			Expect(volResp.VolumeId).NotTo(BeEmpty())
@@ -86,7 +84,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 			objReq := &api.SdkObjectstoreCreateRequest{
 				VolumeId: volID}
 
-			objResp, err := objClient.Create(setContextWithToken(context.Background(), users["admin"]), objReq)
+			objResp, err := objClient.Create(setContextWithToken(context.Background(), run.users["admin"]), objReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(objResp).NotTo(BeNil())
 			Expect(objResp.GetObjectstoreStatus().GetVolumeId()).NotTo(BeEmpty())
@@ -97,7 +95,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 		It("Should failed to create objectstore with empty volume ID", func() {
 			Skip("Not supported yet")
 			volReq := &api.SdkVolumeCreateRequest{
-				Name: fmt.Sprintf("sdk-vol-%v", time.Now().Unix()),
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					Shared:    false,
@@ -107,7 +105,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 					Format:    api.FSType_FS_TYPE_XFS,
 				},
 			}
-			volResp, err := volClient.Create(setContextWithToken(context.Background(), users["admin"]), volReq)
+			volResp, err := volClient.Create(setContextWithToken(context.Background(), run.users["admin"]), volReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(volResp).NotTo(BeNil())
 			Expect(volResp.VolumeId).NotTo(BeEmpty())
@@ -117,7 +115,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 			objReq := &api.SdkObjectstoreCreateRequest{
 				VolumeId: ""}
 
-			objResp, err := objClient.Create(setContextWithToken(context.Background(), users["admin"]), objReq)
+			objResp, err := objClient.Create(setContextWithToken(context.Background(), run.users["admin"]), objReq)
 			Expect(err).To(HaveOccurred())
 			Expect(objResp).To(BeNil())
 
@@ -131,7 +129,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 		It("Should update objectstore status (start/stop)", func() {
 			Skip("Not supported yet")
 			volReq := &api.SdkVolumeCreateRequest{
-				Name: "sdk-vol-test1",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					Shared:    false,
@@ -141,7 +139,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 					Format:    api.FSType_FS_TYPE_XFS,
 				},
 			}
-			volResp, err := volClient.Create(setContextWithToken(context.Background(), users["admin"]), volReq)
+			volResp, err := volClient.Create(setContextWithToken(context.Background(), run.users["admin"]), volReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(volResp).NotTo(BeNil())
 			Expect(volResp.VolumeId).NotTo(BeEmpty())
@@ -151,7 +149,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 			objReq := &api.SdkObjectstoreCreateRequest{
 				VolumeId: volID}
 
-			objResp, err := objClient.Create(setContextWithToken(context.Background(), users["admin"]), objReq)
+			objResp, err := objClient.Create(setContextWithToken(context.Background(), run.users["admin"]), objReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(objResp).NotTo(BeNil())
 			Expect(objResp.GetObjectstoreStatus().GetVolumeId()).NotTo(BeEmpty())
@@ -165,14 +163,14 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 				Enable:        true,
 			}
 
-			_, err = objClient.Update(setContextWithToken(context.Background(), users["admin"]), updateReq)
+			_, err = objClient.Update(setContextWithToken(context.Background(), run.users["admin"]), updateReq)
 			Expect(err).NotTo(HaveOccurred())
 
 			inspectReq := &api.SdkObjectstoreInspectRequest{
 				ObjectstoreId: objResp.GetObjectstoreStatus().GetUuid(),
 			}
 
-			inspectResp, err := objClient.Inspect(setContextWithToken(context.Background(), users["admin"]), inspectReq)
+			inspectResp, err := objClient.Inspect(setContextWithToken(context.Background(), run.users["admin"]), inspectReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(inspectResp).NotTo(BeNil())
 			Expect(inspectResp.GetObjectstoreStatus().GetUuid()).NotTo(BeEmpty())
@@ -186,7 +184,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 		It("Should delete objectstore with given UUID", func() {
 			Skip("Not supported yet")
 			volReq := &api.SdkVolumeCreateRequest{
-				Name: "sdk-vol-test1",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					Shared:    false,
@@ -196,7 +194,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 					Format:    api.FSType_FS_TYPE_XFS,
 				},
 			}
-			volResp, err := volClient.Create(setContextWithToken(context.Background(), users["admin"]), volReq)
+			volResp, err := volClient.Create(setContextWithToken(context.Background(), run.users["admin"]), volReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(volResp).NotTo(BeNil())
 			Expect(volResp.VolumeId).NotTo(BeEmpty())
@@ -206,7 +204,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 			objReq := &api.SdkObjectstoreCreateRequest{
 				VolumeId: volID}
 
-			objResp, err := objClient.Create(setContextWithToken(context.Background(), users["admin"]), objReq)
+			objResp, err := objClient.Create(setContextWithToken(context.Background(), run.users["admin"]), objReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(objResp).NotTo(BeNil())
 			Expect(objResp.GetObjectstoreStatus().GetVolumeId()).NotTo(BeEmpty())
@@ -218,7 +216,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 				ObjectstoreId: objResp.GetObjectstoreStatus().GetUuid(),
 			}
 
-			_, err = objClient.Delete(setContextWithToken(context.Background(), users["admin"]), deleteReq)
+			_, err = objClient.Delete(setContextWithToken(context.Background(), run.users["admin"]), deleteReq)
 			Expect(err).NotTo(HaveOccurred())
 
 			inspectReq := &api.SdkObjectstoreInspectRequest{
@@ -226,7 +224,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 			}
 
 			// Inspect should failed for given objectstore
-			inspectResp, err := objClient.Inspect(setContextWithToken(context.Background(), users["admin"]), inspectReq)
+			inspectResp, err := objClient.Inspect(setContextWithToken(context.Background(), run.users["admin"]), inspectReq)
 			Expect(err).To(HaveOccurred())
 			Expect(inspectResp).To(BeNil())
 		})
@@ -238,7 +236,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 				ObjectstoreId: "invalid",
 			}
 
-			_, err := objClient.Delete(setContextWithToken(context.Background(), users["admin"]), deleteReq)
+			_, err := objClient.Delete(setContextWithToken(context.Background(), run.users["admin"]), deleteReq)
 			Expect(err).To(HaveOccurred())
 
 			serverError, ok := status.FromError(err)
@@ -252,7 +250,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 		It("Should inspect objectstore with given UUID", func() {
 			Skip("Not supported yet")
 			volReq := &api.SdkVolumeCreateRequest{
-				Name: "sdk-vol-test1",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					Shared:    false,
@@ -262,7 +260,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 					Format:    api.FSType_FS_TYPE_XFS,
 				},
 			}
-			volResp, err := volClient.Create(setContextWithToken(context.Background(), users["admin"]), volReq)
+			volResp, err := volClient.Create(setContextWithToken(context.Background(), run.users["admin"]), volReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(volResp).NotTo(BeNil())
 			Expect(volResp.VolumeId).NotTo(BeEmpty())
@@ -272,7 +270,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 			objReq := &api.SdkObjectstoreCreateRequest{
 				VolumeId: volID}
 
-			objResp, err := objClient.Create(setContextWithToken(context.Background(), users["admin"]), objReq)
+			objResp, err := objClient.Create(setContextWithToken(context.Background(), run.users["admin"]), objReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(objResp).NotTo(BeNil())
 			Expect(objResp.GetObjectstoreStatus().GetVolumeId()).NotTo(BeEmpty())
@@ -284,7 +282,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 			}
 
 			// Inspect should failed for given objectstore
-			inspectResp, err := objClient.Inspect(setContextWithToken(context.Background(), users["admin"]), inspectReq)
+			inspectResp, err := objClient.Inspect(setContextWithToken(context.Background(), run.users["admin"]), inspectReq)
 			// verify inspect response with create response
 			Expect(err).NotTo(HaveOccurred())
 			Expect(inspectResp).NotTo(BeNil())
@@ -304,7 +302,7 @@ var _ = Describe("Objectstore Features[OpenStorageObjectstore]", func() {
 				}
 
 				// Inspect should failed for given objectstore
-				inspectResp, err := objClient.Inspect(setContextWithToken(context.Background(), users["admin"]), inspectReq)
+				inspectResp, err := objClient.Inspect(setContextWithToken(context.Background(), run.users["admin"]), inspectReq)
 				// verify inspect response with create response
 				Expect(err).To(HaveOccurred())
 				Expect(inspectResp).To(BeNil())
@@ -17,8 +17,6 @@ package sanity
 
 import (
 	"context"
-	"fmt"
-	"time"
 
 	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
 
@@ -29,7 +27,7 @@ import (
 	. "github.com/onsi/gomega"
 )
 
-var _ = Describe("Ownership Test Suite", func() {
+var _ = Describe("Ownership [OpenStorageVolume]"+sdkVersionTag("OpenStorageVolume/Ownership"), func() {
 
 	var (
 		vc       api.OpenStorageVolumeClient
@@ -37,16 +35,16 @@ var _ = Describe("Ownership Test Suite", func() {
 	)
 
 	BeforeEach(func() {
-		if len(config.SharedSecret) == 0 {
+		if len(run.config.SharedSecret) == 0 {
 			Skip("Not running with authentication")
 		}
-		vc = api.NewOpenStorageVolumeClient(conn)
+		vc = api.NewOpenStorageVolumeClient(run.conn)
 
 		By("creating a volume for user1")
 		respv, err := vc.Create(
-			setContextWithToken(context.Background(), users["user1"]),
+			setContextWithToken(context.Background(), run.users["user1"]),
 			&api.SdkVolumeCreateRequest{
-				Name: fmt.Sprintf("sdk-vol-%v", time.Now().Unix()),
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:    uint64(1 * GIGABYTE),
 					HaLevel: 1,
@@ -63,7 +61,7 @@ var _ = Describe("Ownership Test Suite", func() {
 	AfterEach(func() {
 		By("cleaning up volume for user1")
 		err := deleteVol(
-			setContextWithToken(context.Background(), users["user1"]),
+			setContextWithToken(context.Background(), run.users["user1"]),
 			vc,
 			user1vol)
 		Expect(err).NotTo(HaveOccurred())
@@ -72,7 +70,7 @@ var _ = Describe("Ownership Test Suite", func() {
 	It("should have ownership set in volume", func() {
 		By("checking it sets the ownership accordingly")
 		respInspectVol, err := vc.Inspect(
-			setContextWithToken(context.Background(), users["user1"]),
+			setContextWithToken(context.Background(), run.users["user1"]),
 			&api.SdkVolumeInspectRequest{
 				VolumeId: user1vol,
 			})
@@ -89,7 +87,7 @@ var _ = Describe("Ownership Test Suite", func() {
 
 		By("user2 unable to see the volume in Enumerate")
 		respEnum, err := vc.Enumerate(
-			setContextWithToken(context.Background(), users["user2"]),
+			setContextWithToken(context.Background(), run.users["user2"]),
 			&api.SdkVolumeEnumerateRequest{})
 		Expect(err).ToNot(HaveOccurred())
 		Expect(respEnum).NotTo(BeNil())
@@ -97,7 +95,7 @@ var _ = Describe("Ownership Test Suite", func() {
 
 		By("admin able to see the volume in Enumerate")
 		respEnum, err = vc.Enumerate(
-			setContextWithToken(context.Background(), users["admin"]),
+			setContextWithToken(context.Background(), run.users["admin"]),
 			&api.SdkVolumeEnumerateRequest{})
 		Expect(err).ToNot(HaveOccurred())
 		Expect(respEnum).NotTo(BeNil())
@@ -106,7 +104,7 @@ var _ = Describe("Ownership Test Suite", func() {
 		By("setting group access to 'users'")
 		// Owner setting value
 		_, err = vc.Update(
-			setContextWithToken(context.Background(), users["user1"]),
+			setContextWithToken(context.Background(), run.users["user1"]),
 			&api.SdkVolumeUpdateRequest{
 				VolumeId: user1vol,
 				Spec: &api.VolumeSpecUpdate{
@@ -120,7 +118,7 @@ var _ = Describe("Ownership Test Suite", func() {
 
 		By("checking it sets the ownership accordingly")
 		respInspectVol, err := vc.Inspect(
-			setContextWithToken(context.Background(), users["user1"]),
+			setContextWithToken(context.Background(), run.users["user1"]),
 			&api.SdkVolumeInspectRequest{
 				VolumeId: user1vol,
 			})
@@ -137,7 +135,7 @@ var _ = Describe("Ownership Test Suite", func() {
 
 		By("user2 able to see the volume in Enumerate")
 		respEnum, err = vc.Enumerate(
-			setContextWithToken(context.Background(), users["user2"]),
+			setContextWithToken(context.Background(), run.users["user2"]),
 			&api.SdkVolumeEnumerateRequest{})
 		Expect(err).ToNot(HaveOccurred())
 		Expect(respEnum).NotTo(BeNil())
@@ -145,7 +143,7 @@ var _ = Describe("Ownership Test Suite", func() {
 
 		By("admin able to see the volume in Enumerate")
 		respEnum, err = vc.Enumerate(
-			setContextWithToken(context.Background(), users["admin"]),
+			setContextWithToken(context.Background(), run.users["admin"]),
 			&api.SdkVolumeEnumerateRequest{})
 		Expect(err).ToNot(HaveOccurred())
 		Expect(respEnum).NotTo(BeNil())
@@ -154,7 +152,7 @@ var _ = Describe("Ownership Test Suite", func() {
 		By("user2 unable to update the volume")
 		// user2 is in group users but is not the owner
 		_, err = vc.Update(
-			setContextWithToken(context.Background(), users["user2"]),
+			setContextWithToken(context.Background(), run.users["user2"]),
 			&api.SdkVolumeUpdateRequest{
 				VolumeId: user1vol,
 				Spec: &api.VolumeSpecUpdate{
@@ -173,7 +171,7 @@ var _ = Describe("Ownership Test Suite", func() {
 		By("admin adding group access to 'others' group")
 		// Owner setting value
 		_, err = vc.Update(
-			setContextWithToken(context.Background(), users["admin"]),
+			setContextWithToken(context.Background(), run.users["admin"]),
 			&api.SdkVolumeUpdateRequest{
 				VolumeId: user1vol,
 				Spec: &api.VolumeSpecUpdate{
@@ -187,7 +185,7 @@ var _ = Describe("Ownership Test Suite", func() {
 
 		By("user1 checking it group 'others' was added")
 		respInspectVol, err = vc.Inspect(
-			setContextWithToken(context.Background(), users["user1"]),
+			setContextWithToken(context.Background(), run.users["user1"]),
 			&api.SdkVolumeInspectRequest{
 				VolumeId: user1vol,
 			})
@@ -0,0 +1,448 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// raceWorkers is the number of calls racing on the same resource
+const raceWorkers = 8
+
+// race calls f from n goroutines at the same time, and returns the error of
+// each call
+func race(n int, f func(i int) error) []error {
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer GinkgoRecover()
+			defer wg.Done()
+			<-start
+			errs[i] = f(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+// expectNoServerErrors checks that no call of a race failed with an error of
+// the server, instead of a code telling the client what happened
+func expectNoServerErrors(errs []error) {
+	for i, err := range errs {
+		Expect(status.Code(err)).NotTo(beOneOfCodes(codes.Internal, codes.Unknown),
+			"Call %d of the race failed: %v", i, err)
+	}
+}
+
+// winners returns the indexes of the calls which succeeded
+func winners(errs []error) []int {
+	list := []int{}
+	for i, err := range errs {
+		if err == nil {
+			list = append(list, i)
+		}
+	}
+	return list
+}
+
+// expectCodes checks that the calls which failed returned one of the codes
+func expectCodes(errs []error, allowed ...codes.Code) {
+	for i, err := range errs {
+		if err != nil {
+			Expect(status.Code(err)).To(beOneOfCodes(allowed...),
+				"Call %d of the race returned %v, expected one of %v", i, err, allowed)
+		}
+	}
+}
+
+var _ = Describe("Races [Races]", func() {
+	var (
+		vc  api.OpenStorageVolumeClient
+		ctx context.Context
+	)
+
+	BeforeEach(func() {
+		vc = api.NewOpenStorageVolumeClient(run.conn)
+		ctx = setContextWithToken(context.Background(), run.users["admin"])
+	})
+
+	Describe("Volumes [OpenStorageVolume]", func() {
+		var (
+			ma    api.OpenStorageMountAttachClient
+			volID string
+		)
+
+		BeforeEach(func() {
+			skipUnsupportedService("OpenStorageVolume")
+			ma = api.NewOpenStorageMountAttachClient(run.conn)
+			volID = ""
+		})
+
+		AfterEach(func() {
+			if volID != "" {
+				Expect(deleteVol(ctx, vc, volID)).NotTo(HaveOccurred())
+			}
+		})
+
+		It("should create a single volume when creates of the same name with different specs race", func() {
+			name := genName("vol")
+			ids := make([]string, raceWorkers)
+			errs := race(raceWorkers, func(i int) error {
+				resp, err := vc.Create(ctx, &api.SdkVolumeCreateRequest{
+					Name: name,
+					Spec: &api.VolumeSpec{
+						Size:    uint64(i+1) * GIGABYTE,
+						HaLevel: 1,
+						Format:  api.FSType_FS_TYPE_EXT4,
+					},
+				})
+				ids[i] = resp.GetVolumeId()
+				return err
+			})
+			expectNoServerErrors(errs)
+
+			won := winners(errs)
+			if len(won) != 0 {
+				volID = ids[won[0]]
+			}
+			Expect(won).To(HaveLen(1), "Creates which succeeded")
+			expectCodes(errs, codes.AlreadyExists)
+
+			By("checking that the volume has the spec of the winner")
+			Expect(findVolumeByName(vc, name)).To(Equal(volID))
+			inspectResp, err := vc.Inspect(ctx, &api.SdkVolumeInspectRequest{VolumeId: volID})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inspectResp.GetVolume().GetSpec().GetSize()).To(BeEquivalentTo(uint64(won[0]+1) * GIGABYTE))
+		})
+
+		It("should delete a volume once when deletes race", func() {
+			id := newTestVolume(vc)
+			errs := race(raceWorkers, func(i int) error {
+				_, err := vc.Delete(ctx, &api.SdkVolumeDeleteRequest{VolumeId: id})
+				return err
+			})
+			expectNoServerErrors(errs)
+			// Deleting a volume which does not exist succeeds
+			Expect(winners(errs)).To(HaveLen(raceWorkers))
+
+			_, err := vc.Inspect(ctx, &api.SdkVolumeInspectRequest{VolumeId: id})
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+
+		It("should keep the labels of one update when updates race", func() {
+			volID = newTestVolume(vc)
+			errs := race(raceWorkers, func(i int) error {
+				_, err := vc.Update(ctx, &api.SdkVolumeUpdateRequest{
+					VolumeId: volID,
+					Labels: map[string]string{
+						"race-writer": fmt.Sprintf("%d", i),
+					},
+				})
+				return err
+			})
+			expectNoServerErrors(errs)
+			won := winners(errs)
+			Expect(won).NotTo(BeEmpty(), "Updates which succeeded")
+
+			By("checking that the label was set by an update which succeeded")
+			inspectResp, err := vc.Inspect(ctx, &api.SdkVolumeInspectRequest{VolumeId: volID})
+			Expect(err).NotTo(HaveOccurred())
+			values := make([]string, len(won))
+			for i, w := range won {
+				values[i] = fmt.Sprintf("%d", w)
+			}
+			Expect(values).To(ContainElement(inspectResp.GetVolume().GetLocator().GetVolumeLabels()["race-writer"]))
+		})
+
+		It("should delete a volume when a delete races with updates", func() {
+			id := newTestVolume(vc)
+			errs := race(raceWorkers, func(i int) error {
+				if i == 0 {
+					_, err := vc.Delete(ctx, &api.SdkVolumeDeleteRequest{VolumeId: id})
+					return err
+				}
+				_, err := vc.Update(ctx, &api.SdkVolumeUpdateRequest{
+					VolumeId: id,
+					Labels: map[string]string{
+						"race-writer": fmt.Sprintf("%d", i),
+					},
+				})
+				return err
+			})
+			expectNoServerErrors(errs)
+			Expect(errs[0]).NotTo(HaveOccurred(), "Delete of the volume")
+			// Updates after the delete find no volume
+			expectCodes(errs, codes.NotFound)
+
+			_, err := vc.Inspect(ctx, &api.SdkVolumeInspectRequest{VolumeId: id})
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+
+		It("should attach a volume to a single device when attaches race", func() {
+			volID = newTestVolume(vc)
+			paths := make([]string, raceWorkers)
+			errs := race(raceWorkers, func(i int) error {
+				resp, err := ma.Attach(ctx, &api.SdkVolumeAttachRequest{VolumeId: volID})
+				paths[i] = resp.GetDevicePath()
+				return err
+			})
+			expectNoServerErrors(errs)
+			won := winners(errs)
+			Expect(won).NotTo(BeEmpty(), "Attaches which succeeded")
+			for _, w := range won {
+				Expect(paths[w]).To(Equal(paths[won[0]]), "Device path of attach %d", w)
+			}
+
+			_, err := ma.Detach(ctx, &api.SdkVolumeDetachRequest{
+				VolumeId: volID,
+				Options: &api.SdkVolumeDetachOptions{
+					UnmountBeforeDetach: true,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should leave a volume attached or detached when attaches and detaches race", func() {
+			volID = newTestVolume(vc)
+			errs := race(raceWorkers, func(i int) error {
+				if i%2 == 0 {
+					_, err := ma.Attach(ctx, &api.SdkVolumeAttachRequest{VolumeId: volID})
+					return err
+				}
+				_, err := ma.Detach(ctx, &api.SdkVolumeDetachRequest{
+					VolumeId: volID,
+					Options: &api.SdkVolumeDetachOptions{
+						UnmountBeforeDetach: true,
+					},
+				})
+				return err
+			})
+			expectNoServerErrors(errs)
+
+			// Attaches have even indexes and detaches odd ones
+			attached, detached := false, false
+			for _, i := range winners(errs) {
+				if i%2 == 0 {
+					attached = true
+				} else {
+					detached = true
+				}
+			}
+
+			By("checking the state of the volume")
+			inspectResp, err := vc.Inspect(ctx, &api.SdkVolumeInspectRequest{VolumeId: volID})
+			Expect(err).NotTo(HaveOccurred())
+			v := inspectResp.GetVolume()
+			switch v.GetState() {
+			case api.VolumeState_VOLUME_STATE_ATTACHED:
+				Expect(v.GetDevicePath()).NotTo(BeEmpty(), "The volume is attached without a device path")
+				Expect(attached).To(BeTrue(), "The volume is attached but every attach failed")
+			case api.VolumeState_VOLUME_STATE_DETACHED:
+				Expect(v.GetDevicePath()).To(BeEmpty(), "The volume is detached with a device path")
+				// The volume was detached before the race
+				Expect(detached || !attached).To(BeTrue(),
+					"The volume is detached but every detach failed after an attach succeeded")
+			default:
+				Fail(fmt.Sprintf("The volume is %v instead of attached or detached", v.GetState()))
+			}
+
+			By("detaching the volume")
+			_, err = ma.Detach(ctx, &api.SdkVolumeDetachRequest{
+				VolumeId: volID,
+				Options: &api.SdkVolumeDetachOptions{
+					UnmountBeforeDetach: true,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			inspectResp, err = vc.Inspect(ctx, &api.SdkVolumeInspectRequest{VolumeId: volID})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inspectResp.GetVolume().GetState()).NotTo(Equal(api.VolumeState_VOLUME_STATE_ATTACHED))
+		})
+	})
+
+	Describe("Snapshots [OpenStorageVolume]", func() {
+		var volID string
+
+		BeforeEach(func() {
+			skipUnsupportedService("OpenStorageVolume")
+			volID = newTestVolume(vc)
+		})
+
+		AfterEach(func() {
+			if volID == "" {
+				return
+			}
+			snapEnumResp, err := vc.SnapshotEnumerateWithFilters(ctx,
+				&api.SdkVolumeSnapshotEnumerateWithFiltersRequest{VolumeId: volID})
+			Expect(err).NotTo(HaveOccurred())
+			for _, id := range snapEnumResp.GetVolumeSnapshotIds() {
+				Expect(deleteVol(ctx, vc, id)).NotTo(HaveOccurred())
+			}
+			Expect(deleteVol(ctx, vc, volID)).NotTo(HaveOccurred())
+		})
+
+		It("should create every snapshot when snapshots of a volume race", func() {
+			ids := make([]string, raceWorkers)
+			errs := race(raceWorkers, func(i int) error {
+				resp, err := vc.SnapshotCreate(ctx, &api.SdkVolumeSnapshotCreateRequest{
+					VolumeId: volID,
+					Name:     genName("snap"),
+				})
+				ids[i] = resp.GetSnapshotId()
+				return err
+			})
+			expectNoServerErrors(errs)
+			Expect(winners(errs)).To(HaveLen(raceWorkers))
+
+			snapEnumResp, err := vc.SnapshotEnumerateWithFilters(ctx,
+				&api.SdkVolumeSnapshotEnumerateWithFiltersRequest{VolumeId: volID})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(snapEnumResp.GetVolumeSnapshotIds()).To(ConsistOf(ids))
+		})
+
+		It("should delete a snapshot once when deletes race", func() {
+			snapResp, err := vc.SnapshotCreate(ctx, &api.SdkVolumeSnapshotCreateRequest{
+				VolumeId: volID,
+				Name:     genName("snap"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			errs := race(raceWorkers, func(i int) error {
+				_, err := vc.Delete(ctx, &api.SdkVolumeDeleteRequest{VolumeId: snapResp.GetSnapshotId()})
+				return err
+			})
+			expectNoServerErrors(errs)
+			Expect(winners(errs)).NotTo(BeEmpty(), "Deletes which succeeded")
+			expectCodes(errs, codes.NotFound)
+
+			_, err = vc.Inspect(ctx, &api.SdkVolumeInspectRequest{VolumeId: snapResp.GetSnapshotId()})
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+	})
+
+	Describe("Schedule policies [OpenStorageSchedulePolicy]", func() {
+		var (
+			sc         api.OpenStorageSchedulePolicyClient
+			policyName string
+		)
+
+		BeforeEach(func() {
+			skipUnsupportedService("OpenStorageSchedulePolicy")
+			sc = api.NewOpenStorageSchedulePolicyClient(run.conn)
+			policyName = genName("policy")
+		})
+
+		AfterEach(func() {
+			_, err := sc.Delete(ctx, &api.SdkSchedulePolicyDeleteRequest{Name: policyName})
+			Expect(status.Code(err)).To(beOneOfCodes(codes.OK, codes.NotFound))
+		})
+
+		newPolicy := func(retain int64) *api.SdkSchedulePolicy {
+			return &api.SdkSchedulePolicy{
+				Name: policyName,
+				Schedules: []*api.SdkSchedulePolicyInterval{
+					&api.SdkSchedulePolicyInterval{
+						Retain: retain,
+						PeriodType: &api.SdkSchedulePolicyInterval_Daily{
+							Daily: &api.SdkSchedulePolicyIntervalDaily{
+								Hour:   12,
+								Minute: 30,
+							},
+						},
+					},
+				},
+			}
+		}
+
+		inspectRetain := func() int64 {
+			resp, err := sc.Inspect(ctx, &api.SdkSchedulePolicyInspectRequest{Name: policyName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetPolicy().GetSchedules()).To(HaveLen(1))
+			return resp.GetPolicy().GetSchedules()[0].GetRetain()
+		}
+
+		It("should create a single policy when creates of the same name race", func() {
+			errs := race(raceWorkers, func(i int) error {
+				_, err := sc.Create(ctx, &api.SdkSchedulePolicyCreateRequest{
+					SchedulePolicy: newPolicy(int64(i + 1)),
+				})
+				return err
+			})
+			expectNoServerErrors(errs)
+			won := winners(errs)
+			Expect(won).To(HaveLen(1), "Creates which succeeded")
+			expectCodes(errs, codes.AlreadyExists)
+
+			By("checking that the policy is the one of the winner")
+			Expect(inspectRetain()).To(BeEquivalentTo(won[0] + 1))
+		})
+
+		It("should keep the schedule of one update when updates race", func() {
+			_, err := sc.Create(ctx, &api.SdkSchedulePolicyCreateRequest{
+				SchedulePolicy: newPolicy(1),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			errs := race(raceWorkers, func(i int) error {
+				_, err := sc.Update(ctx, &api.SdkSchedulePolicyUpdateRequest{
+					SchedulePolicy: newPolicy(int64(i + 2)),
+				})
+				return err
+			})
+			expectNoServerErrors(errs)
+			won := winners(errs)
+			Expect(won).NotTo(BeEmpty(), "Updates which succeeded")
+
+			retains := make([]int64, len(won))
+			for i, w := range won {
+				retains[i] = int64(w + 2)
+			}
+			Expect(retains).To(ContainElement(inspectRetain()))
+		})
+
+		It("should delete a policy once when deletes race", func() {
+			_, err := sc.Create(ctx, &api.SdkSchedulePolicyCreateRequest{
+				SchedulePolicy: newPolicy(1),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			errs := race(raceWorkers, func(i int) error {
+				_, err := sc.Delete(ctx, &api.SdkSchedulePolicyDeleteRequest{Name: policyName})
+				return err
+			})
+			expectNoServerErrors(errs)
+			Expect(winners(errs)).NotTo(BeEmpty(), "Deletes which succeeded")
+			expectCodes(errs, codes.NotFound)
+
+			_, err = sc.Inspect(ctx, &api.SdkSchedulePolicyInspectRequest{Name: policyName})
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+	})
+})
@@ -0,0 +1,325 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fixtureFile holds the recorded calls of an RPC, saved as
+// <dir>/<service>/<rpc>.json
+type fixtureFile struct {
+	Method string         `json:"method"`
+	Calls  []*trafficCall `json:"calls"`
+}
+
+func fixtureFilename(dir, fullMethod string) string {
+	// The method is like /openstorage.api.OpenStorageVolume/Create
+	parts := strings.Split(strings.TrimPrefix(fullMethod, "/"), "/")
+	service := parts[0][strings.LastIndex(parts[0], ".")+1:]
+	return filepath.Join(dir, service, parts[len(parts)-1]+".json")
+}
+
+// saveFixture writes the calls recorded during the run into the directory,
+// with a file for each RPC
+func saveFixture(dir string, calls []*trafficCall) error {
+	methods := []string{}
+	byMethod := make(map[string][]*trafficCall)
+	for _, call := range calls {
+		if _, ok := byMethod[call.Method]; !ok {
+			methods = append(methods, call.Method)
+		}
+		// Metadata is not part of the fixture, it only has the tokens
+		call.Metadata = nil
+		byMethod[call.Method] = append(byMethod[call.Method], call)
+	}
+
+	for _, method := range methods {
+		filename := fixtureFilename(dir, method)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(&fixtureFile{
+			Method: method,
+			Calls:  byMethod[method],
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayServer answers the calls of the SDK with the responses recorded by
+// --sdk.record. A request gets the response recorded for the same request,
+// once both are normalized so that the run which recorded them does not
+// matter. Requests which match several recorded calls get their responses
+// in turn, and requests which match none fail with codes.Unimplemented.
+type replayServer struct {
+	lock  sync.Mutex
+	calls map[string][]*trafficCall
+	// keys are the normalized requests of the recorded calls
+	keys map[*trafficCall]string
+	// next is the index of the next response of each normalized request
+	// which matches several recorded calls
+	next map[string]int
+}
+
+// replayNameRegexp matches the names generated by genName, whose run ID and
+// random suffix change with each run
+var replayNameRegexp = regexp.MustCompile(
+	fmt.Sprintf(`%s-[a-z0-9]+-([a-z0-9-]+)-[0-9a-f]{6}`, resourcePrefix))
+
+// normalizeRequest returns the request recorded as JSON without what depends
+// on the run: the run ID label is removed, and generated names are replaced
+// by a placeholder with their kind
+func normalizeRequest(data json.RawMessage) string {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return string(data)
+	}
+	normalized, err := json.Marshal(normalizeValue(value))
+	if err != nil {
+		return string(data)
+	}
+	return string(normalized)
+}
+
+func normalizeValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{})
+		for k, v := range value {
+			if k != runIDLabel {
+				m[replayNameRegexp.ReplaceAllString(k, "<$1>")] = normalizeValue(v)
+			}
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(value))
+		for i, v := range value {
+			l[i] = normalizeValue(v)
+		}
+		return l
+	case string:
+		return replayNameRegexp.ReplaceAllString(value, "<$1>")
+	}
+	return value
+}
+
+// codesByName maps the names of the gRPC codes, like NotFound, to the codes
+var codesByName = func() map[string]codes.Code {
+	m := make(map[string]codes.Code)
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		m[c.String()] = c
+	}
+	return m
+}()
+
+// loadFixture reads the calls recorded in the directory
+func loadFixture(dir string) (map[string][]*trafficCall, error) {
+	calls := make(map[string][]*trafficCall)
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, filename := range files {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		f := fixtureFile{}
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("Unable to parse fixture %s: %v", filename, err)
+		}
+		for _, call := range f.Calls {
+			// Streams interrupted by the end of the run are not replayed
+			if _, ok := codesByName[call.Code]; ok {
+				calls[f.Method] = append(calls[f.Method], call)
+			}
+		}
+	}
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("No recorded calls found in %s", dir)
+	}
+	return calls, nil
+}
+
+// NewReplayServer returns a gRPC server implementing the services of the SDK
+// with the calls recorded in the directory by --sdk.record
+func NewReplayServer(dir string) (*grpc.Server, error) {
+	calls, err := loadFixture(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &replayServer{
+		calls: calls,
+		keys:  make(map[*trafficCall]string),
+		next:  make(map[string]int),
+	}
+	for _, list := range calls {
+		for _, call := range list {
+			if len(call.Requests) != 0 {
+				r.keys[call] = normalizeRequest(call.Requests[0])
+			}
+		}
+	}
+	s := grpc.NewServer()
+	r.register(s)
+	return s, nil
+}
+
+// find returns the recorded call for the request
+func (r *replayServer) find(method string, req proto.Message) (*trafficCall, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	calls := r.calls[method]
+	if len(calls) == 0 {
+		return nil, status.Errorf(codes.Unimplemented, "No recorded calls of %s", method)
+	}
+
+	// The request is compared in the form it was recorded
+	key := normalizeRequest(trafficMessage(req))
+	matches := []*trafficCall{}
+	for _, call := range calls {
+		if r.keys[call] == key {
+			matches = append(matches, call)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, status.Errorf(codes.Unimplemented, "No recorded call of %s matches the request", method)
+	}
+	call := matches[r.next[method+key]%len(matches)]
+	r.next[method+key]++
+	return call, nil
+}
+
+// recordedStatus returns the error of a recorded call, or nil if it
+// succeeded
+func recordedStatus(call *trafficCall) error {
+	code := codesByName[call.Code]
+	if code == codes.OK {
+		return nil
+	}
+	return status.Error(code, call.Message)
+}
+
+func unmarshalRecorded(data json.RawMessage, msg proto.Message) error {
+	// Fixtures recorded with a newer SDK may have unknown fields
+	u := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err := u.Unmarshal(bytes.NewReader(data), msg); err != nil {
+		return status.Errorf(codes.Internal, "Unable to replay the recorded response: %v", err)
+	}
+	return nil
+}
+
+func (r *replayServer) replayUnary(ctx context.Context, method string, req, resp proto.Message) error {
+	call, err := r.find(method, req)
+	if err != nil {
+		return err
+	}
+	if err := recordedStatus(call); err != nil {
+		return err
+	}
+	if len(call.Responses) == 0 {
+		return nil
+	}
+	return unmarshalRecorded(call.Responses[0], resp)
+}
+
+func (r *replayServer) replayStream(stream grpc.ServerStream, method string, req proto.Message) error {
+	call, err := r.find(method, req)
+	if err != nil {
+		return err
+	}
+
+	d, err := getSdkDescriptors()
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	m, ok := d.method(method)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "Unknown method %s", method)
+	}
+	for _, data := range call.Responses {
+		resp, err := newMessage(m.output)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err := unmarshalRecorded(data, resp); err != nil {
+			return err
+		}
+		if err := stream.SendMsg(resp); err != nil {
+			return err
+		}
+	}
+	return recordedStatus(call)
+}
+
+// listen opens the address of the endpoint, which is a unix socket when it
+// is a path, like connect does
+func listen(address string) (net.Listener, error) {
+	network, address := endpointNetwork(address)
+	if network == "unix" {
+		// Remove the socket left behind by a previous replay
+		if info, err := os.Stat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// Replay serves the calls recorded in c.ReplayDir at c.Address until the
+// context is done, so that clients of the SDK can be tested without a
+// storage system
+func Replay(ctx context.Context, c *SanityConfiguration) error {
+	s, err := NewReplayServer(c.ReplayDir)
+	if err != nil {
+		return err
+	}
+	l, err := listen(c.Address)
+	if err != nil {
+		return fmt.Errorf("Unable to listen on %s: %v", c.Address, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.GracefulStop()
+	}()
+	fmt.Printf("Replaying the calls recorded in %s on %s\n", c.ReplayDir, c.Address)
+	return s.Serve(l)
+}
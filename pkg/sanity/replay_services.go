@@ -0,0 +1,630 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc"
+)
+
+// replayAlertsServer replays the recorded calls of OpenStorageAlerts
+type replayAlertsServer struct {
+	*replayServer
+}
+
+func (s replayAlertsServer) EnumerateWithFilters(req *api.SdkAlertsEnumerateWithFiltersRequest, stream api.OpenStorageAlerts_EnumerateWithFiltersServer) error {
+	return s.replayStream(stream, "/openstorage.api.OpenStorageAlerts/EnumerateWithFilters", req)
+}
+
+func (s replayAlertsServer) Delete(ctx context.Context, req *api.SdkAlertsDeleteRequest) (*api.SdkAlertsDeleteResponse, error) {
+	resp := &api.SdkAlertsDeleteResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageAlerts/Delete", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayRoleServer replays the recorded calls of OpenStorageRole
+type replayRoleServer struct {
+	*replayServer
+}
+
+func (s replayRoleServer) Create(ctx context.Context, req *api.SdkRoleCreateRequest) (*api.SdkRoleCreateResponse, error) {
+	resp := &api.SdkRoleCreateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageRole/Create", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayRoleServer) Enumerate(ctx context.Context, req *api.SdkRoleEnumerateRequest) (*api.SdkRoleEnumerateResponse, error) {
+	resp := &api.SdkRoleEnumerateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageRole/Enumerate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayRoleServer) Inspect(ctx context.Context, req *api.SdkRoleInspectRequest) (*api.SdkRoleInspectResponse, error) {
+	resp := &api.SdkRoleInspectResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageRole/Inspect", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayRoleServer) Delete(ctx context.Context, req *api.SdkRoleDeleteRequest) (*api.SdkRoleDeleteResponse, error) {
+	resp := &api.SdkRoleDeleteResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageRole/Delete", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayRoleServer) Update(ctx context.Context, req *api.SdkRoleUpdateRequest) (*api.SdkRoleUpdateResponse, error) {
+	resp := &api.SdkRoleUpdateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageRole/Update", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayIdentityServer replays the recorded calls of OpenStorageIdentity
+type replayIdentityServer struct {
+	*replayServer
+}
+
+func (s replayIdentityServer) Capabilities(ctx context.Context, req *api.SdkIdentityCapabilitiesRequest) (*api.SdkIdentityCapabilitiesResponse, error) {
+	resp := &api.SdkIdentityCapabilitiesResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageIdentity/Capabilities", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayIdentityServer) Version(ctx context.Context, req *api.SdkIdentityVersionRequest) (*api.SdkIdentityVersionResponse, error) {
+	resp := &api.SdkIdentityVersionResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageIdentity/Version", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayClusterServer replays the recorded calls of OpenStorageCluster
+type replayClusterServer struct {
+	*replayServer
+}
+
+func (s replayClusterServer) InspectCurrent(ctx context.Context, req *api.SdkClusterInspectCurrentRequest) (*api.SdkClusterInspectCurrentResponse, error) {
+	resp := &api.SdkClusterInspectCurrentResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCluster/InspectCurrent", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayClusterPairServer replays the recorded calls of OpenStorageClusterPair
+type replayClusterPairServer struct {
+	*replayServer
+}
+
+func (s replayClusterPairServer) Create(ctx context.Context, req *api.SdkClusterPairCreateRequest) (*api.SdkClusterPairCreateResponse, error) {
+	resp := &api.SdkClusterPairCreateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageClusterPair/Create", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayClusterPairServer) Inspect(ctx context.Context, req *api.SdkClusterPairInspectRequest) (*api.SdkClusterPairInspectResponse, error) {
+	resp := &api.SdkClusterPairInspectResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageClusterPair/Inspect", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayClusterPairServer) Enumerate(ctx context.Context, req *api.SdkClusterPairEnumerateRequest) (*api.SdkClusterPairEnumerateResponse, error) {
+	resp := &api.SdkClusterPairEnumerateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageClusterPair/Enumerate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayClusterPairServer) GetToken(ctx context.Context, req *api.SdkClusterPairGetTokenRequest) (*api.SdkClusterPairGetTokenResponse, error) {
+	resp := &api.SdkClusterPairGetTokenResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageClusterPair/GetToken", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayClusterPairServer) ResetToken(ctx context.Context, req *api.SdkClusterPairResetTokenRequest) (*api.SdkClusterPairResetTokenResponse, error) {
+	resp := &api.SdkClusterPairResetTokenResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageClusterPair/ResetToken", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayClusterPairServer) Delete(ctx context.Context, req *api.SdkClusterPairDeleteRequest) (*api.SdkClusterPairDeleteResponse, error) {
+	resp := &api.SdkClusterPairDeleteResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageClusterPair/Delete", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayNodeServer replays the recorded calls of OpenStorageNode
+type replayNodeServer struct {
+	*replayServer
+}
+
+func (s replayNodeServer) Inspect(ctx context.Context, req *api.SdkNodeInspectRequest) (*api.SdkNodeInspectResponse, error) {
+	resp := &api.SdkNodeInspectResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageNode/Inspect", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayNodeServer) InspectCurrent(ctx context.Context, req *api.SdkNodeInspectCurrentRequest) (*api.SdkNodeInspectCurrentResponse, error) {
+	resp := &api.SdkNodeInspectCurrentResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageNode/InspectCurrent", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayNodeServer) Enumerate(ctx context.Context, req *api.SdkNodeEnumerateRequest) (*api.SdkNodeEnumerateResponse, error) {
+	resp := &api.SdkNodeEnumerateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageNode/Enumerate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayVolumeServer replays the recorded calls of OpenStorageVolume
+type replayVolumeServer struct {
+	*replayServer
+}
+
+func (s replayVolumeServer) Create(ctx context.Context, req *api.SdkVolumeCreateRequest) (*api.SdkVolumeCreateResponse, error) {
+	resp := &api.SdkVolumeCreateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/Create", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) Clone(ctx context.Context, req *api.SdkVolumeCloneRequest) (*api.SdkVolumeCloneResponse, error) {
+	resp := &api.SdkVolumeCloneResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/Clone", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) Delete(ctx context.Context, req *api.SdkVolumeDeleteRequest) (*api.SdkVolumeDeleteResponse, error) {
+	resp := &api.SdkVolumeDeleteResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/Delete", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) Inspect(ctx context.Context, req *api.SdkVolumeInspectRequest) (*api.SdkVolumeInspectResponse, error) {
+	resp := &api.SdkVolumeInspectResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/Inspect", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) Update(ctx context.Context, req *api.SdkVolumeUpdateRequest) (*api.SdkVolumeUpdateResponse, error) {
+	resp := &api.SdkVolumeUpdateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/Update", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) Stats(ctx context.Context, req *api.SdkVolumeStatsRequest) (*api.SdkVolumeStatsResponse, error) {
+	resp := &api.SdkVolumeStatsResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/Stats", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) CapacityUsage(ctx context.Context, req *api.SdkVolumeCapacityUsageRequest) (*api.SdkVolumeCapacityUsageResponse, error) {
+	resp := &api.SdkVolumeCapacityUsageResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/CapacityUsage", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) Enumerate(ctx context.Context, req *api.SdkVolumeEnumerateRequest) (*api.SdkVolumeEnumerateResponse, error) {
+	resp := &api.SdkVolumeEnumerateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/Enumerate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) EnumerateWithFilters(ctx context.Context, req *api.SdkVolumeEnumerateWithFiltersRequest) (*api.SdkVolumeEnumerateWithFiltersResponse, error) {
+	resp := &api.SdkVolumeEnumerateWithFiltersResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/EnumerateWithFilters", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) SnapshotCreate(ctx context.Context, req *api.SdkVolumeSnapshotCreateRequest) (*api.SdkVolumeSnapshotCreateResponse, error) {
+	resp := &api.SdkVolumeSnapshotCreateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/SnapshotCreate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) SnapshotRestore(ctx context.Context, req *api.SdkVolumeSnapshotRestoreRequest) (*api.SdkVolumeSnapshotRestoreResponse, error) {
+	resp := &api.SdkVolumeSnapshotRestoreResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/SnapshotRestore", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) SnapshotEnumerate(ctx context.Context, req *api.SdkVolumeSnapshotEnumerateRequest) (*api.SdkVolumeSnapshotEnumerateResponse, error) {
+	resp := &api.SdkVolumeSnapshotEnumerateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/SnapshotEnumerate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) SnapshotEnumerateWithFilters(ctx context.Context, req *api.SdkVolumeSnapshotEnumerateWithFiltersRequest) (*api.SdkVolumeSnapshotEnumerateWithFiltersResponse, error) {
+	resp := &api.SdkVolumeSnapshotEnumerateWithFiltersResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/SnapshotEnumerateWithFilters", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayVolumeServer) SnapshotScheduleUpdate(ctx context.Context, req *api.SdkVolumeSnapshotScheduleUpdateRequest) (*api.SdkVolumeSnapshotScheduleUpdateResponse, error) {
+	resp := &api.SdkVolumeSnapshotScheduleUpdateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageVolume/SnapshotScheduleUpdate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayMountAttachServer replays the recorded calls of OpenStorageMountAttach
+type replayMountAttachServer struct {
+	*replayServer
+}
+
+func (s replayMountAttachServer) Attach(ctx context.Context, req *api.SdkVolumeAttachRequest) (*api.SdkVolumeAttachResponse, error) {
+	resp := &api.SdkVolumeAttachResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageMountAttach/Attach", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayMountAttachServer) Detach(ctx context.Context, req *api.SdkVolumeDetachRequest) (*api.SdkVolumeDetachResponse, error) {
+	resp := &api.SdkVolumeDetachResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageMountAttach/Detach", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayMountAttachServer) Mount(ctx context.Context, req *api.SdkVolumeMountRequest) (*api.SdkVolumeMountResponse, error) {
+	resp := &api.SdkVolumeMountResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageMountAttach/Mount", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayMountAttachServer) Unmount(ctx context.Context, req *api.SdkVolumeUnmountRequest) (*api.SdkVolumeUnmountResponse, error) {
+	resp := &api.SdkVolumeUnmountResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageMountAttach/Unmount", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayMigrateServer replays the recorded calls of OpenStorageMigrate
+type replayMigrateServer struct {
+	*replayServer
+}
+
+func (s replayMigrateServer) Start(ctx context.Context, req *api.SdkCloudMigrateStartRequest) (*api.SdkCloudMigrateStartResponse, error) {
+	resp := &api.SdkCloudMigrateStartResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageMigrate/Start", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayMigrateServer) Cancel(ctx context.Context, req *api.SdkCloudMigrateCancelRequest) (*api.SdkCloudMigrateCancelResponse, error) {
+	resp := &api.SdkCloudMigrateCancelResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageMigrate/Cancel", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayMigrateServer) Status(ctx context.Context, req *api.SdkCloudMigrateStatusRequest) (*api.SdkCloudMigrateStatusResponse, error) {
+	resp := &api.SdkCloudMigrateStatusResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageMigrate/Status", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayObjectstoreServer replays the recorded calls of OpenStorageObjectstore
+type replayObjectstoreServer struct {
+	*replayServer
+}
+
+func (s replayObjectstoreServer) Inspect(ctx context.Context, req *api.SdkObjectstoreInspectRequest) (*api.SdkObjectstoreInspectResponse, error) {
+	resp := &api.SdkObjectstoreInspectResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageObjectstore/Inspect", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayObjectstoreServer) Create(ctx context.Context, req *api.SdkObjectstoreCreateRequest) (*api.SdkObjectstoreCreateResponse, error) {
+	resp := &api.SdkObjectstoreCreateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageObjectstore/Create", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayObjectstoreServer) Delete(ctx context.Context, req *api.SdkObjectstoreDeleteRequest) (*api.SdkObjectstoreDeleteResponse, error) {
+	resp := &api.SdkObjectstoreDeleteResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageObjectstore/Delete", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayObjectstoreServer) Update(ctx context.Context, req *api.SdkObjectstoreUpdateRequest) (*api.SdkObjectstoreUpdateResponse, error) {
+	resp := &api.SdkObjectstoreUpdateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageObjectstore/Update", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayCredentialsServer replays the recorded calls of OpenStorageCredentials
+type replayCredentialsServer struct {
+	*replayServer
+}
+
+func (s replayCredentialsServer) Create(ctx context.Context, req *api.SdkCredentialCreateRequest) (*api.SdkCredentialCreateResponse, error) {
+	resp := &api.SdkCredentialCreateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCredentials/Create", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCredentialsServer) Enumerate(ctx context.Context, req *api.SdkCredentialEnumerateRequest) (*api.SdkCredentialEnumerateResponse, error) {
+	resp := &api.SdkCredentialEnumerateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCredentials/Enumerate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCredentialsServer) Inspect(ctx context.Context, req *api.SdkCredentialInspectRequest) (*api.SdkCredentialInspectResponse, error) {
+	resp := &api.SdkCredentialInspectResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCredentials/Inspect", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCredentialsServer) Delete(ctx context.Context, req *api.SdkCredentialDeleteRequest) (*api.SdkCredentialDeleteResponse, error) {
+	resp := &api.SdkCredentialDeleteResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCredentials/Delete", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCredentialsServer) Validate(ctx context.Context, req *api.SdkCredentialValidateRequest) (*api.SdkCredentialValidateResponse, error) {
+	resp := &api.SdkCredentialValidateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCredentials/Validate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replaySchedulePolicyServer replays the recorded calls of OpenStorageSchedulePolicy
+type replaySchedulePolicyServer struct {
+	*replayServer
+}
+
+func (s replaySchedulePolicyServer) Create(ctx context.Context, req *api.SdkSchedulePolicyCreateRequest) (*api.SdkSchedulePolicyCreateResponse, error) {
+	resp := &api.SdkSchedulePolicyCreateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageSchedulePolicy/Create", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replaySchedulePolicyServer) Update(ctx context.Context, req *api.SdkSchedulePolicyUpdateRequest) (*api.SdkSchedulePolicyUpdateResponse, error) {
+	resp := &api.SdkSchedulePolicyUpdateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageSchedulePolicy/Update", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replaySchedulePolicyServer) Enumerate(ctx context.Context, req *api.SdkSchedulePolicyEnumerateRequest) (*api.SdkSchedulePolicyEnumerateResponse, error) {
+	resp := &api.SdkSchedulePolicyEnumerateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageSchedulePolicy/Enumerate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replaySchedulePolicyServer) Inspect(ctx context.Context, req *api.SdkSchedulePolicyInspectRequest) (*api.SdkSchedulePolicyInspectResponse, error) {
+	resp := &api.SdkSchedulePolicyInspectResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageSchedulePolicy/Inspect", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replaySchedulePolicyServer) Delete(ctx context.Context, req *api.SdkSchedulePolicyDeleteRequest) (*api.SdkSchedulePolicyDeleteResponse, error) {
+	resp := &api.SdkSchedulePolicyDeleteResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageSchedulePolicy/Delete", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayCloudBackupServer replays the recorded calls of OpenStorageCloudBackup
+type replayCloudBackupServer struct {
+	*replayServer
+}
+
+func (s replayCloudBackupServer) Create(ctx context.Context, req *api.SdkCloudBackupCreateRequest) (*api.SdkCloudBackupCreateResponse, error) {
+	resp := &api.SdkCloudBackupCreateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/Create", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) Restore(ctx context.Context, req *api.SdkCloudBackupRestoreRequest) (*api.SdkCloudBackupRestoreResponse, error) {
+	resp := &api.SdkCloudBackupRestoreResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/Restore", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) Delete(ctx context.Context, req *api.SdkCloudBackupDeleteRequest) (*api.SdkCloudBackupDeleteResponse, error) {
+	resp := &api.SdkCloudBackupDeleteResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/Delete", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) DeleteAll(ctx context.Context, req *api.SdkCloudBackupDeleteAllRequest) (*api.SdkCloudBackupDeleteAllResponse, error) {
+	resp := &api.SdkCloudBackupDeleteAllResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/DeleteAll", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) EnumerateWithFilters(ctx context.Context, req *api.SdkCloudBackupEnumerateWithFiltersRequest) (*api.SdkCloudBackupEnumerateWithFiltersResponse, error) {
+	resp := &api.SdkCloudBackupEnumerateWithFiltersResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/EnumerateWithFilters", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) Status(ctx context.Context, req *api.SdkCloudBackupStatusRequest) (*api.SdkCloudBackupStatusResponse, error) {
+	resp := &api.SdkCloudBackupStatusResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/Status", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) Catalog(ctx context.Context, req *api.SdkCloudBackupCatalogRequest) (*api.SdkCloudBackupCatalogResponse, error) {
+	resp := &api.SdkCloudBackupCatalogResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/Catalog", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) History(ctx context.Context, req *api.SdkCloudBackupHistoryRequest) (*api.SdkCloudBackupHistoryResponse, error) {
+	resp := &api.SdkCloudBackupHistoryResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/History", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) StateChange(ctx context.Context, req *api.SdkCloudBackupStateChangeRequest) (*api.SdkCloudBackupStateChangeResponse, error) {
+	resp := &api.SdkCloudBackupStateChangeResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/StateChange", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) SchedCreate(ctx context.Context, req *api.SdkCloudBackupSchedCreateRequest) (*api.SdkCloudBackupSchedCreateResponse, error) {
+	resp := &api.SdkCloudBackupSchedCreateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/SchedCreate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) SchedDelete(ctx context.Context, req *api.SdkCloudBackupSchedDeleteRequest) (*api.SdkCloudBackupSchedDeleteResponse, error) {
+	resp := &api.SdkCloudBackupSchedDeleteResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/SchedDelete", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s replayCloudBackupServer) SchedEnumerate(ctx context.Context, req *api.SdkCloudBackupSchedEnumerateRequest) (*api.SdkCloudBackupSchedEnumerateResponse, error) {
+	resp := &api.SdkCloudBackupSchedEnumerateResponse{}
+	if err := s.replayUnary(ctx, "/openstorage.api.OpenStorageCloudBackup/SchedEnumerate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// register adds every service of the SDK to the gRPC server
+func (r *replayServer) register(s *grpc.Server) {
+	api.RegisterOpenStorageAlertsServer(s, replayAlertsServer{r})
+	api.RegisterOpenStorageRoleServer(s, replayRoleServer{r})
+	api.RegisterOpenStorageIdentityServer(s, replayIdentityServer{r})
+	api.RegisterOpenStorageClusterServer(s, replayClusterServer{r})
+	api.RegisterOpenStorageClusterPairServer(s, replayClusterPairServer{r})
+	api.RegisterOpenStorageNodeServer(s, replayNodeServer{r})
+	api.RegisterOpenStorageVolumeServer(s, replayVolumeServer{r})
+	api.RegisterOpenStorageMountAttachServer(s, replayMountAttachServer{r})
+	api.RegisterOpenStorageMigrateServer(s, replayMigrateServer{r})
+	api.RegisterOpenStorageObjectstoreServer(s, replayObjectstoreServer{r})
+	api.RegisterOpenStorageCredentialsServer(s, replayCredentialsServer{r})
+	api.RegisterOpenStorageSchedulePolicyServer(s, replaySchedulePolicyServer{r})
+	api.RegisterOpenStorageCloudBackupServer(s, replayCloudBackupServer{r})
+}
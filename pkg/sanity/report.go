@@ -0,0 +1,298 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	ginkgoconfig "github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/reporters"
+	"github.com/onsi/ginkgo/types"
+)
+
+var serviceTagRegexp = regexp.MustCompile(`\[OpenStorage\w+\]`)
+
+// SpecState is the outcome of a spec
+type SpecState string
+
+const (
+	SpecPassed   SpecState = "passed"
+	SpecSkipped  SpecState = "skipped"
+	SpecPending  SpecState = "pending"
+	SpecFailed   SpecState = "failed"
+	SpecPanicked SpecState = "panicked"
+	SpecTimedOut SpecState = "timedout"
+	SpecInvalid  SpecState = "invalid"
+)
+
+// SpecResult is the outcome of a single spec, or of a failed suite setup
+// node like BeforeSuite
+type SpecResult struct {
+	// Name is the text of the spec and its containers
+	Name string `json:"name"`
+	// Service is the innermost service tag, like [OpenStorageVolume]
+	Service string    `json:"service,omitempty"`
+	State   SpecState `json:"state"`
+	// Duration is the run time in seconds
+	Duration        float64 `json:"duration"`
+	SkipReason      string  `json:"skipReason,omitempty"`
+	Failure         string  `json:"failure,omitempty"`
+	FailureLocation string  `json:"failureLocation,omitempty"`
+	// Output is what a failed spec wrote to GinkgoWriter, including its
+	// gRPC calls
+	Output string `json:"output,omitempty"`
+}
+
+// Result is the outcome of a run of the suite
+type Result struct {
+	Suite  string `json:"suite"`
+	Passed bool   `json:"passed"`
+	// Duration is the run time in seconds
+	Duration float64      `json:"duration"`
+	Specs    []SpecResult `json:"specs"`
+}
+
+func specState(state types.SpecState) SpecState {
+	switch state {
+	case types.SpecStatePassed:
+		return SpecPassed
+	case types.SpecStateSkipped:
+		return SpecSkipped
+	case types.SpecStatePending:
+		return SpecPending
+	case types.SpecStateFailed:
+		return SpecFailed
+	case types.SpecStatePanicked:
+		return SpecPanicked
+	case types.SpecStateTimedOut:
+		return SpecTimedOut
+	default:
+		return SpecInvalid
+	}
+}
+
+// serviceTag returns the innermost service tag, like [OpenStorageVolume],
+// found in the texts of the spec and its containers
+func serviceTag(texts []string) string {
+	for i := len(texts) - 1; i >= 0; i-- {
+		if tag := serviceTagRegexp.FindString(texts[i]); len(tag) != 0 {
+			return tag
+		}
+	}
+	return ""
+}
+
+func newSpecResult(summary *types.SpecSummary) SpecResult {
+	// The first component text is always the top level container
+	texts := summary.ComponentTexts
+	if len(texts) > 0 {
+		texts = texts[1:]
+	}
+
+	r := SpecResult{
+		Name:     strings.Join(texts, " "),
+		Service:  serviceTag(texts),
+		State:    specState(summary.State),
+		Duration: summary.RunTime.Seconds(),
+	}
+	switch {
+	case summary.Skipped():
+		r.SkipReason = summary.Failure.Message
+	case summary.HasFailureState():
+		r.Failure = summary.Failure.Message
+		r.FailureLocation = summary.Failure.Location.String()
+		r.Output = summary.CapturedOutput
+	}
+	return r
+}
+
+func newSetupResult(name string, summary *types.SetupSummary) SpecResult {
+	r := SpecResult{
+		Name:     name,
+		State:    specState(summary.State),
+		Duration: summary.RunTime.Seconds(),
+	}
+	if summary.State.IsFailure() {
+		r.Failure = summary.Failure.Message
+		r.FailureLocation = summary.Failure.Location.String()
+	}
+	return r
+}
+
+// reportFilename adds the node number to the report filename when the suite
+// is run in parallel, so that the nodes do not overwrite each other's reports
+func reportFilename(filename string) string {
+	if ginkgoconfig.GinkgoConfig.ParallelTotal <= 1 {
+		return filename
+	}
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s.%d%s",
+		strings.TrimSuffix(filename, ext),
+		ginkgoconfig.GinkgoConfig.ParallelNode,
+		ext)
+}
+
+// recordingReporter collects a SpecResult for every spec and failed suite
+// setup node. It is embedded by the file reporters and used by Run.
+type recordingReporter struct {
+	report Result
+}
+
+func (r *recordingReporter) SpecSuiteWillBegin(c ginkgoconfig.GinkgoConfigType, summary *types.SuiteSummary) {
+	r.report = Result{
+		Suite: summary.SuiteDescription,
+		Specs: []SpecResult{},
+	}
+}
+
+func (r *recordingReporter) BeforeSuiteDidRun(summary *types.SetupSummary) {
+	if summary.State != types.SpecStatePassed {
+		r.report.Specs = append(r.report.Specs, newSetupResult("BeforeSuite", summary))
+	}
+}
+
+func (r *recordingReporter) SpecWillRun(summary *types.SpecSummary) {}
+
+func (r *recordingReporter) SpecDidComplete(summary *types.SpecSummary) {
+	r.report.Specs = append(r.report.Specs, newSpecResult(summary))
+}
+
+func (r *recordingReporter) AfterSuiteDidRun(summary *types.SetupSummary) {
+	if summary.State != types.SpecStatePassed {
+		r.report.Specs = append(r.report.Specs, newSetupResult("AfterSuite", summary))
+	}
+}
+
+func (r *recordingReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	r.report.Passed = summary.SuiteSucceeded
+	r.report.Duration = summary.RunTime.Seconds()
+}
+
+// jsonReporter writes the records of the run as a JSON document
+type jsonReporter struct {
+	recordingReporter
+	filename string
+}
+
+func newJSONReporter(filename string) reporters.Reporter {
+	return &jsonReporter{
+		filename: reportFilename(filename),
+	}
+}
+
+func (r *jsonReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	r.recordingReporter.SpecSuiteDidEnd(summary)
+
+	data, err := json.MarshalIndent(&r.report, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(r.filename, data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("Failed to write JSON report %s: %v\n", r.filename, err)
+	}
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// junitReporter writes the records of the run as JUnit XML. The service tag
+// of each spec is used as its class name.
+type junitReporter struct {
+	recordingReporter
+	filename string
+}
+
+func newJUnitReporter(filename string) reporters.Reporter {
+	return &junitReporter{
+		filename: reportFilename(filename),
+	}
+}
+
+func (r *junitReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	r.recordingReporter.SpecSuiteDidEnd(summary)
+
+	suite := junitTestSuite{
+		Name: r.report.Suite,
+		Time: r.report.Duration,
+	}
+	for _, spec := range r.report.Specs {
+		tc := junitTestCase{
+			Name:      spec.Name,
+			ClassName: spec.Service,
+			Time:      spec.Duration,
+			SystemOut: spec.Output,
+		}
+		if len(tc.ClassName) == 0 {
+			tc.ClassName = r.report.Suite
+		}
+		switch spec.State {
+		case SpecPassed:
+		case SpecSkipped, SpecPending:
+			tc.Skipped = &junitSkipped{Message: spec.SkipReason}
+			suite.Skipped++
+		default:
+			tc.Failure = &junitFailure{
+				Type:    string(spec.State),
+				Message: spec.Failure,
+				Content: fmt.Sprintf("%s\n%s", spec.Failure, spec.FailureLocation),
+			}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	data, err := xml.MarshalIndent(&suite, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(r.filename, append([]byte(xml.Header), data...), 0644)
+	}
+	if err != nil {
+		fmt.Printf("Failed to write JUnit report %s: %v\n", r.filename, err)
+	}
+}
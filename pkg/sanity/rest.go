@@ -0,0 +1,368 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	transportGrpc = "grpc"
+	transportRest = "rest"
+)
+
+var pathParamRegexp = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?\}`)
+
+// restTransport sends the unary calls of the tests to the REST gateway
+// instead of the gRPC server, so that the same tests check the gateway.
+// Streaming calls, and calls without a route, still use gRPC.
+type restTransport struct {
+	gateway     string
+	client      *http.Client
+	descriptors *sdkDescriptors
+
+	lock sync.Mutex
+	// statusMismatches are the responses where the HTTP status does not
+	// match the gRPC code returned by the gateway
+	statusMismatches map[string]bool
+}
+
+func newRestTransport(gateway string) (*restTransport, error) {
+	d, err := getSdkDescriptors()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(gateway, "://") {
+		gateway = "http://" + gateway
+	}
+	if _, err := url.Parse(gateway); err != nil {
+		return nil, fmt.Errorf("Invalid gateway address %s: %v", gateway, err)
+	}
+	return &restTransport{
+		gateway:          strings.TrimSuffix(gateway, "/"),
+		client:           &http.Client{},
+		descriptors:      d,
+		statusMismatches: make(map[string]bool),
+	}, nil
+}
+
+// httpRoute returns the HTTP method and path template of a route
+func httpRoute(rule *annotations.HttpRule) (string, string) {
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, p.Get
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, p.Put
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, p.Post
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Custom:
+		return p.Custom.GetKind(), p.Custom.GetPath()
+	}
+	return "", ""
+}
+
+// takeField removes the field with the dotted path from the JSON object of
+// the request and returns its value
+func takeField(fields map[string]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		nested, ok := fields[part].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		fields = nested
+	}
+	value, ok := fields[parts[len(parts)-1]]
+	delete(fields, parts[len(parts)-1])
+	return value, ok
+}
+
+// jsonString returns the form of a JSON value used in paths and queries
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// queryValues adds the fields of a message to the query, in the format
+// parsed by runtime.PopulateQueryParameters
+func (t *restTransport) queryValues(values url.Values, msgName, prefix string, fields map[string]interface{}) {
+	msg, _ := t.descriptors.message(msgName)
+	for name, value := range fields {
+		key := prefix + name
+
+		var field *descriptor.FieldDescriptorProto
+		for _, f := range msg.GetField() {
+			if f.GetName() == name {
+				field = f
+			}
+		}
+		if obj, ok := value.(map[string]interface{}); ok && field != nil {
+			if nested, ok := t.descriptors.message(field.GetTypeName()); ok {
+				if nested.GetOptions().GetMapEntry() {
+					for k, v := range obj {
+						values.Add(fmt.Sprintf("%s[%s]", key, k), jsonString(v))
+					}
+				} else {
+					t.queryValues(values, field.GetTypeName(), key+".", obj)
+				}
+				continue
+			}
+		}
+		if list, ok := value.([]interface{}); ok {
+			for _, v := range list {
+				values.Add(key, jsonString(v))
+			}
+			continue
+		}
+		values.Add(key, jsonString(value))
+	}
+}
+
+// newRequest converts a gRPC request into a request to its gateway route.
+// Path parameters are taken from the request, and the remaining fields are
+// sent in the body or in the query as specified by the route.
+func (t *restTransport) newRequest(ctx context.Context, m sdkMethod, msg proto.Message) (*http.Request, error) {
+	verb, path := httpRoute(m.http)
+
+	// The gateway uses the names of the fields in the proto file
+	marshaler := jsonpb.Marshaler{OrigName: true}
+	data, err := marshaler.MarshalToString(msg)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	fields := make(map[string]interface{})
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	path = pathParamRegexp.ReplaceAllStringFunc(path, func(param string) string {
+		name := pathParamRegexp.FindStringSubmatch(param)[1]
+		value, _ := takeField(fields, name)
+		if value == nil {
+			value = ""
+		}
+		return url.PathEscape(jsonString(value))
+	})
+
+	var body io.Reader
+	query := url.Values{}
+	switch m.http.GetBody() {
+	case "":
+		t.queryValues(query, m.input, "", fields)
+	case "*":
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	default:
+		value, _ := takeField(fields, m.http.GetBody())
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+		t.queryValues(query, m.input, "", fields)
+	}
+
+	u := t.gateway + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(verb, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	// The gateway passes the Authorization header and the headers with
+	// the Grpc-Metadata- prefix to the server as metadata
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		for k, vs := range md {
+			for _, v := range vs {
+				if k == "authorization" {
+					req.Header.Add("Authorization", v)
+				} else {
+					req.Header.Add(runtime.MetadataHeaderPrefix+k, v)
+				}
+			}
+		}
+	}
+	return req, nil
+}
+
+// gatewayError is the body returned by the gateway for errors
+type gatewayError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Code    int32  `json:"code"`
+}
+
+// httpStatusOfCode is the HTTP status of each gRPC code, as documented by
+// google.rpc.Code, which the SDK REST documentation refers to. It is kept
+// here instead of using the mapping of the gateway, which is the one being
+// tested.
+var httpStatusOfCode = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// statusError converts an error returned by the gateway back into a gRPC
+// status, recording it if the HTTP status does not match the gRPC code
+func (t *restTransport) statusError(method string, httpStatus int, body []byte) error {
+	e := gatewayError{}
+	if err := json.Unmarshal(body, &e); err != nil {
+		t.recordMismatch(fmt.Sprintf("%s returned HTTP %d without a gateway error: %s",
+			method, httpStatus, strings.TrimSpace(string(body))))
+		return status.Errorf(codes.Unknown, "REST gateway returned HTTP %d: %s", httpStatus, body)
+	}
+
+	code := codes.Code(e.Code)
+	if expected, ok := httpStatusOfCode[code]; !ok {
+		t.recordMismatch(fmt.Sprintf("%s returned HTTP %d for unknown code %d",
+			method, httpStatus, e.Code))
+	} else if expected != httpStatus {
+		t.recordMismatch(fmt.Sprintf("%s returned HTTP %d for %v, expected HTTP %d",
+			method, httpStatus, code, expected))
+	}
+	message := e.Error
+	if len(message) == 0 {
+		message = e.Message
+	}
+	return status.Error(code, message)
+}
+
+func (t *restTransport) recordMismatch(mismatch string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.statusMismatches[mismatch] = true
+}
+
+// getStatusMismatches returns the sorted list of mismatches recorded
+func (t *restTransport) getStatusMismatches() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	mismatches := make([]string, 0, len(t.statusMismatches))
+	for mismatch := range t.statusMismatches {
+		mismatches = append(mismatches, mismatch)
+	}
+	sort.Strings(mismatches)
+	return mismatches
+}
+
+// unaryInterceptor sends the call to the gateway. It must be the last
+// interceptor of the connection, since it does not call the invoker.
+func (t *restTransport) unaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	m, ok := t.descriptors.method(method)
+	if !ok || m.http == nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	httpReq, err := t.newRequest(ctx, m, req.(proto.Message))
+	if err != nil {
+		return status.Errorf(codes.Internal, "Unable to convert %s to a REST request: %v", method, err)
+	}
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return status.Error(codes.DeadlineExceeded, err.Error())
+		} else if ctx.Err() == context.Canceled {
+			return status.Error(codes.Canceled, err.Error())
+		}
+		return status.Errorf(codes.Unavailable, "REST gateway request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "Unable to read REST gateway response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return t.statusError(method, resp.StatusCode, body)
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	// Unknown fields are not allowed so that renamed fields are caught
+	if err := jsonpb.Unmarshal(bytes.NewReader(body), reply.(proto.Message)); err != nil {
+		return status.Errorf(codes.Internal,
+			"REST gateway returned a response for %s which does not match %s: %v",
+			method, m.output, err)
+	}
+	return nil
+}
@@ -26,7 +26,7 @@ import (
 	. "github.com/onsi/gomega"
 )
 
-var _ = Describe("Role Service Test Suite", func() {
+var _ = Describe("Role [OpenStorageRole]"+sdkVersionTag("OpenStorageRole"), func() {
 
 	var (
 		rc    api.OpenStorageRoleClient
@@ -34,10 +34,10 @@ var _ = Describe("Role Service Test Suite", func() {
 	)
 
 	BeforeEach(func() {
-		if len(config.SharedSecret) == 0 {
+		if len(run.config.SharedSecret) == 0 {
 			Skip("Not running with authentication")
 		}
-		rc = api.NewOpenStorageRoleClient(conn)
+		rc = api.NewOpenStorageRoleClient(run.conn)
 		users = createUsersTokens()
 	})
 
@@ -53,7 +53,7 @@ var _ = Describe("Role Service Test Suite", func() {
 		})
 
 		It("should create, list, get, and delete a role", func() {
-			role := "tester"
+			role := genName("role")
 
 			By("creating role")
 			ctx := setContextWithToken(context.Background(), users["admin"])
@@ -20,23 +20,77 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"net/url"
+	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 
+	ginkgoconfig "github.com/onsi/ginkgo/config"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
-var (
+const suiteName = "OpenStorage SDK Test Suite"
+
+// sanityRun holds the state of a run of the suite
+type sanityRun struct {
+	ctx    context.Context
 	config *SanityConfiguration
 	conn   *grpc.ClientConn
-	lock   sync.Mutex
 	users  map[string]string
+
+	// id is part of the name of every resource created by the run
+	id string
+	// ledger has the resources created by the run which were not deleted
+	ledger *ledger
+	// rest sends the calls to the REST gateway when it is the transport
+	rest *restTransport
+	// coverage records the RPCs called by the tests
+	coverage *apiCoverage
+	// traffic records the calls of each spec
+	traffic *trafficRecorder
+	// bench measures the calls of the workloads in benchmark mode
+	bench *benchRecorder
+	// soak counts the calls of each window in soak mode, where tokens
+	// refreshes the admin token of the calls
+	soak   *soakRecorder
+	tokens *tokenCredentials
+	// upgrade has the resources created by the pre-upgrade phase
+	upgrade *upgradeState
+	// fixture records every call of the run with --sdk.record
+	fixture *trafficRecorder
+
+	// serverSdkVersion is the version of the SDK reported by the server
+	serverSdkVersion *api.SdkVersion
+
+	lock sync.Mutex
+	// unimplementedApis are the APIs which returned codes.Unimplemented
+	// even though the server reports an SDK version which includes them
+	unimplementedApis map[string]bool
+	// errorCodeDeviations are the calls of the error code matrix which
+	// returned an unexpected code
+	errorCodeDeviations []ErrorCodeDeviation
+	// errorCodeMatrixRan is set once a row of the error code matrix ran
+	errorCodeMatrixRan bool
+	// fuzzSeed is the seed of the requests generated in fuzz mode
+	fuzzSeed int64
+	// proxy injects network faults into the connection, when enabled.
+	// faults are the ones configured for the run.
+	proxy  *faultProxy
+	faults faults
+}
+
+var (
+	// Ginkgo keeps a single tree of specs per process, so only one run can
+	// be active at a time. run points to it while Run is executing.
+	runLock sync.Mutex
+	run     *sanityRun
 )
 
 // CloudProviderConfig struct for cloud providers configuration
@@ -46,58 +100,514 @@ type CloudProviderConfig struct {
 	CloudProviders map[string]map[string]string
 }
 
+// SanityConfiguration configures a run of the tests. It can be loaded from a
+// YAML file with LoadConfiguration, using the keys in the yaml tags.
 type SanityConfiguration struct {
-	Address        string
-	MountPath      string
-	SharedSecret   string
-	Issuer         string
-	ProviderConfig *CloudProviderConfig
+	Address        string               `yaml:"endpoint"`
+	MountPath      string               `yaml:"mountpath"`
+	SharedSecret   string               `yaml:"sharedsecret"`
+	Issuer         string               `yaml:"issuer"`
+	ProviderConfig *CloudProviderConfig `yaml:"cloud-provider-config"`
+	// JUnitReport is the file where a JUnit XML report is saved, optional
+	JUnitReport string `yaml:"junit-report"`
+	// JSONReport is the file where a JSON report is saved, optional
+	JSONReport string `yaml:"json-report"`
+	// Services limits the tests to these services, like volume or snapshot
+	Services []string `yaml:"services"`
+	// ExcludeTags skips the tests with any of these tags, like Buggy
+	ExcludeTags []string `yaml:"exclude-tags"`
+	// ListSpecs prints the selected tests instead of running them
+	ListSpecs bool `yaml:"list"`
+	// RunID is used in the names of the resources created by the tests.
+	// It defaults to a random ID, and must only contain lowercase letters
+	// and digits.
+	RunID string `yaml:"run-id"`
+	// CleanupOnly deletes the resources left behind by previous runs with
+	// RunID, which must be set, instead of running the tests
+	CleanupOnly bool `yaml:"cleanup-only"`
+	// Transport is grpc, the default, or rest to send the calls of the
+	// tests to the REST gateway at GatewayAddress
+	Transport      string `yaml:"transport"`
+	GatewayAddress string `yaml:"gateway"`
+	// ErrorCodeReport is the file where the calls of the error code matrix
+	// which returned unexpected codes, and the RPCs it does not cover, are
+	// saved as JSON, optional
+	ErrorCodeReport string `yaml:"error-code-report"`
+	// Fuzz sends random requests to every RPC instead of running the tests.
+	// Requests which make the server return codes.Internal or
+	// codes.Unknown, drop the connection, or hang for FuzzTimeout are saved
+	// as JSON reproducers in FuzzDir. FuzzSeed repeats the requests of a
+	// previous run. The RPCs which delete or move data are only fuzzed
+	// with FuzzDestructive.
+	Fuzz            bool          `yaml:"fuzz"`
+	FuzzIterations  int           `yaml:"fuzz-iterations"`
+	FuzzSeed        int64         `yaml:"fuzz-seed"`
+	FuzzTimeout     time.Duration `yaml:"fuzz-timeout"`
+	FuzzDir         string        `yaml:"fuzz-dir"`
+	FuzzDestructive bool          `yaml:"fuzz-destructive"`
+	// CoverageThreshold fails the run when a lower percentage of the RPCs
+	// of the SDK was called successfully, optional
+	CoverageThreshold float64 `yaml:"coverage-threshold"`
+	// TrafficDir is the directory where the gRPC calls of each spec are
+	// saved as JSON, optional. The calls of failed specs are always part of
+	// their output.
+	TrafficDir string `yaml:"traffic-dir"`
+	// RecordDir is the directory where all the calls of the run are saved,
+	// as a fixture for ReplayDir, optional
+	RecordDir string `yaml:"record"`
+	// ReplayDir serves the calls recorded in the directory at Address
+	// instead of running the tests
+	ReplayDir string `yaml:"replay"`
+	// FaultProxy sends the calls through a local proxy which injects
+	// network faults, like latency=100ms,bandwidth=65536,half-open=true or
+	// reset-every=1m in Faults. Setting Faults enables the proxy. The
+	// NetworkFaults tests change the faults, and are skipped without it.
+	FaultProxy bool   `yaml:"fault-proxy"`
+	Faults     string `yaml:"faults"`
+	// Bench runs the BenchWorkloads, or all of them, with BenchConcurrency
+	// workers for BenchDuration each instead of running the tests, and
+	// reports the throughput and latency of each RPC. The results are saved
+	// as JSON in BenchReport, optional. BenchVolumes is the number of
+	// volumes enumerated by the enumerate workload.
+	Bench            bool          `yaml:"bench"`
+	BenchWorkloads   []string      `yaml:"bench-workloads"`
+	BenchConcurrency int           `yaml:"bench-concurrency"`
+	BenchDuration    time.Duration `yaml:"bench-duration"`
+	BenchVolumes     int           `yaml:"bench-volumes"`
+	BenchReport      string        `yaml:"bench-report"`
+	// Soak repeats the lifecycle of a volume, from its creation to its
+	// deletion with a snapshot, a cloud backup and a restore, for
+	// SoakDuration instead of running the tests. The calls get an admin
+	// token which is refreshed before it expires, valid for TokenLifetime.
+	// Leaks and errors are counted over windows of SoakWindow. The run
+	// fails when resources leak, or when the error rate of the second half
+	// of the windows is higher than the one of the first half by more than
+	// SoakErrorRateIncrease percentage points, 5 when nil. The windows are
+	// saved as JSON in SoakReport, optional.
+	Soak                  bool          `yaml:"soak"`
+	SoakDuration          time.Duration `yaml:"soak-duration"`
+	SoakWindow            time.Duration `yaml:"soak-window"`
+	SoakErrorRateIncrease *float64      `yaml:"soak-error-rate-increase"`
+	SoakReport            string        `yaml:"soak-report"`
+	TokenLifetime         time.Duration `yaml:"token-lifetime"`
+	// Phase is pre-upgrade or post-upgrade to test an upgrade of the
+	// driver instead of running the tests. The pre-upgrade phase creates
+	// resources and saves them with their state in StateFile. The
+	// post-upgrade phase checks that they did not change and can still be
+	// used, then deletes them and the file.
+	Phase     string `yaml:"phase"`
+	StateFile string `yaml:"state-file"`
+	// MaxRecvMsgSize is the limit in bytes on the size of the responses
+	// received by the client, 4MB by default like gRPC
+	MaxRecvMsgSize int `yaml:"max-recv-msg-size"`
+	// ScaleVolumes and ScaleSnapshots are the number of volumes and
+	// snapshots created by the Scale tests, which are skipped when zero
+	ScaleVolumes   int `yaml:"scale-volumes"`
+	ScaleSnapshots int `yaml:"scale-snapshots"`
+	// BackupScheduleInterval is the period of the cloud backup schedules
+	// whose retention is checked, which are skipped when zero. It must be
+	// at least one second. Full backups are told from incremental ones by
+	// the value "full" of BackupTypeKey in the metadata of the backups,
+	// that check is skipped when it is empty.
+	BackupScheduleInterval time.Duration `yaml:"backup-schedule-interval"`
+	BackupTypeKey          string        `yaml:"backup-type-key"`
 }
 
 // Test will test start the sanity tests
 func Test(t *testing.T, reqConfig *SanityConfiguration) {
-	lock.Lock()
-	defer lock.Unlock()
+	if _, err := runSuite(context.Background(), t, reqConfig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failRecorder is the Ginkgo testing interface used by Run
+type failRecorder struct {
+	failed bool
+}
+
+func (f *failRecorder) Fail() {
+	f.failed = true
+}
+
+// Run runs the sanity tests against the server in the configuration and
+// returns the outcome of each test. The configuration and the connection
+// are only used by this run, so Run can be called again against another
+// server. Runs in the same process are executed one at a time.
+//
+// If ctx is cancelled, the connection to the server is closed so that the
+// remaining tests fail quickly, and ctx.Err() is returned.
+func Run(ctx context.Context, reqConfig *SanityConfiguration) (*Result, error) {
+	return runSuite(ctx, &failRecorder{}, reqConfig)
+}
+
+func runSuite(ctx context.Context, t GinkgoTestingT, reqConfig *SanityConfiguration) (*Result, error) {
+	runLock.Lock()
+	defer runLock.Unlock()
+
+	run = &sanityRun{
+		ctx:               ctx,
+		config:            reqConfig,
+		unimplementedApis: make(map[string]bool),
+		ledger:            newLedger(),
+		coverage:          newAPICoverage(),
+		traffic:           newTrafficRecorder(),
+		bench:             newBenchRecorder(),
+		soak:              newSoakRecorder(),
+	}
+	defer func() { run = nil }()
+
+	id, err := newRunID(reqConfig)
+	if err != nil {
+		return nil, err
+	}
+	run.id = id
+	run.fuzzSeed = reqConfig.FuzzSeed
+	if run.fuzzSeed == 0 {
+		run.fuzzSeed = time.Now().UnixNano()
+	}
 
-	config = reqConfig
 	RegisterFailHandler(Fail)
-	RunSpecs(t, "OpenStorage SDK Test Suite")
+
+	switch reqConfig.Transport {
+	case "", transportGrpc:
+	case transportRest:
+		if len(reqConfig.GatewayAddress) == 0 {
+			return nil, fmt.Errorf("The REST transport needs the address of the gateway")
+		}
+		if run.rest, err = newRestTransport(reqConfig.GatewayAddress); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unknown transport %q, must be %s or %s",
+			reqConfig.Transport, transportGrpc, transportRest)
+	}
+
+	if reqConfig.Soak && run.rest != nil {
+		return nil, fmt.Errorf("The soak mode needs the gRPC transport to refresh tokens")
+	}
+
+	if reqConfig.SoakErrorRateIncrease != nil && *reqConfig.SoakErrorRateIncrease < 0 {
+		return nil, fmt.Errorf("The soak error rate increase cannot be a negative number of percentage points")
+	}
+
+	if reqConfig.BackupScheduleInterval != 0 && reqConfig.BackupScheduleInterval < time.Second {
+		return nil, fmt.Errorf("The backup schedule interval must be at least one second, since schedules are set in seconds")
+	}
+
+	if reqConfig.CoverageThreshold < 0 || reqConfig.CoverageThreshold > 100 {
+		return nil, fmt.Errorf("The coverage threshold must be a percentage between 0 and 100")
+	}
+
+	if run.faults, err = parseFaults(reqConfig.Faults); err != nil {
+		return nil, err
+	}
+	if err := checkBenchWorkloads(reqConfig.BenchWorkloads); err != nil {
+		return nil, err
+	}
+
+	if len(reqConfig.ReplayDir) != 0 {
+		if len(reqConfig.RecordDir) != 0 {
+			return nil, fmt.Errorf("A run cannot both record and replay calls")
+		}
+		err := Replay(ctx, reqConfig)
+		return &Result{
+			Suite:  suiteName,
+			Passed: err == nil,
+			Specs:  []SpecResult{},
+		}, err
+	}
+	if len(reqConfig.RecordDir) != 0 {
+		if reqConfig.Bench || reqConfig.Soak {
+			return nil, fmt.Errorf("Calls cannot be recorded in bench or soak mode")
+		}
+		run.fixture = newTrafficRecorder()
+		run.fixture.start()
+	}
+
+	if reqConfig.CleanupOnly {
+		err := cleanupLeftovers(ctx, reqConfig, os.Stdout)
+		return &Result{
+			Suite:  suiteName,
+			Passed: err == nil,
+			Specs:  []SpecResult{},
+		}, err
+	}
+
+	// Selection changes the Ginkgo configuration, restore it afterwards
+	savedGinkgoConfig := ginkgoconfig.GinkgoConfig
+	defer func() { ginkgoconfig.GinkgoConfig = savedGinkgoConfig }()
+	if err := applySpecSelection(reqConfig); err != nil {
+		return nil, fmt.Errorf("Invalid test selection: %v", err)
+	}
+
+	results := &recordingReporter{}
+	specReporters := []Reporter{results}
+	if reqConfig.ListSpecs {
+		ginkgoconfig.GinkgoConfig.DryRun = true
+		list := newListReporter(os.Stdout)
+		specReporters = append(specReporters, list)
+		RunSpecsWithCustomReporters(t, suiteName, specReporters)
+		if len(list.undeclared) != 0 {
+			return &results.report, fmt.Errorf("%d specs do not declare the SDK version of their API", len(list.undeclared))
+		}
+		return &results.report, nil
+	}
+
+	switch reqConfig.Phase {
+	case "":
+	case phasePreUpgrade:
+		if run.upgrade, err = newUpgradeState(stateFilename(reqConfig), run.id); err != nil {
+			return nil, err
+		}
+	case phasePostUpgrade:
+		if run.upgrade, err = loadUpgradeState(stateFilename(reqConfig)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unknown phase %q, must be %s or %s",
+			reqConfig.Phase, phasePreUpgrade, phasePostUpgrade)
+	}
+
+	if len(reqConfig.JUnitReport) != 0 {
+		specReporters = append(specReporters, newJUnitReporter(reqConfig.JUnitReport))
+	}
+	if len(reqConfig.JSONReport) != 0 {
+		specReporters = append(specReporters, newJSONReporter(reqConfig.JSONReport))
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			run.lock.Lock()
+			if run.conn != nil {
+				run.conn.Close()
+			}
+			run.lock.Unlock()
+		case <-done:
+		}
+	}()
+
+	RunSpecsWithDefaultAndCustomReporters(t, suiteName, specReporters)
+	return &results.report, ctx.Err()
 }
 
 var _ = BeforeSuite(func() {
-	var err error
+	fmt.Fprintf(GinkgoWriter, "Resources of this run are prefixed with %s-%s-\n", resourcePrefix, run.id)
+	if run.config.Fuzz {
+		fmt.Fprintf(GinkgoWriter, "Fuzzing with seed %d\n", run.fuzzSeed)
+	}
 
 	By("connecting to OpenStorage SDK endpoint")
-	conn, err = connect(config.Address)
+	interceptors := []grpc.UnaryClientInterceptor{
+		messageSizeUnaryInterceptor,
+		run.coverage.unaryInterceptor,
+		run.sdkVersionUnaryInterceptor,
+		run.ledgerUnaryInterceptor,
+		run.bench.unaryInterceptor,
+		run.soak.unaryInterceptor,
+	}
+	streamInterceptors := []grpc.StreamClientInterceptor{
+		run.coverage.streamInterceptor,
+	}
+	// The calls of the benchmarks and of the soak are not recorded, since
+	// they would use memory and time for the whole run
+	if !run.config.Bench && !run.config.Soak {
+		interceptors = append(interceptors, run.traffic.unaryInterceptor)
+		streamInterceptors = append(streamInterceptors, run.traffic.streamInterceptor)
+	}
+	if run.fixture != nil {
+		interceptors = append(interceptors, run.fixture.unaryInterceptor)
+		streamInterceptors = append(streamInterceptors, run.fixture.streamInterceptor)
+	}
+	if run.rest != nil {
+		interceptors = append(interceptors, run.rest.unaryInterceptor)
+	}
+	address := run.config.Address
+	if run.config.FaultProxy || len(run.config.Faults) != 0 {
+		proxy, err := newFaultProxy(address, run.faults)
+		Expect(err).NotTo(HaveOccurred())
+		run.proxy = proxy
+		address = proxy.address()
+		fmt.Fprintf(GinkgoWriter, "Injecting network faults with a proxy on %s\n", address)
+	}
+	dialOptions := []grpc.DialOption{
+		grpc.WithUnaryInterceptor(chainUnaryInterceptors(interceptors...)),
+		grpc.WithStreamInterceptor(chainStreamInterceptors(streamInterceptors...)),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxRecvMsgSize(run.config))),
+	}
+	if run.config.Soak {
+		run.tokens = newTokenCredentials(run.config.TokenLifetime)
+		dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(run.tokens))
+	}
+	conn, err := connect(run.ctx, address, dialOptions...)
 	Expect(err).NotTo(HaveOccurred())
+	run.lock.Lock()
+	run.conn = conn
+	run.lock.Unlock()
+
 	By("creating users")
-	users = createUsersTokens()
+	run.users = createUsersTokens()
+	By("getting the SDK version of the server")
+	run.serverSdkVersion, err = getServerSdkVersion(api.NewOpenStorageIdentityClient(run.conn))
+	Expect(err).NotTo(HaveOccurred())
 })
 
 var _ = AfterSuite(func() {
-	conn.Close()
+	if run.conn == nil {
+		if run.proxy != nil {
+			run.proxy.close()
+		}
+		return
+	}
+
+	failures := []string{}
+	if run.users != nil {
+		// The tokens of long runs may have expired
+		run.users = createUsersTokens()
+		By("deleting the resources left behind by the tests")
+		leaked, err := run.sweepLedger()
+		if len(leaked) != 0 {
+			list := make([]string, len(leaked))
+			for i, r := range leaked {
+				list[i] = r.String()
+			}
+			failures = append(failures, fmt.Sprintf("Tests left behind %d resources:\n%s",
+				len(leaked), strings.Join(list, "\n")))
+		}
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		if run.upgrade != nil && run.config.Phase == phasePostUpgrade {
+			By("deleting the resources of the pre-upgrade phase")
+			if err := run.deleteUpgradeResources(); err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+	}
+	run.conn.Close()
+	if run.proxy != nil {
+		run.proxy.close()
+	}
+
+	if run.fixture != nil {
+		if err := saveFixture(run.config.RecordDir, run.fixture.stop()); err != nil {
+			failures = append(failures, fmt.Sprintf("Failed to save the recorded calls to %s: %v",
+				run.config.RecordDir, err))
+		}
+	}
+
+	if methods := run.getUnimplementedApis(); len(methods) != 0 {
+		failures = append(failures, fmt.Sprintf("Server reports SDK version %s but does not implement: %s",
+			sdkVersionString(run.serverSdkVersion),
+			strings.Join(methods, ", ")))
+	}
+	if run.rest != nil {
+		if mismatches := run.rest.getStatusMismatches(); len(mismatches) != 0 {
+			failures = append(failures, fmt.Sprintf("REST gateway returned HTTP statuses which do not match the gRPC codes:\n%s",
+				strings.Join(mismatches, "\n")))
+		}
+	}
+	if err := run.reportErrorCodeDeviations(os.Stdout); err != nil {
+		failures = append(failures, err.Error())
+	}
+	if err := run.bench.report(os.Stdout, run.config.BenchReport); err != nil {
+		failures = append(failures, err.Error())
+	}
+	if err := run.soak.report(os.Stdout, run.config.SoakReport); err != nil {
+		failures = append(failures, err.Error())
+	}
+	if d, err := getSdkDescriptors(); err != nil {
+		failures = append(failures, err.Error())
+	} else if percent := run.coverage.report(os.Stdout, d); percent < run.config.CoverageThreshold {
+		failures = append(failures, fmt.Sprintf("Tests called %.1f%% of the RPCs of the SDK, below the threshold of %.1f%%",
+			percent, run.config.CoverageThreshold))
+	}
+	if len(failures) != 0 {
+		Fail(strings.Join(failures, "\n"))
+	}
 })
 
+// chainUnaryInterceptors returns an interceptor which calls the interceptors
+// in order, the first one being the outermost. gRPC only accepts a single
+// unary interceptor per connection.
+func chainUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		chained := invoker
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(
+				ctx context.Context,
+				method string,
+				req, reply interface{},
+				cc *grpc.ClientConn,
+				opts ...grpc.CallOption,
+			) error {
+				return interceptor(ctx, method, req, reply, cc, next, opts...)
+			}
+		}
+		return chained(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// chainStreamInterceptors is chainUnaryInterceptors for streaming calls
+func chainStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		chained := streamer
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(
+				ctx context.Context,
+				desc *grpc.StreamDesc,
+				cc *grpc.ClientConn,
+				method string,
+				opts ...grpc.CallOption,
+			) (grpc.ClientStream, error) {
+				return interceptor(ctx, desc, cc, method, next, opts...)
+			}
+		}
+		return chained(ctx, desc, cc, method, opts...)
+	}
+}
+
 // Connect address by grpc
-func connect(address string) (*grpc.ClientConn, error) {
-	dialOptions := []grpc.DialOption{
+func connect(
+	ctx context.Context,
+	address string,
+	opts ...grpc.DialOption,
+) (*grpc.ClientConn, error) {
+	dialOptions := append([]grpc.DialOption{
 		grpc.WithInsecure(),
-	}
-	u, err := url.Parse(address)
-	if err == nil && (!u.IsAbs() || u.Scheme == "unix") {
+	}, opts...)
+	if network, path := endpointNetwork(address); network == "unix" {
 		dialOptions = append(dialOptions,
 			grpc.WithDialer(
 				func(addr string, timeout time.Duration) (net.Conn, error) {
-					return net.DialTimeout("unix", u.Path, timeout)
+					return net.DialTimeout(network, path, timeout)
 				}))
 	}
 
-	conn, err := grpc.Dial(address, dialOptions...)
+	conn, err := grpc.DialContext(ctx, address, dialOptions...)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
 	defer cancel()
 	for {
 		if !conn.WaitForStateChange(ctx, conn.GetState()) {
@@ -0,0 +1,456 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	// defaultMaxRecvMsgSize is the default limit of gRPC on the size of the
+	// responses received by the client
+	defaultMaxRecvMsgSize = 4 * 1024 * 1024
+
+	// scaleWorkers is the number of concurrent creates and deletes of the
+	// scale tests
+	scaleWorkers = 16
+	// scaleBatch is the number of volumes created with a single Spec.Scale
+	// create, and labelled as a batch
+	scaleBatch = 100
+	// scaleSnapshotParents is the number of volumes the snapshots of the
+	// scale tests are taken of
+	scaleSnapshotParents = 10
+
+	scaleLabel      = "sdk-test-scale"
+	scaleBatchLabel = "sdk-test-scale-batch"
+)
+
+// maxRecvMsgSize returns the limit on the size of the responses received by
+// the client
+func maxRecvMsgSize(c *SanityConfiguration) int {
+	if c.MaxRecvMsgSize > 0 {
+		return c.MaxRecvMsgSize
+	}
+	return defaultMaxRecvMsgSize
+}
+
+// messageSizeUnaryInterceptor explains the errors of the responses larger
+// than the limit of the client, which gRPC reports like any other
+// codes.ResourceExhausted
+func messageSizeUnaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if status.Code(err) != codes.ResourceExhausted {
+		return err
+	}
+
+	// The options include the default ones of the connection, the last
+	// limit is the one of the call
+	limit := 0
+	for _, opt := range opts {
+		if o, ok := opt.(grpc.MaxRecvMsgSizeCallOption); ok {
+			limit = o.MaxRecvMsgSize
+		}
+	}
+	message := status.Convert(err).Message()
+	if !strings.Contains(message, "received message larger than max") ||
+		!strings.HasSuffix(message, fmt.Sprintf(" vs. %d)", limit)) {
+		return err
+	}
+	return status.Errorf(codes.ResourceExhausted,
+		"The response of %s is larger than the limit of %d bytes of the client, raise it with --sdk.max-recv-msg-size: %s",
+		method, limit, message)
+}
+
+// forEachParallel calls f for every index up to n from scaleWorkers
+// goroutines. The remaining indexes are skipped after a failure.
+func forEachParallel(n int, f func(i int)) {
+	indexes := make(chan int)
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		failed bool
+	)
+	for w := 0; w < scaleWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				func() {
+					defer GinkgoRecover()
+					defer func() {
+						if r := recover(); r != nil {
+							lock.Lock()
+							failed = true
+							lock.Unlock()
+							panic(r)
+						}
+					}()
+					f(i)
+				}()
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		lock.Lock()
+		stop := failed
+		lock.Unlock()
+		if stop {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// expectExactSet checks that the IDs returned by an enumeration are exactly
+// the expected ones, without duplicates
+func expectExactSet(what string, actual, expected []string) {
+	missing, unexpected, duplicates := []string{}, []string{}, []string{}
+	seen := make(map[string]bool)
+	for _, id := range actual {
+		if seen[id] {
+			duplicates = append(duplicates, id)
+		}
+		seen[id] = true
+	}
+	want := make(map[string]bool)
+	for _, id := range expected {
+		want[id] = true
+		if !seen[id] {
+			missing = append(missing, id)
+		}
+	}
+	for id := range seen {
+		if !want[id] {
+			unexpected = append(unexpected, id)
+		}
+	}
+	sort.Strings(unexpected)
+
+	Expect(missing).To(BeEmpty(), "%s is missing %d of %d IDs", what, len(missing), len(expected))
+	Expect(unexpected).To(BeEmpty(), "%s returned %d unexpected IDs", what, len(unexpected))
+	Expect(duplicates).To(BeEmpty(), "%s returned %d IDs more than once", what, len(duplicates))
+}
+
+// expectContainsAll checks that an enumeration of every resource returned the
+// expected IDs, once each
+func expectContainsAll(what string, actual, expected []string) {
+	want := make(map[string]bool)
+	for _, id := range expected {
+		want[id] = true
+	}
+	ours := []string{}
+	for _, id := range actual {
+		if want[id] {
+			ours = append(ours, id)
+		}
+	}
+	expectExactSet(what, ours, expected)
+}
+
+// reportResponseSize prints the size of a response next to the limit of the
+// client
+func reportResponseSize(what string, resp proto.Message) {
+	fmt.Fprintf(GinkgoWriter, "%s response is %d bytes, the limit of the client is %d bytes\n",
+		what, proto.Size(resp), maxRecvMsgSize(run.config))
+}
+
+// createScaleVolumes creates count volumes with the labels, and returns
+// their IDs by batch. It first creates a batch with Spec.Scale, and creates
+// the volumes one by one when the driver ignores it.
+func createScaleVolumes(ctx context.Context, vc api.OpenStorageVolumeClient, count int, labels map[string]string) [][]string {
+	batchLabels := func(batch int) map[string]string {
+		l := map[string]string{scaleBatchLabel: strconv.Itoa(batch)}
+		for k, v := range labels {
+			l[k] = v
+		}
+		return l
+	}
+	batchSize := func(batch int) int {
+		if n := count - batch*scaleBatch; n < scaleBatch {
+			return n
+		}
+		return scaleBatch
+	}
+	batches := make([][]string, (count+scaleBatch-1)/scaleBatch)
+
+	scaled := func(batch int) bool {
+		_, err := vc.Create(ctx, &api.SdkVolumeCreateRequest{
+			Name:   genName("vol"),
+			Labels: batchLabels(batch),
+			Spec: &api.VolumeSpec{
+				Size:    uint64(GIGABYTE),
+				HaLevel: 1,
+				Format:  api.FSType_FS_TYPE_EXT4,
+				Scale:   uint32(batchSize(batch)),
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		resp, err := vc.EnumerateWithFilters(ctx, &api.SdkVolumeEnumerateWithFiltersRequest{
+			Labels: batchLabels(batch),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		ids := resp.GetVolumeIds()
+		// The ledger only saw the first volume
+		for _, id := range ids {
+			run.ledger.add(resource{kind: kindVolume, id: id})
+		}
+		batches[batch] = ids
+		if len(ids) == 1 && batchSize(batch) != 1 {
+			return false
+		}
+		Expect(ids).To(HaveLen(batchSize(batch)),
+			"A create with Spec.Scale %d created %d volumes", batchSize(batch), len(ids))
+		return true
+	}
+
+	if scaled(0) {
+		By("creating the volumes with Spec.Scale")
+		forEachParallel(len(batches)-1, func(i int) {
+			scaled(i + 1)
+		})
+		return batches
+	}
+
+	By("creating the volumes one by one, the driver ignores Spec.Scale")
+	var lock sync.Mutex
+	forEachParallel(count-1, func(i int) {
+		batch := (i + 1) / scaleBatch
+		resp, err := vc.Create(ctx, &api.SdkVolumeCreateRequest{
+			Name:   genName("vol"),
+			Labels: batchLabels(batch),
+			Spec: &api.VolumeSpec{
+				Size:    uint64(GIGABYTE),
+				HaLevel: 1,
+				Format:  api.FSType_FS_TYPE_EXT4,
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		lock.Lock()
+		batches[batch] = append(batches[batch], resp.GetVolumeId())
+		lock.Unlock()
+	})
+	return batches
+}
+
+// deleteParallel deletes the volumes or snapshots
+func deleteParallel(ctx context.Context, vc api.OpenStorageVolumeClient, ids []string) {
+	forEachParallel(len(ids), func(i int) {
+		_, err := vc.Delete(ctx, &api.SdkVolumeDeleteRequest{VolumeId: ids[i]})
+		Expect(err).NotTo(HaveOccurred())
+	})
+}
+
+var _ = Describe("Scale [Scale]", func() {
+	var (
+		vc     api.OpenStorageVolumeClient
+		ctx    context.Context
+		volIDs []string
+	)
+
+	BeforeEach(func() {
+		skipUnsupportedService("OpenStorageVolume")
+		vc = api.NewOpenStorageVolumeClient(run.conn)
+		ctx = setContextWithToken(context.Background(), run.users["admin"])
+		volIDs = nil
+	})
+
+	AfterEach(func() {
+		deleteParallel(ctx, vc, volIDs)
+	})
+
+	Describe("Volumes [OpenStorageVolume]", func() {
+
+		It("should enumerate exactly the volumes created", func() {
+			count := run.config.ScaleVolumes
+			if count <= 0 {
+				Skip("Set --sdk.scale-volumes to the number of volumes to create")
+			}
+			labels := map[string]string{scaleLabel: genName("scale")}
+
+			By(fmt.Sprintf("creating %d labelled volumes", count))
+			batches := createScaleVolumes(ctx, vc, count, labels)
+			for _, batch := range batches {
+				volIDs = append(volIDs, batch...)
+			}
+			Expect(volIDs).To(HaveLen(count))
+
+			By("enumerating every volume")
+			all, err := vc.Enumerate(ctx, &api.SdkVolumeEnumerateRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			reportResponseSize("Enumerate", all)
+			expectContainsAll("Enumerate", all.GetVolumeIds(), volIDs)
+
+			By("enumerating the volumes by label")
+			filtered, err := vc.EnumerateWithFilters(ctx, &api.SdkVolumeEnumerateWithFiltersRequest{
+				Labels: labels,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			reportResponseSize("EnumerateWithFilters", filtered)
+			expectExactSet("EnumerateWithFilters", filtered.GetVolumeIds(), volIDs)
+
+			By("enumerating each batch of volumes by two labels")
+			for i, batch := range batches {
+				resp, err := vc.EnumerateWithFilters(ctx, &api.SdkVolumeEnumerateWithFiltersRequest{
+					Labels: map[string]string{
+						scaleLabel:      labels[scaleLabel],
+						scaleBatchLabel: strconv.Itoa(i),
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				expectExactSet(fmt.Sprintf("EnumerateWithFilters of batch %d", i), resp.GetVolumeIds(), batch)
+			}
+
+			By("enumerating a label value no volume has")
+			resp, err := vc.EnumerateWithFilters(ctx, &api.SdkVolumeEnumerateWithFiltersRequest{
+				Labels: map[string]string{scaleLabel: genName("none")},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetVolumeIds()).To(BeEmpty())
+		})
+	})
+
+	Describe("Snapshots [Snapshot]", func() {
+		var snapIDs []string
+
+		BeforeEach(func() {
+			snapIDs = nil
+		})
+
+		AfterEach(func() {
+			// Before the volumes are deleted
+			deleteParallel(ctx, vc, snapIDs)
+		})
+
+		It("should enumerate exactly the snapshots created", func() {
+			count := run.config.ScaleSnapshots
+			if count <= 0 {
+				Skip("Set --sdk.scale-snapshots to the number of snapshots to create")
+			}
+			labels := map[string]string{scaleLabel: genName("scale")}
+
+			parents := scaleSnapshotParents
+			if count < parents {
+				parents = count
+			}
+			for i := 0; i < parents; i++ {
+				volIDs = append(volIDs, newTestVolume(vc))
+			}
+
+			By(fmt.Sprintf("creating %d labelled snapshots of %d volumes", count, parents))
+			snapIDs = make([]string, count)
+			forEachParallel(count, func(i int) {
+				resp, err := vc.SnapshotCreate(ctx, &api.SdkVolumeSnapshotCreateRequest{
+					VolumeId: volIDs[i%parents],
+					Name:     genName("snap"),
+					Labels:   labels,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				snapIDs[i] = resp.GetSnapshotId()
+			})
+
+			By("enumerating the snapshots by label")
+			filtered, err := vc.SnapshotEnumerateWithFilters(ctx, &api.SdkVolumeSnapshotEnumerateWithFiltersRequest{
+				Labels: labels,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			reportResponseSize("SnapshotEnumerateWithFilters", filtered)
+			expectExactSet("SnapshotEnumerateWithFilters by label", filtered.GetVolumeSnapshotIds(), snapIDs)
+
+			By("enumerating the snapshots of each volume")
+			for p, volID := range volIDs {
+				expected := []string{}
+				for i := p; i < count; i += parents {
+					expected = append(expected, snapIDs[i])
+				}
+				resp, err := vc.SnapshotEnumerateWithFilters(ctx, &api.SdkVolumeSnapshotEnumerateWithFiltersRequest{
+					VolumeId: volID,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				expectExactSet("SnapshotEnumerateWithFilters of volume "+volID, resp.GetVolumeSnapshotIds(), expected)
+			}
+
+			By("enumerating every snapshot")
+			all, err := vc.SnapshotEnumerate(ctx, &api.SdkVolumeSnapshotEnumerateRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			reportResponseSize("SnapshotEnumerate", all)
+			expectContainsAll("SnapshotEnumerate", all.GetVolumeSnapshotIds(), snapIDs)
+		})
+	})
+
+	Describe("Message size [OpenStorageVolume]", func() {
+
+		It("should fail clearly when a response exceeds the limit of the client", func() {
+			if run.rest != nil {
+				Skip("The REST gateway has no limit on the size of the responses")
+			}
+			labels := map[string]string{scaleLabel: genName("size")}
+			for i := 0; i < 3; i++ {
+				resp, err := vc.Create(ctx, &api.SdkVolumeCreateRequest{
+					Name:   genName("vol"),
+					Labels: labels,
+					Spec: &api.VolumeSpec{
+						Size:    uint64(GIGABYTE),
+						HaLevel: 1,
+						Format:  api.FSType_FS_TYPE_EXT4,
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				volIDs = append(volIDs, resp.GetVolumeId())
+			}
+			req := &api.SdkVolumeEnumerateWithFiltersRequest{Labels: labels}
+
+			By("enumerating within the limit of the connection")
+			resp, err := vc.EnumerateWithFilters(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			size := proto.Size(resp)
+			Expect(size).To(BeNumerically("<=", maxRecvMsgSize(run.config)))
+
+			By("enumerating with a limit one byte below the size of the response")
+			_, err = vc.EnumerateWithFilters(ctx, req, grpc.MaxCallRecvMsgSize(size-1))
+			Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+			Expect(err.Error()).To(ContainSubstring("--sdk.max-recv-msg-size"))
+			Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("%d bytes", size-1)))
+
+			By("enumerating with a limit equal to the size of the response")
+			_, err = vc.EnumerateWithFilters(ctx, req, grpc.MaxCallRecvMsgSize(size))
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
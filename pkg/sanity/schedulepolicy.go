@@ -17,30 +17,127 @@ limitations under the License.
 package sanity
 
 import (
-	"strconv"
-
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"context"
+	"fmt"
 
+	"github.com/golang/protobuf/proto"
 	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
-func numberOfSchedulePoliciesInCluster(c api.OpenStorageSchedulePolicyClient) int {
+// schedulePolicyNamesInCluster returns the names of the policies in the
+// cluster. Tests check for their own policies by name instead of counting,
+// since other runs may be creating policies in the same cluster.
+func schedulePolicyNamesInCluster(c api.OpenStorageSchedulePolicyClient) []string {
 
 	resp, err := c.Enumerate(
-		setContextWithToken(context.Background(), users["admin"]),
+		setContextWithToken(context.Background(), run.users["admin"]),
 		&api.SdkSchedulePolicyEnumerateRequest{},
 	)
 
 	Expect(err).NotTo(HaveOccurred())
 	Expect(resp).NotTo(BeNil())
 
-	return len(resp.Policies)
+	names := make([]string, 0, len(resp.Policies))
+	for _, policy := range resp.Policies {
+		names = append(names, policy.GetName())
+	}
+	return names
+}
+
+// Constructors of the intervals of the schedule policy validation cases
+func dailyInterval(hour, minute int32) *api.SdkSchedulePolicyInterval {
+	return &api.SdkSchedulePolicyInterval{
+		Retain: 2,
+		PeriodType: &api.SdkSchedulePolicyInterval_Daily{
+			Daily: &api.SdkSchedulePolicyIntervalDaily{Hour: hour, Minute: minute},
+		},
+	}
+}
+
+func weeklyInterval(day api.SdkTimeWeekday, hour, minute int32) *api.SdkSchedulePolicyInterval {
+	return &api.SdkSchedulePolicyInterval{
+		Retain: 2,
+		PeriodType: &api.SdkSchedulePolicyInterval_Weekly{
+			Weekly: &api.SdkSchedulePolicyIntervalWeekly{Day: day, Hour: hour, Minute: minute},
+		},
+	}
+}
+
+func monthlyInterval(day, hour, minute int32) *api.SdkSchedulePolicyInterval {
+	return &api.SdkSchedulePolicyInterval{
+		Retain: 2,
+		PeriodType: &api.SdkSchedulePolicyInterval_Monthly{
+			Monthly: &api.SdkSchedulePolicyIntervalMonthly{Day: day, Hour: hour, Minute: minute},
+		},
+	}
+}
+
+func periodicInterval(seconds int64) *api.SdkSchedulePolicyInterval {
+	return &api.SdkSchedulePolicyInterval{
+		Retain: 2,
+		PeriodType: &api.SdkSchedulePolicyInterval_Periodic{
+			Periodic: &api.SdkSchedulePolicyIntervalPeriodic{Seconds: seconds},
+		},
+	}
+}
+
+// manyIntervals returns one daily interval for every hour of the day and one
+// interval of each other period type
+func manyIntervals() []*api.SdkSchedulePolicyInterval {
+	schedules := []*api.SdkSchedulePolicyInterval{
+		weeklyInterval(api.SdkTimeWeekday_SdkTimeWeekdaySaturday, 6, 0),
+		monthlyInterval(15, 6, 0),
+		periodicInterval(3600),
+	}
+	for hour := int32(0); hour < 24; hour++ {
+		schedules = append(schedules, dailyInterval(hour, 30))
+	}
+	return schedules
+}
+
+// schedulePolicyValidationCases are the boundaries of the fields of every
+// period type. Valid policies must be created and inspect unchanged, the
+// others must be rejected with InvalidArgument.
+var schedulePolicyValidationCases = []struct {
+	description string
+	valid       bool
+	schedules   []*api.SdkSchedulePolicyInterval
+}{
+	{"the first daily time", true, []*api.SdkSchedulePolicyInterval{dailyInterval(0, 0)}},
+	{"the last daily time", true, []*api.SdkSchedulePolicyInterval{dailyInterval(23, 59)}},
+	{"a daily hour of 24", false, []*api.SdkSchedulePolicyInterval{dailyInterval(24, 0)}},
+	{"a negative daily hour", false, []*api.SdkSchedulePolicyInterval{dailyInterval(-1, 0)}},
+	{"a daily minute of 60", false, []*api.SdkSchedulePolicyInterval{dailyInterval(0, 60)}},
+	{"a negative daily minute", false, []*api.SdkSchedulePolicyInterval{dailyInterval(0, -1)}},
+
+	{"the first weekly time", true, []*api.SdkSchedulePolicyInterval{weeklyInterval(api.SdkTimeWeekday_SdkTimeWeekdaySunday, 0, 0)}},
+	{"the last weekly time", true, []*api.SdkSchedulePolicyInterval{weeklyInterval(api.SdkTimeWeekday_SdkTimeWeekdaySaturday, 23, 59)}},
+	{"a weekly hour of 24", false, []*api.SdkSchedulePolicyInterval{weeklyInterval(api.SdkTimeWeekday_SdkTimeWeekdayMonday, 24, 0)}},
+	{"a weekly minute of 60", false, []*api.SdkSchedulePolicyInterval{weeklyInterval(api.SdkTimeWeekday_SdkTimeWeekdayMonday, 0, 60)}},
+	{"an unknown weekday", false, []*api.SdkSchedulePolicyInterval{weeklyInterval(api.SdkTimeWeekday(7), 12, 0)}},
+	{"a negative weekday", false, []*api.SdkSchedulePolicyInterval{weeklyInterval(api.SdkTimeWeekday(-1), 12, 0)}},
+
+	{"the first monthly time", true, []*api.SdkSchedulePolicyInterval{monthlyInterval(1, 0, 0)}},
+	{"the last monthly time", true, []*api.SdkSchedulePolicyInterval{monthlyInterval(31, 23, 59)}},
+	{"a monthly day of 0", false, []*api.SdkSchedulePolicyInterval{monthlyInterval(0, 12, 0)}},
+	{"a monthly day of 32", false, []*api.SdkSchedulePolicyInterval{monthlyInterval(32, 12, 0)}},
+	{"a monthly hour of 24", false, []*api.SdkSchedulePolicyInterval{monthlyInterval(1, 24, 0)}},
+	{"a monthly minute of 60", false, []*api.SdkSchedulePolicyInterval{monthlyInterval(1, 0, 60)}},
+
+	{"a periodic interval of one second", true, []*api.SdkSchedulePolicyInterval{periodicInterval(1)}},
+	{"a periodic interval of a week", true, []*api.SdkSchedulePolicyInterval{periodicInterval(7 * 24 * 3600)}},
+	{"a periodic interval of 0 seconds", false, []*api.SdkSchedulePolicyInterval{periodicInterval(0)}},
+	{"a negative periodic interval", false, []*api.SdkSchedulePolicyInterval{periodicInterval(-60)}},
+
+	{"a schedule without a period", false, []*api.SdkSchedulePolicyInterval{&api.SdkSchedulePolicyInterval{Retain: 2}}},
+	{"many schedules of every period type", true, manyIntervals()},
+	{"an invalid schedule among valid ones", false, append(manyIntervals(), monthlyInterval(32, 0, 0))},
 }
 
 var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
@@ -50,8 +147,8 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 	)
 
 	BeforeEach(func() {
-		c = api.NewOpenStorageSchedulePolicyClient(conn)
-		ic = api.NewOpenStorageIdentityClient(conn)
+		c = api.NewOpenStorageSchedulePolicyClient(run.conn)
+		ic = api.NewOpenStorageIdentityClient(run.conn)
 
 		isSupported := isCapabilitySupported(
 			ic,
@@ -67,13 +164,10 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 
 		var (
 			policyName                string
-			policiesBefore            int
-			policiesAfter             int
 			isPolicyCreatedInTestCase bool
 		)
 
 		BeforeEach(func() {
-			policiesBefore = numberOfSchedulePoliciesInCluster(c)
 			policyName = ""
 			isPolicyCreatedInTestCase = false
 		})
@@ -86,7 +180,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 			if isPolicyCreatedInTestCase {
 
 				resp, err := c.Delete(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkSchedulePolicyDeleteRequest{
 						Name: policyName,
 					},
@@ -97,9 +191,9 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 		})
 
 		It("Should create schedule policy", func() {
-			policyName = "create-test-policy"
+			policyName = genName("policy")
 			resp, err := c.Create(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyCreateRequest{
 					SchedulePolicy: &api.SdkSchedulePolicy{
 						Name: policyName,
@@ -121,14 +215,13 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 			Expect(resp).NotTo(BeNil())
 
 			// Test if the policy got created.
-			policiesAfter = numberOfSchedulePoliciesInCluster(c)
-			Expect(policiesAfter).To(BeEquivalentTo(policiesBefore + 1))
+			Expect(schedulePolicyNamesInCluster(c)).To(ContainElement(policyName))
 			isPolicyCreatedInTestCase = true
 		})
 
 		It("Should fail to create policy for empty name", func() {
 			resp, err := c.Create(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyCreateRequest{
 					SchedulePolicy: &api.SdkSchedulePolicy{
 						Name: policyName,
@@ -154,9 +247,9 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 		})
 
 		It("Should fail to create policy if retention less than 0", func() {
-			policyName = "test-policy-retention"
+			policyName = genName("policy")
 			resp, err := c.Create(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyCreateRequest{
 					SchedulePolicy: &api.SdkSchedulePolicy{
 						Name: policyName,
@@ -184,7 +277,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 
 		It("Should fail to create policy for nil object", func() {
 			resp, err := c.Create(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyCreateRequest{
 					SchedulePolicy: &api.SdkSchedulePolicy{
 						Name:      policyName,
@@ -202,22 +295,19 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 
 	Describe("Delete", func() {
 		var (
-			policyName     string
-			policiesBefore int
-			policiesAfter  int
+			policyName string
 		)
 
 		BeforeEach(func() {
-			policiesBefore = numberOfSchedulePoliciesInCluster(c)
 			policyName = ""
 		})
 
 		It("Should delete a schedule policy", func() {
 
 			By("First create a policy")
-			policyName = "delete-test-policy"
+			policyName = genName("policy")
 			resp, err := c.Create(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyCreateRequest{
 					SchedulePolicy: &api.SdkSchedulePolicy{
 						Name: policyName,
@@ -240,11 +330,10 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 			Expect(resp).NotTo(BeNil())
 
 			// Test if the policy got created.
-			policiesAfter = numberOfSchedulePoliciesInCluster(c)
-			Expect(policiesAfter).To(BeEquivalentTo(policiesBefore + 1))
+			Expect(schedulePolicyNamesInCluster(c)).To(ContainElement(policyName))
 
 			deleteResponse, err := c.Delete(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyDeleteRequest{
 					Name: policyName,
 				},
@@ -261,7 +350,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 		// 	policyName = "policy-doesnt-exist"
 
 		// 	resp, err := c.Delete(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkSchedulePolicyDeleteRequest{
 		// 			Name: policyName,
 		// 		},
@@ -277,7 +366,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 		It("Should fail to delete a schedule policy with empty name", func() {
 
 			resp, err := c.Delete(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyDeleteRequest{
 					Name: policyName,
 				},
@@ -295,13 +384,10 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 
 		var (
 			policyName                string
-			policiesBefore            int
-			policiesAfter             int
 			isPolicyCreatedInTestCase bool
 		)
 
 		BeforeEach(func() {
-			policiesBefore = numberOfSchedulePoliciesInCluster(c)
 			policyName = ""
 			isPolicyCreatedInTestCase = false
 		})
@@ -314,7 +400,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 			if isPolicyCreatedInTestCase {
 
 				resp, err := c.Delete(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkSchedulePolicyDeleteRequest{
 						Name: policyName,
 					},
@@ -326,7 +412,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 
 		It("Should inspect a schedule policy", func() {
 
-			policyName = "inspect-test-policy"
+			policyName = genName("policy")
 			policy := &api.SdkSchedulePolicyCreateRequest{
 				SchedulePolicy: &api.SdkSchedulePolicy{
 					Name: policyName,
@@ -346,21 +432,20 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 
 			By("First create a policy")
 			resp, err := c.Create(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				policy,
 			)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(resp).NotTo(BeNil())
 
 			// Test if the policy got created.
-			policiesAfter = numberOfSchedulePoliciesInCluster(c)
-			Expect(policiesAfter).To(BeEquivalentTo(policiesBefore + 1))
+			Expect(schedulePolicyNamesInCluster(c)).To(ContainElement(policyName))
 			isPolicyCreatedInTestCase = true
 
 			By("Inspecting the created policy")
 
 			inspectResponse, err := c.Inspect(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyInspectRequest{
 					Name: policyName,
 				},
@@ -383,7 +468,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 		// 	policyName = "policy-doesnt-exist"
 
 		// 	resp, err := c.Delete(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkSchedulePolicyDeleteRequest{
 		// 			Name: policyName,
 		// 		},
@@ -399,7 +484,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 		It("Should fail to inspect a policy of empty name", func() {
 
 			resp, err := c.Delete(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyDeleteRequest{
 					Name: policyName,
 				},
@@ -418,13 +503,12 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 		var (
 			policyNames               []string
 			policiesBefore            int
-			policiesAfter             int
 			isPolicyCreatedInTestCase bool
 			count                     int
 		)
 
 		BeforeEach(func() {
-			policiesBefore = numberOfSchedulePoliciesInCluster(c)
+			policiesBefore = len(schedulePolicyNamesInCluster(c))
 			count = 5
 			isPolicyCreatedInTestCase = false
 			policyNames = []string{}
@@ -439,7 +523,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 				for _, policyName := range policyNames {
 
 					resp, err := c.Delete(
-						setContextWithToken(context.Background(), users["admin"]),
+						setContextWithToken(context.Background(), run.users["admin"]),
 						&api.SdkSchedulePolicyDeleteRequest{
 							Name: policyName,
 						},
@@ -454,9 +538,9 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 
 			By("Creating 5 schedule policies")
 			for i := 0; i < count; i++ {
-				policyName := "test-policy" + strconv.Itoa(i)
+				policyName := genName("policy")
 				resp, err := c.Create(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkSchedulePolicyCreateRequest{
 						SchedulePolicy: &api.SdkSchedulePolicy{
 							Name: policyName,
@@ -480,25 +564,30 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 			}
 
 			// Test if the policies got created.
-			policiesAfter = numberOfSchedulePoliciesInCluster(c)
-			Expect(policiesAfter).To(BeEquivalentTo(policiesBefore + count))
+			Expect(policyNames).To(HaveLen(count))
 			isPolicyCreatedInTestCase = true
 
 			By("Enumerating all the policies in the cluster")
 
 			enumerateResponse, err := c.Enumerate(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyEnumerateRequest{},
 			)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(len(enumerateResponse.Policies)).To(BeEquivalentTo(policiesAfter))
+			names := []string{}
+			for _, policy := range enumerateResponse.Policies {
+				names = append(names, policy.GetName())
+			}
+			for _, policyName := range policyNames {
+				Expect(names).To(ContainElement(policyName))
+			}
 		})
 
 		It("Should not fail but return zero if there are no policies in the cluster", func() {
 
 			if policiesBefore == 0 {
 				resp, err := c.Enumerate(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkSchedulePolicyEnumerateRequest{},
 				)
 
@@ -513,13 +602,10 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 
 		var (
 			policyName                string
-			policiesBefore            int
-			policiesAfter             int
 			isPolicyCreatedInTestCase bool
 		)
 
 		BeforeEach(func() {
-			policiesBefore = numberOfSchedulePoliciesInCluster(c)
 			policyName = ""
 			isPolicyCreatedInTestCase = false
 		})
@@ -532,7 +618,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 			if isPolicyCreatedInTestCase {
 
 				resp, err := c.Delete(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkSchedulePolicyDeleteRequest{
 						Name: policyName,
 					},
@@ -545,9 +631,9 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 		It("Should update an existing schedule policy successfully", func() {
 
 			By("Creating a schedule policy first")
-			policyName = "update-test-policy"
+			policyName = genName("policy")
 			resp, err := c.Create(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyCreateRequest{
 					SchedulePolicy: &api.SdkSchedulePolicy{
 						Name: policyName,
@@ -569,8 +655,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 			Expect(resp).NotTo(BeNil())
 
 			// Test if the policy got created.
-			policiesAfter = numberOfSchedulePoliciesInCluster(c)
-			Expect(policiesAfter).To(BeEquivalentTo(policiesBefore + 1))
+			Expect(schedulePolicyNamesInCluster(c)).To(ContainElement(policyName))
 			isPolicyCreatedInTestCase = true
 
 			By("Updating the schedule policy")
@@ -593,7 +678,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 				},
 			}
 			updateResponse, err := c.Update(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				update,
 			)
 
@@ -602,7 +687,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 
 			By("Inspecting the updated schedule policy")
 			inspectResponse, err := c.Inspect(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyInspectRequest{
 					Name: policyName,
 				},
@@ -628,9 +713,9 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 		It("Should fail to update the name of the schedule policy", func() {
 
 			By("Creating a schedule policy first")
-			policyName = "fail-test-policy-update"
+			policyName = genName("policy")
 			resp, err := c.Create(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkSchedulePolicyCreateRequest{
 					SchedulePolicy: &api.SdkSchedulePolicy{
 						Name: policyName,
@@ -652,15 +737,14 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 			Expect(resp).NotTo(BeNil())
 
 			// Test if the policy got created.
-			policiesAfter = numberOfSchedulePoliciesInCluster(c)
-			Expect(policiesAfter).To(BeEquivalentTo(policiesBefore + 1))
+			Expect(schedulePolicyNamesInCluster(c)).To(ContainElement(policyName))
 			isPolicyCreatedInTestCase = true
 
 			By("Updating the schedule policy")
 
 			update := &api.SdkSchedulePolicyUpdateRequest{
 				SchedulePolicy: &api.SdkSchedulePolicy{
-					Name: "policy-name-changed",
+					Name: genName("policy"),
 					Schedules: []*api.SdkSchedulePolicyInterval{
 						&api.SdkSchedulePolicyInterval{
 							Retain: 5,
@@ -676,7 +760,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 				},
 			}
 			updateResponse, err := c.Update(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				update,
 			)
 
@@ -708,7 +792,7 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 				},
 			}
 			updateResponse, err := c.Update(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				update,
 			)
 			Expect(err).To(HaveOccurred())
@@ -719,4 +803,121 @@ var _ = Describe("SchedulePolicy [OpenStorageSchedulePolicy]", func() {
 			Expect(serverError.Code()).To(BeEquivalentTo(codes.InvalidArgument))
 		})
 	})
+
+	Describe("Validation", func() {
+
+		var (
+			policyNames []string
+		)
+
+		BeforeEach(func() {
+			policyNames = nil
+		})
+
+		AfterEach(func() {
+			for _, name := range policyNames {
+				_, err := c.Delete(
+					setContextWithToken(context.Background(), run.users["admin"]),
+					&api.SdkSchedulePolicyDeleteRequest{
+						Name: name,
+					},
+				)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		// expectRoundTrip checks the policy inspects as it was created
+		expectRoundTrip := func(policy *api.SdkSchedulePolicy) {
+			inspectResponse, err := c.Inspect(
+				setContextWithToken(context.Background(), run.users["admin"]),
+				&api.SdkSchedulePolicyInspectRequest{
+					Name: policy.GetName(),
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			if !proto.Equal(inspectResponse.GetPolicy(), policy) {
+				Fail(fmt.Sprintf("Schedule policy %s changed:\ncreated:   %s\ninspected: %s",
+					policy.GetName(),
+					proto.CompactTextString(policy),
+					proto.CompactTextString(inspectResponse.GetPolicy())))
+			}
+		}
+
+		for _, tc := range schedulePolicyValidationCases {
+			tc := tc
+
+			if tc.valid {
+				It("Should accept a policy with "+tc.description, func() {
+					policy := &api.SdkSchedulePolicy{
+						Name:      genName("policy"),
+						Schedules: tc.schedules,
+					}
+					_, err := c.Create(
+						setContextWithToken(context.Background(), run.users["admin"]),
+						&api.SdkSchedulePolicyCreateRequest{
+							SchedulePolicy: policy,
+						},
+					)
+					Expect(err).NotTo(HaveOccurred())
+					policyNames = append(policyNames, policy.GetName())
+
+					expectRoundTrip(policy)
+				})
+				continue
+			}
+
+			It("Should reject a policy with "+tc.description, func() {
+				name := genName("policy")
+				resp, err := c.Create(
+					setContextWithToken(context.Background(), run.users["admin"]),
+					&api.SdkSchedulePolicyCreateRequest{
+						SchedulePolicy: &api.SdkSchedulePolicy{
+							Name:      name,
+							Schedules: tc.schedules,
+						},
+					},
+				)
+				if err == nil {
+					policyNames = append(policyNames, name)
+				}
+				Expect(err).To(HaveOccurred())
+				Expect(resp).To(BeNil())
+				serverError, ok := status.FromError(err)
+				Expect(ok).To(BeTrue())
+				Expect(serverError.Code()).To(BeEquivalentTo(codes.InvalidArgument))
+				Expect(schedulePolicyNamesInCluster(c)).NotTo(ContainElement(name))
+			})
+		}
+
+		It("Should reject a policy with a duplicate name", func() {
+			policy := &api.SdkSchedulePolicy{
+				Name:      genName("policy"),
+				Schedules: []*api.SdkSchedulePolicyInterval{dailyInterval(12, 30)},
+			}
+			_, err := c.Create(
+				setContextWithToken(context.Background(), run.users["admin"]),
+				&api.SdkSchedulePolicyCreateRequest{
+					SchedulePolicy: policy,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			policyNames = append(policyNames, policy.GetName())
+
+			By("Creating another policy with the same name")
+			_, err = c.Create(
+				setContextWithToken(context.Background(), run.users["admin"]),
+				&api.SdkSchedulePolicyCreateRequest{
+					SchedulePolicy: &api.SdkSchedulePolicy{
+						Name:      policy.GetName(),
+						Schedules: []*api.SdkSchedulePolicyInterval{periodicInterval(60)},
+					},
+				},
+			)
+			Expect(err).To(HaveOccurred())
+			Expect(status.Code(err)).To(BeEquivalentTo(codes.AlreadyExists))
+
+			By("Checking the first policy was not replaced")
+			expectRoundTrip(policy)
+		})
+	})
 })
@@ -0,0 +1,294 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	ginkgoconfig "github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+// sdkService maps a service name which can be selected by the user to the
+// tag used in the Describe titles of its tests
+type sdkService struct {
+	name       string
+	tag        string
+	capability api.SdkServiceCapability_OpenStorageService_Type
+	// suite is set for the suites which test several services, like
+	// errorcodes. Their specs are also tagged with the services they call,
+	// but only run for those services if the suite is selected.
+	suite bool
+}
+
+// sdkServices are the services which can be selected. Services which do not
+// need a capability use SdkServiceCapability_OpenStorageService_UNKNOWN.
+var sdkServices = []sdkService{
+	{"identity", "[OpenStorageIdentity]", api.SdkServiceCapability_OpenStorageService_UNKNOWN, false},
+	{"cluster", "[OpenStorageCluster]", api.SdkServiceCapability_OpenStorageService_CLUSTER, false},
+	{"node", "[OpenStorageNode]", api.SdkServiceCapability_OpenStorageService_NODE, false},
+	{"volume", "[OpenStorageVolume]", api.SdkServiceCapability_OpenStorageService_VOLUME, false},
+	{"snapshot", "[Snapshot]", api.SdkServiceCapability_OpenStorageService_VOLUME, false},
+	{"credentials", "[OpenStorageCredentials]", api.SdkServiceCapability_OpenStorageService_CREDENTIALS, false},
+	{"schedulepolicy", "[OpenStorageSchedulePolicy]", api.SdkServiceCapability_OpenStorageService_SCHEDULE_POLICY, false},
+	{"cloudbackup", "[OpenStorageCloudBackup]", api.SdkServiceCapability_OpenStorageService_CLOUD_BACKUP, false},
+	{"objectstore", "[OpenStorageObjectstore]", api.SdkServiceCapability_OpenStorageService_OBJECT_STORAGE, false},
+	{"role", "[OpenStorageRole]", api.SdkServiceCapability_OpenStorageService_ROLE, false},
+	{"clusterpair", "[OpenStorageClusterPair]", api.SdkServiceCapability_OpenStorageService_CLUSTER_PAIR, false},
+	{"migrate", "[OpenStorageMigrate]", api.SdkServiceCapability_OpenStorageService_MIGRATE, false},
+	{"errorcodes", "[ErrorCodes]", api.SdkServiceCapability_OpenStorageService_UNKNOWN, true},
+	{"networkfaults", "[NetworkFaults]", api.SdkServiceCapability_OpenStorageService_UNKNOWN, true},
+	{"deadlines", "[Deadlines]", api.SdkServiceCapability_OpenStorageService_UNKNOWN, true},
+	{"idempotency", "[Idempotency]", api.SdkServiceCapability_OpenStorageService_UNKNOWN, true},
+	{"races", "[Races]", api.SdkServiceCapability_OpenStorageService_UNKNOWN, true},
+	{"scale", "[Scale]", api.SdkServiceCapability_OpenStorageService_UNKNOWN, true},
+}
+
+var tagRegexp = regexp.MustCompile(`\[[^\]]+\]`)
+
+func sdkServiceNames() []string {
+	names := make([]string, len(sdkServices))
+	for i, s := range sdkServices {
+		names[i] = s.name
+	}
+	return names
+}
+
+func getSdkService(name string) (sdkService, bool) {
+	for _, s := range sdkServices {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return sdkService{}, false
+}
+
+// tagsRegexp returns a regular expression matching any of the tags
+func tagsRegexp(tags []string) string {
+	quoted := make([]string, len(tags))
+	for i, tag := range tags {
+		quoted[i] = regexp.QuoteMeta(tag)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// applySpecSelection converts the services and tags selected in the
+// configuration into Ginkgo focus and skip regular expressions
+func applySpecSelection(c *SanityConfiguration) error {
+	modes := []struct {
+		enabled bool
+		tag     string
+	}{
+		{c.Fuzz, fuzzTag},
+		{c.Bench, benchTag},
+		{c.Soak, soakTag},
+		{c.Phase == phasePreUpgrade, preUpgradeTag},
+		{c.Phase == phasePostUpgrade, postUpgradeTag},
+	}
+	enabled := 0
+	for _, mode := range modes {
+		if mode.enabled {
+			enabled++
+		}
+	}
+	if enabled > 1 {
+		return fmt.Errorf("Only one of the fuzz, benchmark, soak and upgrade modes can be selected")
+	}
+	if (len(c.Services) != 0 || enabled != 0) && len(ginkgoconfig.GinkgoConfig.FocusString) != 0 {
+		return fmt.Errorf("Services, fuzz, benchmark, soak and upgrade modes cannot be selected together with a Ginkgo focus")
+	}
+
+	// Selecting services runs their own tests. Selecting suites runs
+	// them, and when services are selected as well only their specs for
+	// those services. The tag of a suite comes before the service tags in
+	// the text of its specs.
+	focus := ""
+	skipped := []string{}
+	if len(c.Services) != 0 {
+		serviceTags, suiteTags := []string{}, []string{}
+		for _, name := range c.Services {
+			s, ok := getSdkService(strings.TrimSpace(name))
+			if !ok {
+				return fmt.Errorf("Unknown service %q, must be one of: %s",
+					name, strings.Join(sdkServiceNames(), ", "))
+			}
+			if s.suite {
+				suiteTags = append(suiteTags, s.tag)
+			} else {
+				serviceTags = append(serviceTags, s.tag)
+			}
+		}
+		switch {
+		case len(suiteTags) == 0:
+			focus = tagsRegexp(serviceTags)
+			for _, s := range sdkServices {
+				if s.suite {
+					skipped = append(skipped, s.tag)
+				}
+			}
+		case len(serviceTags) == 0:
+			focus = tagsRegexp(suiteTags)
+		default:
+			focus = "(" + tagsRegexp(suiteTags) + ").*(" + tagsRegexp(serviceTags) + ")"
+		}
+	}
+
+	// The fuzz, benchmark, soak and upgrade specs only run in their mode,
+	// and then alone. Their tag comes before the service tags in their
+	// text.
+	modeTag := ""
+	for _, mode := range modes {
+		if mode.enabled {
+			modeTag = mode.tag
+		} else {
+			skipped = append(skipped, mode.tag)
+		}
+	}
+	if len(modeTag) != 0 {
+		if len(focus) != 0 {
+			focus = regexp.QuoteMeta(modeTag) + ".*(" + focus + ")"
+		} else {
+			focus = regexp.QuoteMeta(modeTag)
+		}
+	}
+	if len(skipped) != 0 {
+		skip := tagsRegexp(skipped)
+		if len(ginkgoconfig.GinkgoConfig.SkipString) != 0 {
+			skip = ginkgoconfig.GinkgoConfig.SkipString + "|" + skip
+		}
+		ginkgoconfig.GinkgoConfig.SkipString = skip
+	}
+	if len(focus) != 0 {
+		ginkgoconfig.GinkgoConfig.FocusString = focus
+	}
+
+	if len(c.ExcludeTags) != 0 {
+		tags := make([]string, 0, len(c.ExcludeTags))
+		for _, tag := range c.ExcludeTags {
+			tag = strings.Trim(strings.TrimSpace(tag), "[]")
+			if len(tag) == 0 {
+				return fmt.Errorf("Empty tag in excluded tags")
+			}
+			tags = append(tags, "["+tag+"]")
+		}
+		skip := tagsRegexp(tags)
+		if len(ginkgoconfig.GinkgoConfig.SkipString) != 0 {
+			skip = ginkgoconfig.GinkgoConfig.SkipString + "|" + skip
+		}
+		ginkgoconfig.GinkgoConfig.SkipString = skip
+	}
+
+	return nil
+}
+
+// specCapability returns the capability needed by a spec, using the
+// innermost service tag found in its texts
+func specCapability(texts []string) api.SdkServiceCapability_OpenStorageService_Type {
+	for i := len(texts) - 1; i >= 0; i-- {
+		for _, tag := range tagRegexp.FindAllString(texts[i], -1) {
+			for _, s := range sdkServices {
+				if s.tag == tag {
+					return s.capability
+				}
+			}
+		}
+	}
+	return api.SdkServiceCapability_OpenStorageService_UNKNOWN
+}
+
+// listReporter prints the tree of specs selected to run, together with their
+// tags and the capability they need. It is used with a Ginkgo dry run so
+// that no connection to the server is needed.
+type listReporter struct {
+	w       io.Writer
+	current []string
+	// undeclared are the specs of APIs added after the first SDK release
+	// which do not declare the SDK version of the API
+	undeclared []string
+}
+
+func newListReporter(w io.Writer) *listReporter {
+	return &listReporter{w: w}
+}
+
+func (r *listReporter) SpecSuiteWillBegin(c ginkgoconfig.GinkgoConfigType, summary *types.SuiteSummary) {
+	fmt.Fprintf(r.w, "%s: %d of %d specs selected\n",
+		summary.SuiteDescription,
+		summary.NumberOfSpecsThatWillBeRun,
+		summary.NumberOfTotalSpecs)
+}
+
+func (r *listReporter) BeforeSuiteDidRun(summary *types.SetupSummary) {}
+
+func (r *listReporter) SpecWillRun(summary *types.SpecSummary) {}
+
+func (r *listReporter) SpecDidComplete(summary *types.SpecSummary) {
+	if summary.Skipped() || summary.Pending() {
+		return
+	}
+
+	// The first component text is always the top level container
+	texts := summary.ComponentTexts[1:]
+	containers := texts[:len(texts)-1]
+
+	// Print only the containers which differ from the previous spec
+	same := 0
+	for same < len(containers) && same < len(r.current) && containers[same] == r.current[same] {
+		same++
+	}
+	for i := same; i < len(containers); i++ {
+		fmt.Fprintf(r.w, "%s%s\n", strings.Repeat("  ", i), containers[i])
+	}
+	r.current = containers
+
+	capability := "none"
+	if c := specCapability(texts); c != api.SdkServiceCapability_OpenStorageService_UNKNOWN {
+		capability = c.String()
+	}
+	tags := []string{}
+	for _, text := range texts {
+		tags = append(tags, tagRegexp.FindAllString(text, -1)...)
+	}
+	fmt.Fprintf(r.w, "%s- %s %s (capability: %s)\n",
+		strings.Repeat("  ", len(containers)),
+		texts[len(texts)-1],
+		strings.Join(tags, " "),
+		capability)
+
+	text := strings.Join(texts, " ")
+	if names := undeclaredSdkApis(text); len(names) != 0 {
+		r.undeclared = append(r.undeclared,
+			fmt.Sprintf("%s (%s)", text, strings.Join(names, ", ")))
+	}
+}
+
+func (r *listReporter) AfterSuiteDidRun(summary *types.SetupSummary) {}
+
+func (r *listReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	if len(r.undeclared) == 0 {
+		return
+	}
+	fmt.Fprintf(r.w, "\n%d specs test APIs added after the first SDK release without declaring their SDK version with sdkVersionTag:\n",
+		len(r.undeclared))
+	for _, spec := range r.undeclared {
+		fmt.Fprintf(r.w, "  %s\n", spec)
+	}
+}
@@ -20,21 +20,29 @@ import (
 	"context"
 	"fmt"
 	"strconv"
-	"time"
+	"strings"
 
 	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
-func genSnapName(volid string) string {
-	return fmt.Sprintf("%s-snap-%v",
-		volid,
-		time.Now().Unix())
+// snapshotSchedulePolicies returns the names of the schedule policies in the
+// SnapshotSchedule of a volume spec, formatted as policy=<name>,policy=<name>
+func snapshotSchedulePolicies(schedule string) []string {
+	names := []string{}
+	for _, item := range strings.Split(schedule, ",") {
+		if strings.HasPrefix(item, "policy=") {
+			names = append(names, strings.TrimPrefix(item, "policy="))
+		}
+	}
+	return names
 }
 
-var _ = Describe("Volume [OpenStorageVolume]", func() {
+var _ = Describe("Volume Snapshot [OpenStorageVolume] [Snapshot]", func() {
 	var (
 		c  api.OpenStorageVolumeClient
 		ic api.OpenStorageIdentityClient
@@ -42,9 +50,9 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 	)
 
 	BeforeEach(func() {
-		c = api.NewOpenStorageVolumeClient(conn)
-		ic = api.NewOpenStorageIdentityClient(conn)
-		sc = api.NewOpenStorageSchedulePolicyClient(conn)
+		c = api.NewOpenStorageVolumeClient(run.conn)
+		ic = api.NewOpenStorageIdentityClient(run.conn)
+		sc = api.NewOpenStorageSchedulePolicyClient(run.conn)
 
 		isSupported := isCapabilitySupported(
 			ic,
@@ -69,15 +77,20 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 		BeforeEach(func() {
 			volID = ""
+			snapID = ""
 		})
 
 		AfterEach(func() {
-			if len(volID) != 0 {
-				err := deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
-					c,
-					volID)
-				Expect(err).NotTo(HaveOccurred())
+			// Snapshots first, a volume which has snapshots may not be
+			// deleted
+			for _, id := range []string{snapID, volID} {
+				if len(id) != 0 {
+					err := deleteVol(
+						setContextWithToken(context.Background(), run.users["admin"]),
+						c,
+						id)
+					Expect(err).NotTo(HaveOccurred())
+				}
 			}
 		})
 
@@ -87,7 +100,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			var err error
 			req := &api.SdkVolumeCreateRequest{
-				Name: "sdk-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					Shared:    false,
@@ -98,7 +111,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 				},
 			}
 
-			vResp, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			vResp, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 
 			By("Checking if volume created successfully with the provided params")
@@ -107,7 +120,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			inspectReq := &api.SdkVolumeInspectRequest{
 				VolumeId: vResp.VolumeId,
 			}
-			inspectResponse, err := c.Inspect(setContextWithToken(context.Background(), users["admin"]), inspectReq)
+			inspectResponse, err := c.Inspect(setContextWithToken(context.Background(), run.users["admin"]), inspectReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(inspectResponse).NotTo(BeNil())
 
@@ -119,19 +132,19 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			vreq := &api.SdkVolumeSnapshotCreateRequest{
 				VolumeId: vResp.VolumeId,
-				Name:     genSnapName(vResp.VolumeId),
+				Name:     genName("snap"),
 				Labels: map[string]string{
 					"Name": "snapshot-of" + volID,
 				},
 			}
-			resp, err := c.SnapshotCreate(setContextWithToken(context.Background(), users["admin"]), vreq)
+			resp, err := c.SnapshotCreate(setContextWithToken(context.Background(), run.users["admin"]), vreq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(resp.GetSnapshotId()).To(Not(BeNil()))
 			snapID = resp.GetSnapshotId()
 
 			By("Checking the Parent field of the created snapshot")
 
-			volumes, err := c.Inspect(setContextWithToken(context.Background(), users["admin"]), &api.SdkVolumeInspectRequest{
+			volumes, err := c.Inspect(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkVolumeInspectRequest{
 				VolumeId: snapID,
 			})
 			Expect(err).NotTo(HaveOccurred())
@@ -151,25 +164,26 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 		BeforeEach(func() {
 			volID = ""
+			snapIDs = nil
 		})
 
 		AfterEach(func() {
 			var err error
 
-			if len(volID) != 0 {
-				err = deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
-					c,
-					volID)
-				Expect(err).NotTo(HaveOccurred())
-			}
 			for _, snapID := range snapIDs {
 				err = deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					c,
 					snapID)
 				Expect(err).ToNot(HaveOccurred())
 			}
+			if len(volID) != 0 {
+				err = deleteVol(
+					setContextWithToken(context.Background(), run.users["admin"]),
+					c,
+					volID)
+				Expect(err).NotTo(HaveOccurred())
+			}
 
 		})
 
@@ -179,7 +193,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			var err error
 			req := &api.SdkVolumeCreateRequest{
-				Name: "sdk-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					Shared:    false,
@@ -190,13 +204,13 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 				},
 			}
 
-			vResp, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			vResp, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 
 			inspectReq := &api.SdkVolumeInspectRequest{
 				VolumeId: vResp.VolumeId,
 			}
-			inspectResponse, err := c.Inspect(setContextWithToken(context.Background(), users["admin"]), inspectReq)
+			inspectResponse, err := c.Inspect(setContextWithToken(context.Background(), run.users["admin"]), inspectReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(inspectResponse).NotTo(BeNil())
 
@@ -210,20 +224,20 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 				snapReq := &api.SdkVolumeSnapshotCreateRequest{
 					VolumeId: vResp.VolumeId,
-					Name:     genSnapName(vResp.VolumeId),
+					Name:     genName("snap"),
 					Labels: map[string]string{
 						"Name": "snapshot-" + strconv.Itoa(i) + "-of" + volID,
 					},
 				}
 
-				snapResp, err := c.SnapshotCreate(setContextWithToken(context.Background(), users["admin"]), snapReq)
+				snapResp, err := c.SnapshotCreate(setContextWithToken(context.Background(), run.users["admin"]), snapReq)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(snapResp.GetSnapshotId()).To(Not(BeNil()))
 				snapIDs = append(snapIDs, snapResp.GetSnapshotId())
 
 				By("Checking the Parent field of the created snapshot")
 
-				volResp, err := c.Inspect(setContextWithToken(context.Background(), users["admin"]), &api.SdkVolumeInspectRequest{
+				volResp, err := c.Inspect(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkVolumeInspectRequest{
 					VolumeId: snapResp.GetSnapshotId(),
 				})
 				Expect(err).NotTo(HaveOccurred())
@@ -232,7 +246,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			By("Enumerating the snapshots with the volumeID")
 
-			snapEnumResp, err := c.SnapshotEnumerate(setContextWithToken(context.Background(), users["admin"]), &api.SdkVolumeSnapshotEnumerateRequest{
+			snapEnumResp, err := c.SnapshotEnumerate(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkVolumeSnapshotEnumerateRequest{
 				VolumeId: volID,
 			})
 			Expect(err).NotTo(HaveOccurred())
@@ -249,15 +263,18 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 		BeforeEach(func() {
 			volID = ""
+			snapID = ""
 		})
 
 		AfterEach(func() {
-			if len(volID) != 0 {
-				err := deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
-					c,
-					volID)
-				Expect(err).NotTo(HaveOccurred())
+			for _, id := range []string{snapID, volID} {
+				if len(id) != 0 {
+					err := deleteVol(
+						setContextWithToken(context.Background(), run.users["admin"]),
+						c,
+						id)
+					Expect(err).NotTo(HaveOccurred())
+				}
 			}
 
 		})
@@ -268,7 +285,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			var err error
 			req := &api.SdkVolumeCreateRequest{
-				Name: "sdk-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					Shared:    false,
@@ -279,14 +296,14 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 				},
 			}
 
-			vResp, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			vResp, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			volID = vResp.GetVolumeId()
 
 			inspectReq := &api.SdkVolumeInspectRequest{
 				VolumeId: vResp.VolumeId,
 			}
-			inspectResponse, err := c.Inspect(setContextWithToken(context.Background(), users["admin"]), inspectReq)
+			inspectResponse, err := c.Inspect(setContextWithToken(context.Background(), run.users["admin"]), inspectReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(inspectResponse).NotTo(BeNil())
 
@@ -297,20 +314,20 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			snapReq := &api.SdkVolumeSnapshotCreateRequest{
 				VolumeId: volID,
-				Name:     genSnapName(volID),
+				Name:     genName("snap"),
 				Labels: map[string]string{
 					"Name": "snapshot-of" + volID,
 				},
 			}
 
-			snapResp, err := c.SnapshotCreate(setContextWithToken(context.Background(), users["admin"]), snapReq)
+			snapResp, err := c.SnapshotCreate(setContextWithToken(context.Background(), run.users["admin"]), snapReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(snapResp.GetSnapshotId()).To(Not(BeNil()))
 			snapID = snapResp.GetSnapshotId()
 
 			By("Checking the Parent field of the created snapshot")
 
-			volResp, err := c.Inspect(setContextWithToken(context.Background(), users["admin"]), &api.SdkVolumeInspectRequest{
+			volResp, err := c.Inspect(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkVolumeInspectRequest{
 				VolumeId: snapResp.GetSnapshotId(),
 			})
 			Expect(err).NotTo(HaveOccurred())
@@ -318,7 +335,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			By("Restoring the volume from snapshot")
 
-			_, err = c.SnapshotRestore(setContextWithToken(context.Background(), users["admin"]), &api.SdkVolumeSnapshotRestoreRequest{
+			_, err = c.SnapshotRestore(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkVolumeSnapshotRestoreRequest{
 				VolumeId:   volID,
 				SnapshotId: snapID,
 			})
@@ -326,6 +343,181 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		})
 	})
 
+	Describe("Volume Snapshot Chains", func() {
+
+		var (
+			ctx context.Context
+			ids []string
+		)
+
+		// snapshot, clone and parentOf track every created id so the
+		// AfterEach can delete the children before their parents
+		snapshot := func(parent string) string {
+			resp, err := c.SnapshotCreate(ctx, &api.SdkVolumeSnapshotCreateRequest{
+				VolumeId: parent,
+				Name:     genName("snap"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetSnapshotId()).NotTo(BeEmpty())
+			ids = append(ids, resp.GetSnapshotId())
+			return resp.GetSnapshotId()
+		}
+
+		clone := func(parent string) string {
+			resp, err := c.Clone(ctx, &api.SdkVolumeCloneRequest{
+				Name:     genName("clone"),
+				ParentId: parent,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetVolumeId()).NotTo(BeEmpty())
+			ids = append(ids, resp.GetVolumeId())
+			return resp.GetVolumeId()
+		}
+
+		parentOf := func(id string) string {
+			resp, err := c.Inspect(ctx, &api.SdkVolumeInspectRequest{
+				VolumeId: id,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			return resp.GetVolume().GetSource().GetParent()
+		}
+
+		BeforeEach(func() {
+			ctx = setContextWithToken(context.Background(), run.users["admin"])
+			ids = nil
+		})
+
+		AfterEach(func() {
+			for i := len(ids) - 1; i >= 0; i-- {
+				err := deleteVol(ctx, c, ids[i])
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("Should record the lineage of snapshots of clones and clones of snapshots", func() {
+			By("Creating the volume")
+			volID := newTestVolume(c)
+			ids = append(ids, volID)
+
+			By("Creating a chain of snapshots and clones")
+			snap1 := snapshot(volID)
+			clone1 := clone(snap1)
+			snap2 := snapshot(clone1)
+			clone2 := clone(snap2)
+
+			By("Checking the Parent field of each volume in the chain")
+			Expect(parentOf(volID)).To(BeEmpty())
+			Expect(parentOf(snap1)).To(Equal(volID))
+			Expect(parentOf(clone1)).To(Equal(snap1))
+			Expect(parentOf(snap2)).To(Equal(clone1))
+			Expect(parentOf(clone2)).To(Equal(snap2))
+		})
+
+		It("Should fail to delete a volume which still has snapshots", func() {
+			By("Creating a volume with a snapshot")
+			volID := newTestVolume(c)
+			ids = append(ids, volID)
+			snapID := snapshot(volID)
+
+			By("Deleting the volume")
+			_, err := c.Delete(ctx, &api.SdkVolumeDeleteRequest{
+				VolumeId: volID,
+			})
+			Expect(err).To(HaveOccurred())
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(BeEquivalentTo(codes.FailedPrecondition))
+
+			By("Checking the volume and its snapshot still exist")
+			Expect(parentOf(volID)).To(BeEmpty())
+			Expect(parentOf(snapID)).To(Equal(volID))
+		})
+
+		It("Should fail to delete a snapshot which still has clones", func() {
+			By("Creating a clone of a snapshot")
+			volID := newTestVolume(c)
+			ids = append(ids, volID)
+			snapID := snapshot(volID)
+			cloneID := clone(snapID)
+
+			By("Deleting the snapshot")
+			_, err := c.Delete(ctx, &api.SdkVolumeDeleteRequest{
+				VolumeId: snapID,
+			})
+			Expect(err).To(HaveOccurred())
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(BeEquivalentTo(codes.FailedPrecondition))
+
+			By("Checking the snapshot and its clone still exist")
+			Expect(parentOf(snapID)).To(Equal(volID))
+			Expect(parentOf(cloneID)).To(Equal(snapID))
+		})
+
+		It("Should restore an older snapshot after newer ones were taken", func() {
+			By("Creating a volume with three snapshots")
+			volID := newTestVolume(c)
+			ids = append(ids, volID)
+			snapIDs := []string{snapshot(volID), snapshot(volID), snapshot(volID)}
+
+			By("Restoring the oldest snapshot")
+			_, err := c.SnapshotRestore(ctx, &api.SdkVolumeSnapshotRestoreRequest{
+				VolumeId:   volID,
+				SnapshotId: snapIDs[0],
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the newer snapshots survived the restore")
+			resp, err := c.SnapshotEnumerateWithFilters(ctx, &api.SdkVolumeSnapshotEnumerateWithFiltersRequest{
+				VolumeId: volID,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			expectExactSet("snapshots of "+volID, resp.GetVolumeSnapshotIds(), snapIDs)
+			for _, snapID := range snapIDs {
+				Expect(parentOf(snapID)).To(Equal(volID))
+			}
+
+			By("Restoring the newest snapshot")
+			_, err = c.SnapshotRestore(ctx, &api.SdkVolumeSnapshotRestoreRequest{
+				VolumeId:   volID,
+				SnapshotId: snapIDs[2],
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should enumerate only the snapshots of the given parent", func() {
+			By("Creating two volumes, a clone and their snapshots")
+			vol1 := newTestVolume(c)
+			ids = append(ids, vol1)
+			vol2 := newTestVolume(c)
+			ids = append(ids, vol2)
+			vol1Snaps := []string{snapshot(vol1), snapshot(vol1)}
+			vol2Snaps := []string{snapshot(vol2)}
+			cloneID := clone(vol1Snaps[0])
+			cloneSnaps := []string{snapshot(cloneID)}
+
+			By("Enumerating the snapshots of each parent")
+			for parent, expected := range map[string][]string{
+				vol1:    vol1Snaps,
+				vol2:    vol2Snaps,
+				cloneID: cloneSnaps,
+			} {
+				resp, err := c.SnapshotEnumerateWithFilters(ctx, &api.SdkVolumeSnapshotEnumerateWithFiltersRequest{
+					VolumeId: parent,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				expectExactSet("snapshots of "+parent, resp.GetVolumeSnapshotIds(), expected)
+			}
+
+			By("Enumerating the snapshots of a snapshot")
+			resp, err := c.SnapshotEnumerateWithFilters(ctx, &api.SdkVolumeSnapshotEnumerateWithFiltersRequest{
+				VolumeId: vol1Snaps[1],
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetVolumeSnapshotIds()).To(BeEmpty())
+		})
+	})
+
 	Describe("VolumeSnapshotScheduleUpdate", func() {
 
 		var (
@@ -342,12 +534,12 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			var err error
 
 			if len(volID) != 0 {
-				_, err = c.Delete(setContextWithToken(context.Background(), users["admin"]),
+				_, err = c.Delete(setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeDeleteRequest{VolumeId: volID},
 				)
 			}
 			if len(policyName) != 0 {
-				_, err = sc.Delete(setContextWithToken(context.Background(), users["admin"]),
+				_, err = sc.Delete(setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkSchedulePolicyDeleteRequest{Name: policyName})
 			}
 			Expect(err).ToNot(HaveOccurred())
@@ -358,19 +550,19 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			By("creating the volume")
 			var err error
 			req := &api.SdkVolumeCreateRequest{
-				Name: fmt.Sprintf("sdk-vol-%d", time.Now().Unix()),
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:    uint64(5 * GIGABYTE),
 					HaLevel: 3,
 					Format:  api.FSType_FS_TYPE_EXT4,
 				},
 			}
-			vResp, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			vResp, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			volID = vResp.GetVolumeId()
 
 			By("creating a schedule policy")
-			policyName = fmt.Sprintf("mypolicy-%d", time.Now().Unix())
+			policyName = genName("policy")
 			policyReq := &api.SdkSchedulePolicyCreateRequest{
 				SchedulePolicy: &api.SdkSchedulePolicy{
 					Name: policyName,
@@ -388,18 +580,18 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 					},
 				},
 			}
-			_, err = sc.Create(setContextWithToken(context.Background(), users["admin"]), policyReq)
+			_, err = sc.Create(setContextWithToken(context.Background(), run.users["admin"]), policyReq)
 			Expect(err).NotTo(HaveOccurred())
 
 			By("applying schedule to the volume")
-			_, err = c.SnapshotScheduleUpdate(setContextWithToken(context.Background(), users["admin"]), &api.SdkVolumeSnapshotScheduleUpdateRequest{
+			_, err = c.SnapshotScheduleUpdate(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkVolumeSnapshotScheduleUpdateRequest{
 				VolumeId:              volID,
 				SnapshotScheduleNames: []string{policyName},
 			})
 			Expect(err).NotTo(HaveOccurred())
 
 			By("confirming the schedule name is in the volume spec")
-			inspectResponse, err := c.Inspect(setContextWithToken(context.Background(), users["admin"]), &api.SdkVolumeInspectRequest{
+			inspectResponse, err := c.Inspect(setContextWithToken(context.Background(), run.users["admin"]), &api.SdkVolumeInspectRequest{
 				VolumeId: vResp.VolumeId,
 			})
 			Expect(err).NotTo(HaveOccurred())
@@ -408,4 +600,179 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		})
 	})
 
+	Describe("Volume Snapshot Schedules [OpenStorageSchedulePolicy]", func() {
+
+		var (
+			ctx      context.Context
+			volID    string
+			policies []string
+		)
+
+		createPolicy := func(retain int64, day api.SdkTimeWeekday) string {
+			name := genName("policy")
+			_, err := sc.Create(ctx, &api.SdkSchedulePolicyCreateRequest{
+				SchedulePolicy: &api.SdkSchedulePolicy{
+					Name: name,
+					Schedules: []*api.SdkSchedulePolicyInterval{
+						&api.SdkSchedulePolicyInterval{
+							Retain: retain,
+							PeriodType: &api.SdkSchedulePolicyInterval_Weekly{
+								Weekly: &api.SdkSchedulePolicyIntervalWeekly{
+									Day:    day,
+									Hour:   1,
+									Minute: 15,
+								},
+							},
+						},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			policies = append(policies, name)
+			return name
+		}
+
+		scheduleUpdate := func(names ...string) error {
+			_, err := c.SnapshotScheduleUpdate(ctx, &api.SdkVolumeSnapshotScheduleUpdateRequest{
+				VolumeId:              volID,
+				SnapshotScheduleNames: names,
+			})
+			return err
+		}
+
+		scheduleOf := func() string {
+			resp, err := c.Inspect(ctx, &api.SdkVolumeInspectRequest{
+				VolumeId: volID,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			return resp.GetVolume().GetSpec().GetSnapshotSchedule()
+		}
+
+		BeforeEach(func() {
+			skipUnsupportedService("OpenStorageSchedulePolicy")
+			ctx = setContextWithToken(context.Background(), run.users["admin"])
+			volID = ""
+			policies = nil
+		})
+
+		AfterEach(func() {
+			if len(volID) != 0 {
+				err := deleteVol(ctx, c, volID)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			// Policies deleted by the test itself are already gone
+			for _, name := range policies {
+				_, err := sc.Delete(ctx, &api.SdkSchedulePolicyDeleteRequest{Name: name})
+				if err != nil {
+					Expect(status.Code(err)).To(BeEquivalentTo(codes.NotFound))
+				}
+			}
+		})
+
+		It("Should attach, replace and clear several schedule policies", func() {
+			volID = newTestVolume(c)
+			first := []string{
+				createPolicy(2, api.SdkTimeWeekday_SdkTimeWeekdayMonday),
+				createPolicy(3, api.SdkTimeWeekday_SdkTimeWeekdayTuesday),
+			}
+			second := []string{
+				createPolicy(4, api.SdkTimeWeekday_SdkTimeWeekdayWednesday),
+				createPolicy(5, api.SdkTimeWeekday_SdkTimeWeekdayThursday),
+				createPolicy(6, api.SdkTimeWeekday_SdkTimeWeekdayFriday),
+			}
+
+			By("Attaching two policies")
+			Expect(scheduleUpdate(first...)).To(Succeed())
+			expectExactSet("snapshot schedule policies", snapshotSchedulePolicies(scheduleOf()), first)
+
+			By("Replacing them with three other policies")
+			Expect(scheduleUpdate(second...)).To(Succeed())
+			expectExactSet("snapshot schedule policies", snapshotSchedulePolicies(scheduleOf()), second)
+
+			By("Clearing the policies")
+			Expect(scheduleUpdate()).To(Succeed())
+			Expect(scheduleOf()).To(BeEmpty())
+		})
+
+		It("Should fail to attach a schedule policy which does not exist", func() {
+			volID = newTestVolume(c)
+			name := createPolicy(2, api.SdkTimeWeekday_SdkTimeWeekdaySunday)
+			Expect(scheduleUpdate(name)).To(Succeed())
+
+			By("Attaching an existing and a missing policy")
+			err := scheduleUpdate(name, genName("missing-policy"))
+			Expect(err).To(HaveOccurred())
+			Expect(status.Code(err)).To(beOneOfCodes(codes.InvalidArgument, codes.NotFound))
+
+			By("Checking the schedule of the volume did not change")
+			Expect(scheduleOf()).To(Equal(fmt.Sprintf("policy=%s", name)))
+		})
+
+		It("Should keep the volume consistent when deleting a referenced schedule policy", func() {
+			volID = newTestVolume(c)
+			name := createPolicy(2, api.SdkTimeWeekday_SdkTimeWeekdaySunday)
+			Expect(scheduleUpdate(name)).To(Succeed())
+
+			By("Deleting the policy while the volume references it")
+			_, err := sc.Delete(ctx, &api.SdkSchedulePolicyDeleteRequest{Name: name})
+			if err != nil {
+				// A driver protecting the reference must keep the policy
+				Expect(status.Code(err)).To(BeEquivalentTo(codes.FailedPrecondition))
+				_, err = sc.Inspect(ctx, &api.SdkSchedulePolicyInspectRequest{Name: name})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(scheduleOf()).To(Equal(fmt.Sprintf("policy=%s", name)))
+				return
+			}
+
+			By("Checking the deleted policy is gone and the volume can be rescheduled")
+			_, err = sc.Inspect(ctx, &api.SdkSchedulePolicyInspectRequest{Name: name})
+			Expect(err).To(HaveOccurred())
+			Expect(status.Code(err)).To(BeEquivalentTo(codes.NotFound))
+			// The driver may keep the stale reference or clear it
+			schedule := scheduleOf()
+			Expect(schedule).To(Or(Equal(fmt.Sprintf("policy=%s", name)), BeEmpty()))
+			Expect(scheduleUpdate(name)).NotTo(Succeed())
+			Expect(scheduleOf()).To(Equal(schedule))
+
+			replacement := createPolicy(3, api.SdkTimeWeekday_SdkTimeWeekdayMonday)
+			Expect(scheduleUpdate(replacement)).To(Succeed())
+			Expect(scheduleOf()).To(Equal(fmt.Sprintf("policy=%s", replacement)))
+		})
+
+		It("Should show schedule policy updates through the volume spec", func() {
+			volID = newTestVolume(c)
+			name := createPolicy(2, api.SdkTimeWeekday_SdkTimeWeekdaySunday)
+			Expect(scheduleUpdate(name)).To(Succeed())
+
+			By("Updating the referenced policy")
+			update := &api.SdkSchedulePolicy{
+				Name: name,
+				Schedules: []*api.SdkSchedulePolicyInterval{
+					&api.SdkSchedulePolicyInterval{
+						Retain: 7,
+						PeriodType: &api.SdkSchedulePolicyInterval_Weekly{
+							Weekly: &api.SdkSchedulePolicyIntervalWeekly{
+								Day:    api.SdkTimeWeekday_SdkTimeWeekdaySaturday,
+								Hour:   1,
+								Minute: 15,
+							},
+						},
+					},
+				},
+			}
+			_, err := sc.Update(ctx, &api.SdkSchedulePolicyUpdateRequest{SchedulePolicy: update})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Following the volume spec to the updated policy")
+			names := snapshotSchedulePolicies(scheduleOf())
+			Expect(names).To(Equal([]string{name}))
+			resp, err := sc.Inspect(ctx, &api.SdkSchedulePolicyInspectRequest{Name: names[0]})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetPolicy().GetSchedules()).To(HaveLen(1))
+			Expect(resp.GetPolicy().GetSchedules()[0].GetRetain()).To(BeEquivalentTo(7))
+			Expect(resp.GetPolicy().GetSchedules()[0].GetWeekly().GetDay()).
+				To(BeEquivalentTo(api.SdkTimeWeekday_SdkTimeWeekdaySaturday))
+		})
+	})
+
 })
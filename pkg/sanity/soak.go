@@ -0,0 +1,576 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"github.com/libopenstorage/sdk-test/pkg/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	soakTag = "[Soak]"
+
+	defaultSoakDuration          = 2 * time.Hour
+	defaultSoakWindow            = 10 * time.Minute
+	defaultSoakErrorRateIncrease = 5.0
+	defaultTokenLifetime         = time.Hour
+
+	// soakCallTimeout is the deadline of each call of the soak, so that a
+	// server which stops answering fails the iteration instead of the run
+	soakCallTimeout = time.Minute
+	// soakTaskTimeout is the time given to a cloud backup or restore
+	soakTaskTimeout = 30 * time.Minute
+)
+
+// tokenCredentials adds an admin token to the calls which do not have one,
+// and creates a new token before the current one expires. Tokens created
+// once, like the ones of createUsersTokens, expire during long runs.
+type tokenCredentials struct {
+	lock       sync.Mutex
+	lifetime   time.Duration
+	token      string
+	expiration time.Time
+	// refreshes is the number of tokens created
+	refreshes int
+}
+
+func newTokenCredentials(lifetime time.Duration) *tokenCredentials {
+	if lifetime <= 0 {
+		lifetime = defaultTokenLifetime
+	}
+	return &tokenCredentials{lifetime: lifetime}
+}
+
+// GetRequestMetadata returns the token, creating a new one when less than a
+// quarter of its lifetime is left
+func (t *tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get("authorization")) != 0 {
+		return nil, nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if time.Until(t.expiration) < t.lifetime/4 {
+		expiration := time.Now().Add(t.lifetime)
+		claims := newAdminClaims()
+		claims.Issuer = run.config.Issuer
+		signature, err := auth.NewSignatureSharedSecret(run.config.SharedSecret)
+		if err != nil {
+			return nil, err
+		}
+		token, err := auth.Token(claims, signature, &auth.Options{
+			Expiration: expiration.Unix(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to refresh the token: %v", err)
+		}
+		t.token, t.expiration = token, expiration
+		t.refreshes++
+	}
+	return map[string]string{"authorization": "bearer " + t.token}, nil
+}
+
+// RequireTransportSecurity returns false, the suite connects without TLS
+func (t *tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+func (t *tokenCredentials) getRefreshes() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.refreshes
+}
+
+// SoakWindow is the outcome of the iterations of a window of the soak mode.
+// ErrorRate is the percentage of calls which failed, and Leaked is the
+// number of resources created by the soak and not deleted at the end of the
+// window.
+type SoakWindow struct {
+	Start          time.Time `json:"start"`
+	Seconds        float64   `json:"seconds"`
+	Iterations     int       `json:"iterations"`
+	Failures       int       `json:"failed_iterations"`
+	Calls          int       `json:"calls"`
+	Errors         int       `json:"errors"`
+	ErrorRate      float64   `json:"error_rate"`
+	Leaked         int       `json:"leaked"`
+	TokenRefreshes int       `json:"token_refreshes"`
+	FirstError     string    `json:"first_error,omitempty"`
+}
+
+// soakRecorder counts the iterations and calls of each window of the soak
+type soakRecorder struct {
+	lock   sync.Mutex
+	active bool
+	window SoakWindow
+	// refreshes is the number of tokens created before the window started
+	refreshes int
+	windows   []SoakWindow
+}
+
+func newSoakRecorder() *soakRecorder {
+	return &soakRecorder{}
+}
+
+// startWindow starts counting the calls of a new window
+func (s *soakRecorder) startWindow(refreshes int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.active = true
+	s.window = SoakWindow{Start: time.Now()}
+	s.refreshes = refreshes
+}
+
+// iteration counts an iteration of the lifecycle, failed if err is set
+func (s *soakRecorder) iteration(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.window.Iterations++
+	if err != nil {
+		s.window.Failures++
+		if len(s.window.FirstError) == 0 {
+			s.window.FirstError = err.Error()
+		}
+	}
+}
+
+// stopWindow stops counting and saves the window, unless it had no
+// iterations
+func (s *soakRecorder) stopWindow(leaked, refreshes int) SoakWindow {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.active = false
+	w := s.window
+	w.Seconds = time.Since(w.Start).Seconds()
+	w.Leaked = leaked
+	w.TokenRefreshes = refreshes - s.refreshes
+	if w.Calls != 0 {
+		w.ErrorRate = 100 * float64(w.Errors) / float64(w.Calls)
+	}
+	if w.Iterations != 0 {
+		s.windows = append(s.windows, w)
+	}
+	return w
+}
+
+func (s *soakRecorder) getWindows() []SoakWindow {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]SoakWindow{}, s.windows...)
+}
+
+func (s *soakRecorder) unaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.active {
+		s.window.Calls++
+		if err != nil {
+			s.window.Errors++
+		}
+	}
+	return err
+}
+
+// report prints the windows of the soak, and saves them as JSON in the file
+// if it is set
+func (s *soakRecorder) report(w io.Writer, filename string) error {
+	windows := s.getWindows()
+	if len(windows) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\nSoak windows:\n")
+	fmt.Fprintf(w, "  %-8s %9s %10s %8s %8s %7s %8s %7s %9s\n",
+		"start", "seconds", "iterations", "failed", "calls", "errors", "error %", "leaked", "refreshes")
+	for _, window := range windows {
+		fmt.Fprintf(w, "  %-8s %9.0f %10d %8d %8d %7d %8.2f %7d %9d\n",
+			window.Start.Format("15:04:05"), window.Seconds, window.Iterations, window.Failures,
+			window.Calls, window.Errors, window.ErrorRate, window.Leaked, window.TokenRefreshes)
+	}
+
+	if len(filename) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(windows, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(reportFilename(filename), data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to write soak report %s: %v", filename, err)
+	}
+	return nil
+}
+
+// leakGrowth returns the growth of the leaked resources from the first window
+// to the last one, from the least squares line through the leaked resources
+// of every window, so that a single window does not decide the trend
+func leakGrowth(windows []SoakWindow) float64 {
+	n := float64(len(windows))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, w := range windows {
+		x, y := float64(i), float64(w.Leaked)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	return slope * (n - 1)
+}
+
+// checkSoakTrend returns an error when the leaked resources grow by at least
+// one over the windows, or when the error rate of the second half of the windows is
+// higher than the one of the first half by more than increase percentage
+// points
+func checkSoakTrend(windows []SoakWindow, increase float64) error {
+	iterations := 0
+	for _, w := range windows {
+		iterations += w.Iterations - w.Failures
+	}
+	if iterations == 0 {
+		return fmt.Errorf("No iteration of the soak succeeded")
+	}
+	if len(windows) < 2 {
+		return nil
+	}
+
+	if growth := leakGrowth(windows); growth >= 1 {
+		return fmt.Errorf("Leaked resources grew by %.1f over the %d windows, from %d after the first window to %d after the last one",
+			growth, len(windows), windows[0].Leaked, windows[len(windows)-1].Leaked)
+	}
+
+	errorRate := func(windows []SoakWindow) float64 {
+		calls, errors := 0, 0
+		for _, w := range windows {
+			calls += w.Calls
+			errors += w.Errors
+		}
+		if calls == 0 {
+			return 0
+		}
+		return 100 * float64(errors) / float64(calls)
+	}
+	half := len(windows) / 2
+	before, after := errorRate(windows[:half]), errorRate(windows[len(windows)-half:])
+	if after-before > increase {
+		return fmt.Errorf("Error rate grew from %.2f%% in the first half of the soak to %.2f%% in the second half",
+			before, after)
+	}
+	return nil
+}
+
+// soakSettings returns the duration of the soak and of its windows
+func soakSettings(c *SanityConfiguration) (time.Duration, time.Duration) {
+	duration, window := c.SoakDuration, c.SoakWindow
+	if duration <= 0 {
+		duration = defaultSoakDuration
+	}
+	if window <= 0 {
+		window = defaultSoakWindow
+	}
+	return duration, window
+}
+
+// soakContext returns a context with the deadline of the calls of the soak.
+// The token is added by the credentials of the connection.
+func soakContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), soakCallTimeout)
+}
+
+// soakCall calls f with a soak context
+func soakCall(f func(ctx context.Context) error) error {
+	ctx, cancel := soakContext()
+	defer cancel()
+	return f(ctx)
+}
+
+// soakLifecycle creates, attaches, mounts, snapshots, backs up and restores
+// a volume, then deletes everything
+type soakLifecycle struct {
+	vc api.OpenStorageVolumeClient
+	ma api.OpenStorageMountAttachClient
+	bc api.OpenStorageCloudBackupClient
+	// clusterID and credID are set when cloud backups are tested
+	clusterID string
+	credID    string
+}
+
+// waitForTask waits until the cloud backup or restore task is done
+func (l *soakLifecycle) waitForTask(taskID string) error {
+	return waitForCloudBackupTask(l.bc, taskID, soakTaskTimeout, soakContext, nil)
+}
+
+// backup backs up the volume and restores the backup to a new volume. The
+// cleanups delete the backups and the restored volume.
+func (l *soakLifecycle) backup(volID string, cleanup *[]func() error) error {
+	backupTask := genName("task")
+	err := soakCall(func(ctx context.Context) error {
+		_, err := l.bc.Create(ctx, &api.SdkCloudBackupCreateRequest{
+			VolumeId:     volID,
+			CredentialId: l.credID,
+			TaskId:       backupTask,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Backup failed: %v", err)
+	}
+	*cleanup = append(*cleanup, func() error {
+		return soakCall(func(ctx context.Context) error {
+			_, err := l.bc.DeleteAll(ctx, &api.SdkCloudBackupDeleteAllRequest{
+				SrcVolumeId:  volID,
+				CredentialId: l.credID,
+			})
+			return err
+		})
+	})
+	if err := l.waitForTask(backupTask); err != nil {
+		return err
+	}
+
+	var backups *api.SdkCloudBackupEnumerateWithFiltersResponse
+	err = soakCall(func(ctx context.Context) (err error) {
+		backups, err = l.bc.EnumerateWithFilters(ctx, &api.SdkCloudBackupEnumerateWithFiltersRequest{
+			ClusterId:    l.clusterID,
+			SrcVolumeId:  volID,
+			CredentialId: l.credID,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Enumerating the backups failed: %v", err)
+	}
+	if len(backups.GetBackups()) == 0 {
+		return fmt.Errorf("Backup of volume %s not found", volID)
+	}
+
+	restoreTask := genName("task")
+	var restore *api.SdkCloudBackupRestoreResponse
+	err = soakCall(func(ctx context.Context) (err error) {
+		restore, err = l.bc.Restore(ctx, &api.SdkCloudBackupRestoreRequest{
+			BackupId:          backups.GetBackups()[0].GetId(),
+			RestoreVolumeName: genName("vol"),
+			CredentialId:      l.credID,
+			TaskId:            restoreTask,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Restore failed: %v", err)
+	}
+	*cleanup = append(*cleanup, func() error {
+		return soakCall(func(ctx context.Context) error {
+			return deleteVol(ctx, l.vc, restore.GetRestoreVolumeId())
+		})
+	})
+	return l.waitForTask(restoreTask)
+}
+
+// iterate runs the lifecycle once, and returns the first error of a step or
+// of the cleanup
+func (l *soakLifecycle) iterate() (err error) {
+	var cleanup []func() error
+	defer func() {
+		for i := len(cleanup) - 1; i >= 0; i-- {
+			if cerr := cleanup[i](); cerr != nil && err == nil {
+				err = fmt.Errorf("Cleanup failed: %v", cerr)
+			}
+		}
+	}()
+
+	var volID string
+	err = soakCall(func(ctx context.Context) error {
+		resp, err := l.vc.Create(ctx, &api.SdkVolumeCreateRequest{
+			Name: genName("vol"),
+			Spec: &api.VolumeSpec{
+				Size:    uint64(GIGABYTE),
+				HaLevel: 1,
+				Format:  api.FSType_FS_TYPE_EXT4,
+			},
+		})
+		volID = resp.GetVolumeId()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Create failed: %v", err)
+	}
+	cleanup = append(cleanup, func() error {
+		return soakCall(func(ctx context.Context) error {
+			return deleteVol(ctx, l.vc, volID)
+		})
+	})
+
+	err = soakCall(func(ctx context.Context) error {
+		_, err := l.ma.Attach(ctx, &api.SdkVolumeAttachRequest{VolumeId: volID})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Attach failed: %v", err)
+	}
+	cleanup = append(cleanup, func() error {
+		return soakCall(func(ctx context.Context) error {
+			_, err := l.ma.Detach(ctx, &api.SdkVolumeDetachRequest{
+				VolumeId: volID,
+				Options: &api.SdkVolumeDetachOptions{
+					UnmountBeforeDetach: true,
+				},
+			})
+			return err
+		})
+	})
+
+	if len(run.config.MountPath) != 0 {
+		err = soakCall(func(ctx context.Context) error {
+			_, err := l.ma.Mount(ctx, &api.SdkVolumeMountRequest{
+				VolumeId:  volID,
+				MountPath: run.config.MountPath,
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Mount failed: %v", err)
+		}
+		cleanup = append(cleanup, func() error {
+			return soakCall(func(ctx context.Context) error {
+				_, err := l.ma.Unmount(ctx, &api.SdkVolumeUnmountRequest{
+					VolumeId:  volID,
+					MountPath: run.config.MountPath,
+				})
+				return err
+			})
+		})
+	}
+
+	var snapID string
+	err = soakCall(func(ctx context.Context) error {
+		resp, err := l.vc.SnapshotCreate(ctx, &api.SdkVolumeSnapshotCreateRequest{
+			VolumeId: volID,
+			Name:     genName("snap"),
+		})
+		snapID = resp.GetSnapshotId()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Snapshot failed: %v", err)
+	}
+	cleanup = append(cleanup, func() error {
+		return soakCall(func(ctx context.Context) error {
+			_, err := l.vc.Delete(ctx, &api.SdkVolumeDeleteRequest{VolumeId: snapID})
+			return err
+		})
+	})
+
+	if len(l.credID) != 0 {
+		return l.backup(volID, &cleanup)
+	}
+	return nil
+}
+
+var _ = Describe("Soak "+soakTag, func() {
+	var (
+		lifecycle *soakLifecycle
+		cc        api.OpenStorageCredentialsClient
+		credIDs   map[string]string
+	)
+
+	BeforeEach(func() {
+		skipUnsupportedService("OpenStorageVolume")
+		lifecycle = &soakLifecycle{
+			vc: api.NewOpenStorageVolumeClient(run.conn),
+			ma: api.NewOpenStorageMountAttachClient(run.conn),
+			bc: api.NewOpenStorageCloudBackupClient(run.conn),
+		}
+		cc = api.NewOpenStorageCredentialsClient(run.conn)
+		credIDs = nil
+
+		ic := api.NewOpenStorageIdentityClient(run.conn)
+		if run.config.ProviderConfig != nil &&
+			isCapabilitySupported(ic, api.SdkServiceCapability_OpenStorageService_CLOUD_BACKUP) {
+			cluster, err := api.NewOpenStorageClusterClient(run.conn).InspectCurrent(
+				setContextWithToken(context.Background(), run.users["admin"]),
+				&api.SdkClusterInspectCurrentRequest{},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			lifecycle.clusterID = cluster.GetCluster().GetId()
+			credIDs = parseAndCreateCredentials2(cc)
+			for _, credID := range credIDs {
+				lifecycle.credID = credID
+				break
+			}
+		}
+	})
+
+	AfterEach(func() {
+		// The tokens of run.users may have expired, the credentials of the
+		// connection add a fresh one
+		for _, credID := range credIDs {
+			err := soakCall(func(ctx context.Context) error {
+				_, err := cc.Delete(ctx, &api.SdkCredentialDeleteRequest{CredentialId: credID})
+				return err
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	It("should keep error rates and leaked resources flat over the lifecycle of volumes [OpenStorageVolume]", func() {
+		duration, window := soakSettings(run.config)
+		increase := defaultSoakErrorRateIncrease
+		if run.config.SoakErrorRateIncrease != nil {
+			increase = *run.config.SoakErrorRateIncrease
+		}
+
+		// Resources created before the soak, like credentials, are not leaks
+		baseline := run.ledger.count()
+		end := time.Now().Add(duration)
+		run.soak.startWindow(run.tokens.getRefreshes())
+		windowEnd := time.Now().Add(window)
+		for time.Now().Before(end) && run.ctx.Err() == nil {
+			run.soak.iteration(lifecycle.iterate())
+			if time.Now().After(windowEnd) {
+				w := run.soak.stopWindow(run.ledger.count()-baseline, run.tokens.getRefreshes())
+				fmt.Fprintf(GinkgoWriter, "Soak window: %d iterations, %d failed, %.2f%% of the calls failed, %d resources leaked\n",
+					w.Iterations, w.Failures, w.ErrorRate, w.Leaked)
+				run.soak.startWindow(run.tokens.getRefreshes())
+				windowEnd = time.Now().Add(window)
+			}
+		}
+		run.soak.stopWindow(run.ledger.count()-baseline, run.tokens.getRefreshes())
+
+		Expect(checkSoakTrend(run.soak.getWindows(), increase)).To(Succeed())
+	})
+})
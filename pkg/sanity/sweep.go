@@ -0,0 +1,391 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/gomega"
+)
+
+// sweeper deletes resources left behind by the tests. It does not use
+// Gomega, so that it can run outside of a spec.
+type sweeper struct {
+	ctx   context.Context
+	conn  *grpc.ClientConn
+	token string
+}
+
+func (s *sweeper) context() context.Context {
+	return setContextWithToken(internalContext(s.ctx), s.token)
+}
+
+func isNotFound(err error) bool {
+	return status.Code(err) == codes.NotFound
+}
+
+// exists returns true if the resource still exists
+func (s *sweeper) exists(r resource) (bool, error) {
+	var err error
+	switch r.kind {
+	case kindCloudBackup:
+		var resp *api.SdkCloudBackupEnumerateWithFiltersResponse
+		resp, err = api.NewOpenStorageCloudBackupClient(s.conn).EnumerateWithFilters(
+			s.context(),
+			&api.SdkCloudBackupEnumerateWithFiltersRequest{
+				SrcVolumeId:  r.id,
+				CredentialId: r.credentialID,
+			})
+		if err == nil {
+			return len(resp.GetBackups()) != 0, nil
+		}
+	case kindBackupSchedule:
+		var resp *api.SdkCloudBackupSchedEnumerateResponse
+		resp, err = api.NewOpenStorageCloudBackupClient(s.conn).SchedEnumerate(
+			s.context(),
+			&api.SdkCloudBackupSchedEnumerateRequest{})
+		if err == nil {
+			_, ok := resp.GetCloudSchedList()[r.id]
+			return ok, nil
+		}
+	case kindSnapshot, kindVolume:
+		_, err = api.NewOpenStorageVolumeClient(s.conn).Inspect(
+			s.context(),
+			&api.SdkVolumeInspectRequest{VolumeId: r.id})
+	case kindCredential:
+		_, err = api.NewOpenStorageCredentialsClient(s.conn).Inspect(
+			s.context(),
+			&api.SdkCredentialInspectRequest{CredentialId: r.id})
+	case kindSchedulePolicy:
+		_, err = api.NewOpenStorageSchedulePolicyClient(s.conn).Inspect(
+			s.context(),
+			&api.SdkSchedulePolicyInspectRequest{Name: r.id})
+	case kindRole:
+		_, err = api.NewOpenStorageRoleClient(s.conn).Inspect(
+			s.context(),
+			&api.SdkRoleInspectRequest{Name: r.id})
+	default:
+		return false, fmt.Errorf("Unknown resource kind %s", r.kind)
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// delete deletes the resource. It returns false if the resource did not
+// exist anymore.
+func (s *sweeper) delete(r resource) (bool, error) {
+	exists, err := s.exists(r)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	switch r.kind {
+	case kindCloudBackup:
+		_, err = api.NewOpenStorageCloudBackupClient(s.conn).DeleteAll(
+			s.context(),
+			&api.SdkCloudBackupDeleteAllRequest{
+				SrcVolumeId:  r.id,
+				CredentialId: r.credentialID,
+			})
+	case kindBackupSchedule:
+		_, err = api.NewOpenStorageCloudBackupClient(s.conn).SchedDelete(
+			s.context(),
+			&api.SdkCloudBackupSchedDeleteRequest{BackupScheduleId: r.id})
+	case kindSnapshot, kindVolume:
+		// The test may have failed while the volume was attached. Errors
+		// are ignored since most volumes are not attached.
+		api.NewOpenStorageMountAttachClient(s.conn).Detach(
+			s.context(),
+			&api.SdkVolumeDetachRequest{
+				VolumeId: r.id,
+				Options: &api.SdkVolumeDetachOptions{
+					Force:               true,
+					UnmountBeforeDetach: true,
+				},
+			})
+		_, err = api.NewOpenStorageVolumeClient(s.conn).Delete(
+			s.context(),
+			&api.SdkVolumeDeleteRequest{VolumeId: r.id})
+	case kindCredential:
+		_, err = api.NewOpenStorageCredentialsClient(s.conn).Delete(
+			s.context(),
+			&api.SdkCredentialDeleteRequest{CredentialId: r.id})
+	case kindSchedulePolicy:
+		_, err = api.NewOpenStorageSchedulePolicyClient(s.conn).Delete(
+			s.context(),
+			&api.SdkSchedulePolicyDeleteRequest{Name: r.id})
+	case kindRole:
+		_, err = api.NewOpenStorageRoleClient(s.conn).Delete(
+			s.context(),
+			&api.SdkRoleDeleteRequest{Name: r.id})
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// sweep deletes the resources in dependency order. It returns the resources
+// which still existed and were deleted. Snapshots and volumes may depend on
+// each other in chains, like a snapshot of a clone of a snapshot, so the
+// resources which failed are tried again while others are deleted.
+func (s *sweeper) sweep(resources map[resourceKind][]resource) ([]resource, error) {
+	deleted := []resource{}
+	for {
+		failed := make(map[resourceKind][]resource)
+		errs := []string{}
+		progress := false
+		for _, kind := range sweepOrder {
+			for _, r := range resources[kind] {
+				ok, err := s.delete(r)
+				if err != nil {
+					failed[kind] = append(failed[kind], r)
+					errs = append(errs, fmt.Sprintf("%v: %v", r, err))
+					continue
+				}
+				progress = true
+				if ok {
+					deleted = append(deleted, r)
+				}
+			}
+		}
+		if len(errs) == 0 {
+			return deleted, nil
+		}
+		if !progress {
+			return deleted, fmt.Errorf("Unable to delete:\n%s", strings.Join(errs, "\n"))
+		}
+		resources = failed
+	}
+}
+
+// sweepLedger deletes the resources which are still in the ledger of the run
+// and returns them. Any resource returned was leaked by a test.
+func (r *sanityRun) sweepLedger() ([]resource, error) {
+	s := &sweeper{
+		ctx:   context.Background(),
+		conn:  r.conn,
+		token: r.users["admin"],
+	}
+	resources := make(map[resourceKind][]resource)
+	for _, kind := range sweepOrder {
+		resources[kind] = r.ledger.list(kind)
+	}
+	return s.sweep(resources)
+}
+
+// leftoverNameRegexp matches the names generated by genName for the run ID
+func leftoverNameRegexp(runID string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`^%s-%s-.+-[0-9a-f]{6}$`,
+		resourcePrefix, regexp.QuoteMeta(runID)))
+}
+
+// ignoreUnimplemented allows services which are not supported by the
+// server to be skipped when looking for leftovers
+func ignoreUnimplemented(err error) error {
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	return err
+}
+
+// findLeftovers returns the resources left behind by previous runs with the
+// run ID. Resources are found by their run ID label or by their name.
+func (s *sweeper) findLeftovers(runID string) (map[resourceKind][]resource, error) {
+	names := leftoverNameRegexp(runID)
+	isLeftoverLabel := func(labels map[string]string) bool {
+		id, ok := labels[runIDLabel]
+		return ok && id == runID
+	}
+	leftovers := make(map[resourceKind][]resource)
+	volumes := make(map[string]bool)
+	credentials := make(map[string]bool)
+
+	// Volumes and snapshots. snapshots are the snapshots of the parents of
+	// the leftovers.
+	snapshots := make(map[string]map[string]bool)
+	vc := api.NewOpenStorageVolumeClient(s.conn)
+	volEnum, err := vc.Enumerate(s.context(), &api.SdkVolumeEnumerateRequest{})
+	if err = ignoreUnimplemented(err); err != nil {
+		return nil, err
+	}
+	for _, id := range volEnum.GetVolumeIds() {
+		inspect, err := vc.Inspect(s.context(), &api.SdkVolumeInspectRequest{VolumeId: id})
+		if isNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		v := inspect.GetVolume()
+		if !isLeftoverLabel(inspect.GetLabels()) &&
+			!isLeftoverLabel(v.GetLocator().GetVolumeLabels()) &&
+			!names.MatchString(inspect.GetName()) {
+			continue
+		}
+		kind := kindVolume
+		if parent := v.GetSource().GetParent(); len(parent) != 0 {
+			// Clones have a parent as well, but are not among its
+			// snapshots
+			if _, ok := snapshots[parent]; !ok {
+				snapEnum, err := vc.SnapshotEnumerateWithFilters(s.context(),
+					&api.SdkVolumeSnapshotEnumerateWithFiltersRequest{VolumeId: parent})
+				if err != nil && !isNotFound(err) {
+					return nil, err
+				}
+				snapshots[parent] = make(map[string]bool)
+				for _, snapID := range snapEnum.GetVolumeSnapshotIds() {
+					snapshots[parent][snapID] = true
+				}
+			}
+			if snapshots[parent][id] {
+				kind = kindSnapshot
+			}
+		}
+		leftovers[kind] = append(leftovers[kind], resource{kind: kind, id: id})
+		volumes[id] = true
+	}
+
+	// Credentials
+	cc := api.NewOpenStorageCredentialsClient(s.conn)
+	credEnum, err := cc.Enumerate(s.context(), &api.SdkCredentialEnumerateRequest{})
+	if err = ignoreUnimplemented(err); err != nil {
+		return nil, err
+	}
+	for _, id := range credEnum.GetCredentialIds() {
+		inspect, err := cc.Inspect(s.context(), &api.SdkCredentialInspectRequest{CredentialId: id})
+		if isNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if names.MatchString(inspect.GetName()) {
+			leftovers[kindCredential] = append(leftovers[kindCredential],
+				resource{kind: kindCredential, id: id})
+			credentials[id] = true
+		}
+	}
+
+	// Cloud backups and their schedules, which use the leftover
+	// credentials or volumes
+	bc := api.NewOpenStorageCloudBackupClient(s.conn)
+	backups := make(map[resource]bool)
+	for _, c := range leftovers[kindCredential] {
+		backupEnum, err := bc.EnumerateWithFilters(s.context(),
+			&api.SdkCloudBackupEnumerateWithFiltersRequest{
+				CredentialId: c.id,
+				All:          true,
+			})
+		if err = ignoreUnimplemented(err); err != nil {
+			return nil, err
+		}
+		for _, b := range backupEnum.GetBackups() {
+			backups[resource{kind: kindCloudBackup, id: b.GetSrcVolumeId(), credentialID: c.id}] = true
+		}
+	}
+	for b := range backups {
+		leftovers[kindCloudBackup] = append(leftovers[kindCloudBackup], b)
+	}
+	schedEnum, err := bc.SchedEnumerate(s.context(), &api.SdkCloudBackupSchedEnumerateRequest{})
+	if err = ignoreUnimplemented(err); err != nil {
+		return nil, err
+	}
+	for id, sched := range schedEnum.GetCloudSchedList() {
+		if volumes[sched.GetSrcVolumeId()] || credentials[sched.GetCredentialId()] {
+			leftovers[kindBackupSchedule] = append(leftovers[kindBackupSchedule],
+				resource{kind: kindBackupSchedule, id: id})
+		}
+	}
+
+	// Schedule policies
+	policyEnum, err := api.NewOpenStorageSchedulePolicyClient(s.conn).Enumerate(
+		s.context(), &api.SdkSchedulePolicyEnumerateRequest{})
+	if err = ignoreUnimplemented(err); err != nil {
+		return nil, err
+	}
+	for _, policy := range policyEnum.GetPolicies() {
+		if names.MatchString(policy.GetName()) {
+			leftovers[kindSchedulePolicy] = append(leftovers[kindSchedulePolicy],
+				resource{kind: kindSchedulePolicy, id: policy.GetName()})
+		}
+	}
+
+	// Roles
+	roleEnum, err := api.NewOpenStorageRoleClient(s.conn).Enumerate(
+		s.context(), &api.SdkRoleEnumerateRequest{})
+	if err = ignoreUnimplemented(err); err != nil {
+		return nil, err
+	}
+	for _, name := range roleEnum.GetNames() {
+		if names.MatchString(name) {
+			leftovers[kindRole] = append(leftovers[kindRole],
+				resource{kind: kindRole, id: name})
+		}
+	}
+
+	return leftovers, nil
+}
+
+// cleanupLeftovers connects to the server and deletes the resources left
+// behind by previous runs, like runs which crashed before their AfterSuite.
+// Only the resources of the run ID in the configuration are deleted, which is
+// required so that the resources of runs still in progress, like other CI
+// jobs sharing the cluster, are never deleted.
+func cleanupLeftovers(ctx context.Context, c *SanityConfiguration, w io.Writer) error {
+	if len(c.RunID) == 0 {
+		return fmt.Errorf("Cleaning up leftover resources needs the run ID of the run which left them behind")
+	}
+
+	conn, err := connect(ctx, c.Address)
+	if err != nil {
+		return fmt.Errorf("Unable to connect to %s: %v", c.Address, err)
+	}
+	defer conn.Close()
+
+	var users map[string]string
+	if failures := InterceptGomegaFailures(func() {
+		users = createUsersTokens()
+	}); len(failures) != 0 {
+		return fmt.Errorf("Unable to create tokens: %s", strings.Join(failures, "\n"))
+	}
+
+	s := &sweeper{
+		ctx:   ctx,
+		conn:  conn,
+		token: users["admin"],
+	}
+	leftovers, err := s.findLeftovers(c.RunID)
+	if err != nil {
+		return fmt.Errorf("Unable to find leftover resources: %v", err)
+	}
+	deleted, err := s.sweep(leftovers)
+	for _, r := range deleted {
+		fmt.Fprintf(w, "Deleted %v\n", r)
+	}
+	fmt.Fprintf(w, "Deleted %d leftover resources\n", len(deleted))
+	return err
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+)
+
+const (
+	redacted = "[redacted]"
+
+	// trafficMaxMessage limits the size of the messages printed with a
+	// failure. The files of --sdk.traffic-dir have the whole messages.
+	trafficMaxMessage = 2048
+)
+
+var (
+	// redactedMetadataRegexp matches the metadata keys which hold secrets
+	redactedMetadataRegexp = regexp.MustCompile(`(?i)(authorization|token|secret|password)`)
+	// redactedFieldRegexp matches the message fields which hold secrets,
+	// like the keys of credentials
+	redactedFieldRegexp = regexp.MustCompile(`(?i)(secret|password|passphrase|token|_key$|^key$)`)
+
+	specFilenameRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// trafficCall is a gRPC call made by a spec. Streaming calls have a message
+// for each one sent and received.
+type trafficCall struct {
+	Method    string              `json:"method"`
+	Metadata  map[string][]string `json:"metadata,omitempty"`
+	Requests  []json.RawMessage   `json:"requests"`
+	Responses []json.RawMessage   `json:"responses"`
+	Code      string              `json:"code"`
+	Message   string              `json:"message,omitempty"`
+	Start     time.Time           `json:"start"`
+	Latency   string              `json:"latency"`
+}
+
+// trafficRecorder buffers the gRPC calls of the spec which is running
+type trafficRecorder struct {
+	lock      sync.Mutex
+	recording bool
+	calls     []*trafficCall
+	// specs numbers the files of the specs
+	specs int
+}
+
+func newTrafficRecorder() *trafficRecorder {
+	return &trafficRecorder{}
+}
+
+// start drops the calls recorded so far and records the calls of a spec
+func (t *trafficRecorder) start() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.recording = true
+	t.calls = nil
+}
+
+// stop returns the calls recorded since start, in the order they started
+func (t *trafficRecorder) stop() []*trafficCall {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.recording = false
+	calls := t.calls
+	t.calls = nil
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].Start.Before(calls[j].Start)
+	})
+	return calls
+}
+
+func (t *trafficRecorder) add(call *trafficCall) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.recording {
+		t.calls = append(t.calls, call)
+	}
+}
+
+// finish sets the outcome of a call
+func (t *trafficRecorder) finish(call *trafficCall, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	s := status.Convert(err)
+	call.Code = s.Code().String()
+	call.Message = s.Message()
+	call.Latency = time.Since(call.Start).String()
+}
+
+// newTrafficCall starts the record of a call, with the secrets in its
+// metadata redacted
+func newTrafficCall(ctx context.Context, method string) *trafficCall {
+	call := &trafficCall{
+		Method:    method,
+		Requests:  []json.RawMessage{},
+		Responses: []json.RawMessage{},
+		Start:     time.Now(),
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md) != 0 {
+		call.Metadata = make(map[string][]string)
+		for k, vs := range md {
+			for _, v := range vs {
+				if redactedMetadataRegexp.MatchString(k) {
+					v = redacted
+				}
+				call.Metadata[k] = append(call.Metadata[k], v)
+			}
+		}
+	}
+	return call
+}
+
+// redactFields replaces the values of the fields which hold secrets
+func redactFields(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if redactedFieldRegexp.MatchString(k) {
+				v[k] = redacted
+			} else {
+				v[k] = redactFields(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactFields(v[i])
+		}
+	}
+	return value
+}
+
+// trafficMessage returns the JSON of a message, with its secrets redacted
+func trafficMessage(msg interface{}) json.RawMessage {
+	pb, ok := msg.(proto.Message)
+	if !ok {
+		return json.RawMessage("null")
+	}
+	marshaler := jsonpb.Marshaler{OrigName: true}
+	data, err := marshaler.MarshalToString(pb)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return json.RawMessage("null")
+	}
+	redactedData, err := json.Marshal(redactFields(value))
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(redactedData)
+}
+
+func (t *trafficRecorder) unaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	call := newTrafficCall(ctx, method)
+	call.Requests = append(call.Requests, trafficMessage(req))
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err == nil {
+		call.Responses = append(call.Responses, trafficMessage(reply))
+	}
+	t.finish(call, err)
+	t.add(call)
+	return err
+}
+
+// trafficStream records the messages of a streaming call. The call is
+// finished when a receive fails, which is io.EOF at the end of the stream.
+type trafficStream struct {
+	grpc.ClientStream
+	recorder *trafficRecorder
+	call     *trafficCall
+}
+
+func (s *trafficStream) SendMsg(m interface{}) error {
+	data := trafficMessage(m)
+	s.recorder.lock.Lock()
+	s.call.Requests = append(s.call.Requests, data)
+	s.recorder.lock.Unlock()
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *trafficStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		data := trafficMessage(m)
+		s.recorder.lock.Lock()
+		s.call.Responses = append(s.call.Responses, data)
+		s.recorder.lock.Unlock()
+		return nil
+	}
+	if err == io.EOF {
+		s.recorder.finish(s.call, nil)
+	} else {
+		s.recorder.finish(s.call, err)
+	}
+	return err
+}
+
+func (t *trafficRecorder) streamInterceptor(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	call := newTrafficCall(ctx, method)
+	// Calls which are never finished are reported as incomplete
+	call.Code = "incomplete"
+	t.add(call)
+
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		t.finish(call, err)
+		return nil, err
+	}
+	return &trafficStream{ClientStream: stream, recorder: t, call: call}, nil
+}
+
+func truncateMessage(data json.RawMessage) string {
+	if len(data) <= trafficMaxMessage {
+		return string(data)
+	}
+	return fmt.Sprintf("%s... (%d bytes)", data[:trafficMaxMessage], len(data))
+}
+
+// writeTraffic prints the calls of a spec
+func (t *trafficRecorder) writeTraffic(w io.Writer, calls []*trafficCall) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	fmt.Fprintf(w, "\ngRPC calls of the spec:\n")
+	for _, call := range calls {
+		fmt.Fprintf(w, "%s %s %s (%s)\n",
+			call.Start.Format("15:04:05.000"), call.Method, call.Code, call.Latency)
+		if len(call.Message) != 0 {
+			fmt.Fprintf(w, "  message: %s\n", call.Message)
+		}
+		keys := make([]string, 0, len(call.Metadata))
+		for k := range call.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  metadata: %s=%s\n", k, strings.Join(call.Metadata[k], ","))
+		}
+		for _, req := range call.Requests {
+			fmt.Fprintf(w, "  request: %s\n", truncateMessage(req))
+		}
+		for _, resp := range call.Responses {
+			fmt.Fprintf(w, "  response: %s\n", truncateMessage(resp))
+		}
+	}
+}
+
+// saveTraffic writes the calls of a spec to a file of the directory
+func (t *trafficRecorder) saveTraffic(dir, spec string, failed bool, calls []*trafficCall) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.specs++
+	name := strings.Trim(specFilenameRegexp.ReplaceAllString(strings.ToLower(spec), "-"), "-")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	filename := reportFilename(filepath.Join(dir, fmt.Sprintf("%s-%04d-%s.json", run.id, t.specs, name)))
+
+	data, err := json.MarshalIndent(struct {
+		Spec   string         `json:"spec"`
+		Failed bool           `json:"failed"`
+		Calls  []*trafficCall `json:"calls"`
+	}{spec, failed, calls}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// The calls of every spec are recorded, and printed when the spec fails so
+// that they are part of the failure output
+var _ = BeforeEach(func() {
+	run.traffic.start()
+})
+
+var _ = AfterEach(func() {
+	calls := run.traffic.stop()
+	desc := CurrentGinkgoTestDescription()
+	if desc.Failed && len(calls) != 0 {
+		run.traffic.writeTraffic(GinkgoWriter, calls)
+	}
+	if len(run.config.TrafficDir) != 0 && len(calls) != 0 {
+		if err := run.traffic.saveTraffic(run.config.TrafficDir, desc.FullTestText, desc.Failed, calls); err != nil {
+			fmt.Fprintf(GinkgoWriter, "Failed to save the gRPC calls of the spec: %v\n", err)
+		}
+	}
+})
@@ -0,0 +1,604 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	preUpgradeTag  = "[PreUpgrade]"
+	postUpgradeTag = "[PostUpgrade]"
+
+	phasePreUpgrade  = "pre-upgrade"
+	phasePostUpgrade = "post-upgrade"
+
+	defaultStateFile = "sdk-test-upgrade-state.json"
+
+	// upgradeLabel labels the volumes and snapshots created before the
+	// upgrade
+	upgradeLabel = "sdk-test-upgrade"
+)
+
+// upgradeResource is a resource created before the upgrade, with the parts
+// of its inspected state which must not change across the upgrade
+type upgradeResource struct {
+	Kind resourceKind `json:"kind"`
+	ID   string       `json:"id"`
+	// Owner is the user whose token inspects and uses the resource
+	Owner string          `json:"owner"`
+	State json.RawMessage `json:"state"`
+	// CredentialID is the credential of cloud backups, whose ID is the one
+	// of their source volume
+	CredentialID string `json:"credential_id,omitempty"`
+}
+
+// upgradeState is saved to the state file by the pre-upgrade phase, and
+// loaded by the post-upgrade phase
+type upgradeState struct {
+	filename string
+
+	RunID            string            `json:"run_id"`
+	Created          time.Time         `json:"created"`
+	ServerSdkVersion string            `json:"server_sdk_version"`
+	Resources        []upgradeResource `json:"resources"`
+}
+
+// stateFilename returns the state file of the upgrade phases
+func stateFilename(c *SanityConfiguration) string {
+	if len(c.StateFile) != 0 {
+		return c.StateFile
+	}
+	return defaultStateFile
+}
+
+// newUpgradeState returns the state of a pre-upgrade phase. The file must
+// not exist, so that the resources of an earlier phase are not forgotten.
+func newUpgradeState(filename, runID string) (*upgradeState, error) {
+	if _, err := os.Stat(filename); err == nil {
+		return nil, fmt.Errorf("State file %s exists, run the post-upgrade phase or remove it", filename)
+	}
+	return &upgradeState{
+		filename:  filename,
+		RunID:     runID,
+		Created:   time.Now(),
+		Resources: []upgradeResource{},
+	}, nil
+}
+
+// loadUpgradeState loads the state saved by the pre-upgrade phase
+func loadUpgradeState(filename string) (*upgradeState, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the state of the pre-upgrade phase: %v", err)
+	}
+	s := &upgradeState{filename: filename}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("Invalid state file %s: %v", filename, err)
+	}
+	return s, nil
+}
+
+func (s *upgradeState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(s.filename, data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to write state file %s: %v", s.filename, err)
+	}
+	return nil
+}
+
+// list returns the resources of a kind
+func (s *upgradeState) list(kind resourceKind) []upgradeResource {
+	list := []upgradeResource{}
+	for _, r := range s.Resources {
+		if r.Kind == kind {
+			list = append(list, r)
+		}
+	}
+	return list
+}
+
+// add inspects the resource and saves it to the state file. The resource
+// is removed from the ledger so that it survives the run.
+func (s *upgradeState) add(kind resourceKind, id, owner string) {
+	state, err := inspectStableState(kind, id, run.users[owner])
+	Expect(err).NotTo(HaveOccurred())
+	data, err := (&jsonpb.Marshaler{OrigName: true}).MarshalToString(state)
+	Expect(err).NotTo(HaveOccurred())
+
+	s.ServerSdkVersion = sdkVersionString(run.serverSdkVersion)
+	s.Resources = append(s.Resources, upgradeResource{
+		Kind:  kind,
+		ID:    id,
+		Owner: owner,
+		State: json.RawMessage(data),
+	})
+	Expect(s.save()).To(Succeed())
+	run.ledger.remove(resource{kind: kind, id: id})
+}
+
+// addCloudBackups saves the cloud backups of the volume with the credential
+// to the state file, so that they are deleted after the upgrade. They have
+// no state, since the backups made by a schedule change over time.
+func (s *upgradeState) addCloudBackups(volID, credID string) {
+	s.Resources = append(s.Resources, upgradeResource{
+		Kind:         kindCloudBackup,
+		ID:           volID,
+		Owner:        "admin",
+		CredentialID: credID,
+	})
+	Expect(s.save()).To(Succeed())
+}
+
+// expectUnchanged checks that the resource inspects as it did before the
+// upgrade
+func expectUnchanged(r upgradeResource) {
+	current, err := inspectStableState(r.Kind, r.ID, run.users[r.Owner])
+	Expect(err).NotTo(HaveOccurred(), "%s %s not found after the upgrade", r.Kind, r.ID)
+
+	saved := proto.Clone(current)
+	saved.Reset()
+	Expect(jsonpb.Unmarshal(bytes.NewReader(r.State), saved)).To(Succeed())
+	if !proto.Equal(saved, current) {
+		data, _ := (&jsonpb.Marshaler{OrigName: true}).MarshalToString(current)
+		Fail(fmt.Sprintf("%s %s changed across the upgrade:\nbefore: %s\nafter:  %s",
+			r.Kind, r.ID, string(r.State), data))
+	}
+}
+
+// inspectStableState returns the parts of the inspected state of a resource
+// which must not change across an upgrade. Volumes may be attached or
+// replicated elsewhere after the upgrade, only their identity, source,
+// locator, spec and creation time are kept.
+func inspectStableState(kind resourceKind, id, token string) (proto.Message, error) {
+	ctx := setContextWithToken(context.Background(), token)
+	switch kind {
+	case kindVolume, kindSnapshot:
+		resp, err := api.NewOpenStorageVolumeClient(run.conn).Inspect(ctx,
+			&api.SdkVolumeInspectRequest{VolumeId: id})
+		if err != nil {
+			return nil, err
+		}
+		v := resp.GetVolume()
+		return &api.Volume{
+			Id:       v.GetId(),
+			Source:   v.GetSource(),
+			Group:    v.GetGroup(),
+			Readonly: v.GetReadonly(),
+			Locator:  v.GetLocator(),
+			Ctime:    v.GetCtime(),
+			Spec:     v.GetSpec(),
+			Format:   v.GetFormat(),
+		}, nil
+	case kindSchedulePolicy:
+		resp, err := api.NewOpenStorageSchedulePolicyClient(run.conn).Inspect(ctx,
+			&api.SdkSchedulePolicyInspectRequest{Name: id})
+		if err != nil {
+			return nil, err
+		}
+		return resp.GetPolicy(), nil
+	case kindRole:
+		resp, err := api.NewOpenStorageRoleClient(run.conn).Inspect(ctx,
+			&api.SdkRoleInspectRequest{Name: id})
+		if err != nil {
+			return nil, err
+		}
+		return resp.GetRole(), nil
+	case kindCredential:
+		return api.NewOpenStorageCredentialsClient(run.conn).Inspect(ctx,
+			&api.SdkCredentialInspectRequest{CredentialId: id})
+	case kindBackupSchedule:
+		resp, err := api.NewOpenStorageCloudBackupClient(run.conn).SchedEnumerate(ctx,
+			&api.SdkCloudBackupSchedEnumerateRequest{})
+		if err != nil {
+			return nil, err
+		}
+		info, ok := resp.GetCloudSchedList()[id]
+		if !ok {
+			return nil, fmt.Errorf("Backup schedule %s not enumerated", id)
+		}
+		return info, nil
+	}
+	return nil, fmt.Errorf("Unknown resource kind %s", kind)
+}
+
+// deleteUpgradeResources deletes the resources of the state file after the
+// post-upgrade phase, and then the state file
+func (r *sanityRun) deleteUpgradeResources() error {
+	s := &sweeper{
+		ctx:   context.Background(),
+		conn:  r.conn,
+		token: r.users["admin"],
+	}
+	resources := make(map[resourceKind][]resource)
+	for _, u := range r.upgrade.Resources {
+		resources[u.Kind] = append(resources[u.Kind], resource{
+			kind:         u.Kind,
+			id:           u.ID,
+			credentialID: u.CredentialID,
+		})
+	}
+	if _, err := s.sweep(resources); err != nil {
+		return fmt.Errorf("Failed to delete the resources of the pre-upgrade phase: %v", err)
+	}
+	return os.Remove(r.upgrade.filename)
+}
+
+var _ = Describe("Upgrade", func() {
+	var (
+		vc api.OpenStorageVolumeClient
+	)
+
+	BeforeEach(func() {
+		vc = api.NewOpenStorageVolumeClient(run.conn)
+	})
+
+	Describe("Before the upgrade "+preUpgradeTag, func() {
+
+		It("should create a labelled volume [OpenStorageVolume]", func() {
+			skipUnsupportedService("OpenStorageVolume")
+
+			resp, err := vc.Create(
+				setContextWithToken(context.Background(), run.users["admin"]),
+				&api.SdkVolumeCreateRequest{
+					Name: genName("vol"),
+					Labels: map[string]string{
+						upgradeLabel: "labelled",
+						"tier":       "gold",
+					},
+					Spec: &api.VolumeSpec{
+						Size:    uint64(GIGABYTE),
+						HaLevel: 1,
+						Format:  api.FSType_FS_TYPE_EXT4,
+					},
+				})
+			Expect(err).NotTo(HaveOccurred())
+			run.upgrade.add(kindVolume, resp.GetVolumeId(), "admin")
+		})
+
+		It("should create a volume owned by user1 and shared with a group [OpenStorageVolume]"+sdkVersionTag("OpenStorageVolume/Ownership"), func() {
+			skipUnsupportedService("OpenStorageVolume")
+			if len(run.config.SharedSecret) == 0 {
+				Skip("Volumes have no owner without authentication")
+			}
+
+			ctx := setContextWithToken(context.Background(), run.users["user1"])
+			resp, err := vc.Create(ctx, &api.SdkVolumeCreateRequest{
+				Name:   genName("vol"),
+				Labels: map[string]string{upgradeLabel: "owned"},
+				Spec: &api.VolumeSpec{
+					Size:    uint64(GIGABYTE),
+					HaLevel: 1,
+					Format:  api.FSType_FS_TYPE_EXT4,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = vc.Update(ctx, &api.SdkVolumeUpdateRequest{
+				VolumeId: resp.GetVolumeId(),
+				Spec: &api.VolumeSpecUpdate{
+					Ownership: &api.Ownership{
+						Acls: &api.Ownership_AccessControl{
+							Groups: []string{"users"},
+						},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			run.upgrade.add(kindVolume, resp.GetVolumeId(), "user1")
+		})
+
+		It("should create a snapshot [Snapshot]", func() {
+			skipUnsupportedService("OpenStorageVolume")
+
+			ctx := setContextWithToken(context.Background(), run.users["admin"])
+			volID := newTestVolume(vc)
+			run.upgrade.add(kindVolume, volID, "admin")
+			resp, err := vc.SnapshotCreate(ctx, &api.SdkVolumeSnapshotCreateRequest{
+				VolumeId: volID,
+				Name:     genName("snap"),
+				Labels:   map[string]string{upgradeLabel: "snapshot"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			run.upgrade.add(kindSnapshot, resp.GetSnapshotId(), "admin")
+		})
+
+		It("should create schedule policies [OpenStorageSchedulePolicy]", func() {
+			skipUnsupportedService("OpenStorageSchedulePolicy")
+
+			ctx := setContextWithToken(context.Background(), run.users["admin"])
+			policies := []*api.SdkSchedulePolicy{
+				{
+					Name: genName("policy"),
+					Schedules: []*api.SdkSchedulePolicyInterval{
+						{
+							Retain: 2,
+							PeriodType: &api.SdkSchedulePolicyInterval_Daily{
+								Daily: &api.SdkSchedulePolicyIntervalDaily{
+									Hour:   12,
+									Minute: 30,
+								},
+							},
+						},
+					},
+				},
+				{
+					Name: genName("policy"),
+					Schedules: []*api.SdkSchedulePolicyInterval{
+						{
+							Retain: 4,
+							PeriodType: &api.SdkSchedulePolicyInterval_Weekly{
+								Weekly: &api.SdkSchedulePolicyIntervalWeekly{
+									Day:    api.SdkTimeWeekday_SdkTimeWeekdaySunday,
+									Hour:   3,
+									Minute: 15,
+								},
+							},
+						},
+					},
+				},
+			}
+			for _, policy := range policies {
+				_, err := api.NewOpenStorageSchedulePolicyClient(run.conn).Create(ctx,
+					&api.SdkSchedulePolicyCreateRequest{SchedulePolicy: policy})
+				Expect(err).NotTo(HaveOccurred())
+				run.upgrade.add(kindSchedulePolicy, policy.GetName(), "admin")
+			}
+		})
+
+		It("should create a role [OpenStorageRole]"+sdkVersionTag("OpenStorageRole"), func() {
+			skipUnsupportedService("OpenStorageRole")
+
+			name := genName("role")
+			_, err := api.NewOpenStorageRoleClient(run.conn).Create(
+				setContextWithToken(context.Background(), run.users["admin"]),
+				&api.SdkRoleCreateRequest{
+					Role: &api.SdkRole{
+						Name: name,
+						Rules: []*api.SdkRule{
+							{
+								Services: []string{"identity"},
+								Apis:     []string{"*"},
+							},
+							{
+								Services: []string{"volumes"},
+								Apis:     []string{"inspect", "enumerate*"},
+							},
+						},
+					},
+				})
+			Expect(err).NotTo(HaveOccurred())
+			run.upgrade.add(kindRole, name, "admin")
+		})
+
+		It("should create credentials [OpenStorageCredentials]", func() {
+			if run.config.ProviderConfig == nil {
+				Skip("No cloud provider configuration")
+			}
+
+			for _, credID := range parseAndCreateCredentials2(api.NewOpenStorageCredentialsClient(run.conn)) {
+				run.upgrade.add(kindCredential, credID, "admin")
+			}
+		})
+
+		It("should create cloud backup schedules [OpenStorageCloudBackup]"+sdkVersionTag("OpenStorageCloudBackup/Sched"), func() {
+			skipUnlessCloudBackup(api.NewOpenStorageIdentityClient(run.conn))
+
+			b := newBackupVolume(vc)
+			run.upgrade.add(kindVolume, b.volID, "admin")
+			for _, credID := range b.credIDs {
+				run.upgrade.add(kindCredential, credID, "admin")
+				resp, err := api.NewOpenStorageCloudBackupClient(run.conn).SchedCreate(
+					setContextWithToken(context.Background(), run.users["admin"]),
+					&api.SdkCloudBackupSchedCreateRequest{
+						CloudSchedInfo: &api.SdkCloudBackupScheduleInfo{
+							SrcVolumeId:  b.volID,
+							CredentialId: credID,
+							MaxBackups:   3,
+							Schedules: []*api.SdkSchedulePolicyInterval{
+								{
+									Retain: 1,
+									PeriodType: &api.SdkSchedulePolicyInterval_Daily{
+										Daily: &api.SdkSchedulePolicyIntervalDaily{
+											Hour:   0,
+											Minute: 30,
+										},
+									},
+								},
+							},
+						},
+					})
+				Expect(err).NotTo(HaveOccurred())
+				run.upgrade.add(kindBackupSchedule, resp.GetBackupScheduleId(), "admin")
+				run.upgrade.addCloudBackups(b.volID, credID)
+			}
+		})
+	})
+
+	Describe("After the upgrade "+postUpgradeTag, func() {
+
+		// resources returns the resources of a kind in the state file, and
+		// skips the spec when there are none
+		resources := func(kind resourceKind) []upgradeResource {
+			list := run.upgrade.list(kind)
+			if len(list) == 0 {
+				Skip(fmt.Sprintf("No %s was created before the upgrade", kind))
+			}
+			return list
+		}
+
+		// volumes returns the volumes in the state file owned by the admin, or
+		// by the other users, and skips the spec when there are none
+		volumes := func(admin bool) []upgradeResource {
+			list := []upgradeResource{}
+			for _, r := range run.upgrade.list(kindVolume) {
+				if (r.Owner == "admin") == admin {
+					list = append(list, r)
+				}
+			}
+			if len(list) == 0 && admin {
+				Skip("No volume owned by the admin was created before the upgrade")
+			} else if len(list) == 0 {
+				Skip("No volume owned by a user was created before the upgrade")
+			}
+			return list
+		}
+
+		// expectVolumeUsable checks that the volume is unchanged, and that
+		// its owner can update it and take a snapshot of it
+		expectVolumeUsable := func(r upgradeResource) {
+			expectUnchanged(r)
+
+			By("updating the labels of volume " + r.ID)
+			ctx := setContextWithToken(context.Background(), run.users[r.Owner])
+			_, err := vc.Update(ctx, &api.SdkVolumeUpdateRequest{
+				VolumeId: r.ID,
+				Labels:   map[string]string{upgradeLabel + "-checked": "true"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("taking a snapshot of volume " + r.ID)
+			snap, err := vc.SnapshotCreate(ctx, &api.SdkVolumeSnapshotCreateRequest{
+				VolumeId: r.ID,
+				Name:     genName("snap"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = vc.Delete(ctx, &api.SdkVolumeDeleteRequest{VolumeId: snap.GetSnapshotId()})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("should find admin volumes unchanged and usable [OpenStorageVolume]", func() {
+			for _, r := range volumes(true) {
+				expectVolumeUsable(r)
+			}
+		})
+
+		It("should find volumes owned by users unchanged and usable [OpenStorageVolume]"+sdkVersionTag("OpenStorageVolume/Ownership"), func() {
+			for _, r := range volumes(false) {
+				expectVolumeUsable(r)
+			}
+		})
+
+		It("should find snapshots unchanged and usable [Snapshot]", func() {
+			for _, r := range resources(kindSnapshot) {
+				expectUnchanged(r)
+				ctx := setContextWithToken(context.Background(), run.users[r.Owner])
+
+				By("finding snapshot " + r.ID + " among the snapshots of its volume")
+				snap, err := vc.Inspect(ctx, &api.SdkVolumeInspectRequest{VolumeId: r.ID})
+				Expect(err).NotTo(HaveOccurred())
+				snaps, err := vc.SnapshotEnumerateWithFilters(ctx, &api.SdkVolumeSnapshotEnumerateWithFiltersRequest{
+					VolumeId: snap.GetVolume().GetSource().GetParent(),
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(snaps.GetVolumeSnapshotIds()).To(ContainElement(r.ID))
+
+				By("cloning snapshot " + r.ID)
+				clone, err := vc.Clone(ctx, &api.SdkVolumeCloneRequest{
+					Name:     genName("clone"),
+					ParentId: r.ID,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(deleteVol(ctx, vc, clone.GetVolumeId())).To(Succeed())
+			}
+		})
+
+		It("should find schedule policies unchanged and usable [OpenStorageSchedulePolicy]", func() {
+			c := api.NewOpenStorageSchedulePolicyClient(run.conn)
+			for _, r := range resources(kindSchedulePolicy) {
+				expectUnchanged(r)
+
+				By("updating schedule policy " + r.ID + " with its own content")
+				ctx := setContextWithToken(context.Background(), run.users[r.Owner])
+				resp, err := c.Inspect(ctx, &api.SdkSchedulePolicyInspectRequest{Name: r.ID})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = c.Update(ctx, &api.SdkSchedulePolicyUpdateRequest{SchedulePolicy: resp.GetPolicy()})
+				Expect(err).NotTo(HaveOccurred())
+				expectUnchanged(r)
+			}
+		})
+
+		It("should find roles unchanged and usable [OpenStorageRole]"+sdkVersionTag("OpenStorageRole"), func() {
+			c := api.NewOpenStorageRoleClient(run.conn)
+			for _, r := range resources(kindRole) {
+				expectUnchanged(r)
+
+				By("updating role " + r.ID + " with its own rules")
+				ctx := setContextWithToken(context.Background(), run.users[r.Owner])
+				resp, err := c.Inspect(ctx, &api.SdkRoleInspectRequest{Name: r.ID})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = c.Update(ctx, &api.SdkRoleUpdateRequest{Role: resp.GetRole()})
+				Expect(err).NotTo(HaveOccurred())
+				expectUnchanged(r)
+			}
+		})
+
+		It("should find credentials unchanged and usable [OpenStorageCredentials]"+sdkVersionTag("OpenStorageCredentials/Validate"), func() {
+			c := api.NewOpenStorageCredentialsClient(run.conn)
+			for _, r := range resources(kindCredential) {
+				expectUnchanged(r)
+
+				By("validating credential " + r.ID)
+				_, err := c.Validate(
+					setContextWithToken(context.Background(), run.users[r.Owner]),
+					&api.SdkCredentialValidateRequest{CredentialId: r.ID})
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("should find cloud backup schedules unchanged [OpenStorageCloudBackup]"+sdkVersionTag("OpenStorageCloudBackup/Sched"), func() {
+			for _, r := range resources(kindBackupSchedule) {
+				expectUnchanged(r)
+			}
+		})
+
+		It("should enumerate the cloud backups made by the schedules [OpenStorageCloudBackup]"+sdkVersionTag("OpenStorageCloudBackup/Sched"), func() {
+			bc := api.NewOpenStorageCloudBackupClient(run.conn)
+			for _, r := range resources(kindCloudBackup) {
+				By("enumerating the backups of volume " + r.ID + " with credential " + r.CredentialID)
+				resp, err := bc.EnumerateWithFilters(
+					setContextWithToken(context.Background(), run.users[r.Owner]),
+					&api.SdkCloudBackupEnumerateWithFiltersRequest{
+						SrcVolumeId:  r.ID,
+						CredentialId: r.CredentialID,
+					})
+				Expect(err).NotTo(HaveOccurred())
+				// The schedule may not have run yet, but the backups it
+				// made must be of the volume
+				for _, backup := range resp.GetBackups() {
+					Expect(backup.GetSrcVolumeId()).To(Equal(r.ID))
+					Expect(backup.GetId()).NotTo(BeEmpty())
+				}
+			}
+		})
+	})
+})
@@ -49,7 +49,7 @@ func createUsersTokens() map[string]string {
 		Groups:  []string{"users"},
 	}, &auth.Options{
 		Expiration: time.Now().Add(1 * time.Hour).Unix(),
-	}, config.SharedSecret)
+	}, run.config.SharedSecret)
 	users["user1"] = user1
 
 	// user1
@@ -61,7 +61,7 @@ func createUsersTokens() map[string]string {
 		Groups:  []string{"users"},
 	}, &auth.Options{
 		Expiration: time.Now().Add(1 * time.Hour).Unix(),
-	}, config.SharedSecret)
+	}, run.config.SharedSecret)
 	users["user2"] = user2
 
 	// user1
@@ -73,19 +73,13 @@ func createUsersTokens() map[string]string {
 		Groups:  []string{"users", "testers"},
 	}, &auth.Options{
 		Expiration: time.Now().Add(1 * time.Hour).Unix(),
-	}, config.SharedSecret)
+	}, run.config.SharedSecret)
 	users["user3"] = user3
 
 	// admin
-	admin := createToken(&auth.Claims{
-		Subject: "admin",
-		Name:    "admin",
-		Email:   "admin@user",
-		Roles:   []string{"system.admin"},
-		Groups:  []string{"*"},
-	}, &auth.Options{
+	admin := createToken(newAdminClaims(), &auth.Options{
 		Expiration: time.Now().Add(1 * time.Hour).Unix(),
-	}, config.SharedSecret)
+	}, run.config.SharedSecret)
 	users["admin"] = admin
 
 	// expired
@@ -96,12 +90,23 @@ func createUsersTokens() map[string]string {
 		Roles:   []string{"system.view"},
 	}, &auth.Options{
 		Expiration: time.Now().Add(-1 * time.Hour).Unix(),
-	}, config.SharedSecret)
+	}, run.config.SharedSecret)
 	users["expired"] = expired
 
 	return users
 }
 
+// newAdminClaims returns the claims of the admin user
+func newAdminClaims() *auth.Claims {
+	return &auth.Claims{
+		Subject: "admin",
+		Name:    "admin",
+		Email:   "admin@user",
+		Roles:   []string{"system.admin"},
+		Groups:  []string{"*"},
+	}
+}
+
 func setContextWithToken(ctx context.Context, token string) context.Context {
 	md := metadata.New(map[string]string{
 		"authorization": "bearer " + token,
@@ -153,68 +158,9 @@ func testVolumeCreation(req *api.SdkVolumeCreateRequest) {
 
 }
 
-// This will create credential for provider listed from cb.yaml file
-func parseAndCreateCredentials(credClient api.OpenStorageCredentialsClient) int {
-	numCredCreated := 0
-	for provider, providerParams := range config.ProviderConfig.CloudProviders {
-		if provider == "aws" {
-			credReq := &api.SdkCredentialCreateRequest{
-				Name: providerParams["CredName"],
-				CredentialType: &api.SdkCredentialCreateRequest_AwsCredential{
-					AwsCredential: &api.SdkAwsCredentialRequest{
-						AccessKey:  providerParams["CredAccessKey"],
-						SecretKey:  providerParams["CredSecretKey"],
-						Endpoint:   providerParams["CredEndpoint"],
-						Region:     providerParams["CredRegion"],
-						DisableSsl: providerParams["CredDisableSSL"] == "true",
-					},
-				},
-			}
-
-			credResp, err := credClient.Create(setContextWithToken(context.Background(), users["admin"]), credReq)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(credResp.GetCredentialId()).NotTo(BeEmpty())
-			numCredCreated++
-
-		} else if provider == "azure" {
-			credReq := &api.SdkCredentialCreateRequest{
-				Name: providerParams["CredName"],
-				CredentialType: &api.SdkCredentialCreateRequest_AzureCredential{
-					AzureCredential: &api.SdkAzureCredentialRequest{
-						AccountKey:  providerParams["CredAccountName"],
-						AccountName: providerParams["CredAccountKey"],
-					},
-				},
-			}
-
-			credResp, err := credClient.Create(setContextWithToken(context.Background(), users["admin"]), credReq)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(credResp.GetCredentialId()).NotTo(BeEmpty())
-			numCredCreated++
-
-		} else if provider == "google" {
-			credReq := &api.SdkCredentialCreateRequest{
-				Name: providerParams["CredName"],
-				CredentialType: &api.SdkCredentialCreateRequest_GoogleCredential{
-					GoogleCredential: &api.SdkGoogleCredentialRequest{
-						ProjectId: providerParams["CredProjectID"],
-						JsonKey:   providerParams["CredJsonKey"],
-					},
-				},
-			}
-
-			credResp, err := credClient.Create(setContextWithToken(context.Background(), users["admin"]), credReq)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(credResp.GetCredentialId()).NotTo(BeEmpty())
-			numCredCreated++
-		}
-	}
-	return numCredCreated
-}
-
 func newTestVolume(volClient api.OpenStorageVolumeClient) string {
 	volReq := &api.SdkVolumeCreateRequest{
-		Name: fmt.Sprintf("sdk-vol-%v", time.Now().Unix()),
+		Name: genName("vol"),
 		Spec: &api.VolumeSpec{
 			Size:      uint64(5 * GIGABYTE),
 			Shared:    false,
@@ -224,7 +170,7 @@ func newTestVolume(volClient api.OpenStorageVolumeClient) string {
 			Format:    api.FSType_FS_TYPE_XFS,
 		},
 	}
-	volResp, err := volClient.Create(setContextWithToken(context.Background(), users["admin"]), volReq)
+	volResp, err := volClient.Create(setContextWithToken(context.Background(), run.users["admin"]), volReq)
 	Expect(err).NotTo(HaveOccurred())
 	Expect(volResp).NotTo(BeNil())
 	Expect(volResp.VolumeId).NotTo(BeEmpty())
@@ -234,7 +180,7 @@ func newTestVolume(volClient api.OpenStorageVolumeClient) string {
 
 func newTestCredential(credClient api.OpenStorageCredentialsClient) string {
 	credReq := &api.SdkCredentialCreateRequest{
-		Name: "test-credential",
+		Name: genName("cred"),
 		CredentialType: &api.SdkCredentialCreateRequest_AwsCredential{
 			AwsCredential: &api.SdkAwsCredentialRequest{
 				AccessKey: "aws-access-key",
@@ -245,7 +191,7 @@ func newTestCredential(credClient api.OpenStorageCredentialsClient) string {
 		},
 	}
 
-	credResp, err := credClient.Create(setContextWithToken(context.Background(), users["admin"]), credReq)
+	credResp, err := credClient.Create(setContextWithToken(context.Background(), run.users["admin"]), credReq)
 	Expect(err).NotTo(HaveOccurred())
 	Expect(credResp.GetCredentialId()).NotTo(BeEmpty())
 	return credResp.GetCredentialId()
@@ -254,11 +200,11 @@ func newTestCredential(credClient api.OpenStorageCredentialsClient) string {
 // This will create credential for provider listed from cb.yaml file
 func parseAndCreateCredentials2(credClient api.OpenStorageCredentialsClient) map[string]string {
 	credMap := make(map[string]string)
-	for provider, providerParams := range config.ProviderConfig.CloudProviders {
+	for provider, providerParams := range run.config.ProviderConfig.CloudProviders {
 		if provider == "aws" {
 
 			credReq := &api.SdkCredentialCreateRequest{
-				Name: providerParams["CredName"],
+				Name: genName(providerParams["CredName"]),
 				CredentialType: &api.SdkCredentialCreateRequest_AwsCredential{
 					AwsCredential: &api.SdkAwsCredentialRequest{
 						AccessKey:  providerParams["CredAccessKey"],
@@ -270,14 +216,14 @@ func parseAndCreateCredentials2(credClient api.OpenStorageCredentialsClient) map
 				},
 			}
 
-			credResp, err := credClient.Create(setContextWithToken(context.Background(), users["admin"]), credReq)
+			credResp, err := credClient.Create(setContextWithToken(context.Background(), run.users["admin"]), credReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(credResp.GetCredentialId()).NotTo(BeEmpty())
 			credMap["aws"] = credResp.GetCredentialId()
 
 		} else if provider == "azure" {
 			credReq := &api.SdkCredentialCreateRequest{
-				Name: providerParams["CredName"],
+				Name: genName(providerParams["CredName"]),
 				CredentialType: &api.SdkCredentialCreateRequest_AzureCredential{
 					AzureCredential: &api.SdkAzureCredentialRequest{
 						AccountKey:  providerParams["CredAccountName"],
@@ -285,14 +231,14 @@ func parseAndCreateCredentials2(credClient api.OpenStorageCredentialsClient) map
 					},
 				},
 			}
-			credResp, err := credClient.Create(setContextWithToken(context.Background(), users["admin"]), credReq)
+			credResp, err := credClient.Create(setContextWithToken(context.Background(), run.users["admin"]), credReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(credResp.GetCredentialId()).NotTo(BeEmpty())
 			credMap["azure"] = credResp.GetCredentialId()
 
 		} else if provider == "google" {
 			credReq := &api.SdkCredentialCreateRequest{
-				Name: providerParams["CredName"],
+				Name: genName(providerParams["CredName"]),
 				CredentialType: &api.SdkCredentialCreateRequest_GoogleCredential{
 					GoogleCredential: &api.SdkGoogleCredentialRequest{
 						ProjectId: providerParams["CredProjectID"],
@@ -301,7 +247,7 @@ func parseAndCreateCredentials2(credClient api.OpenStorageCredentialsClient) map
 				},
 			}
 
-			credResp, err := credClient.Create(setContextWithToken(context.Background(), users["admin"]), credReq)
+			credResp, err := credClient.Create(setContextWithToken(context.Background(), run.users["admin"]), credReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(credResp.GetCredentialId()).NotTo(BeEmpty())
 			credMap["google"] = credResp.GetCredentialId()
@@ -315,7 +261,7 @@ func isCapabilitySupported(c api.OpenStorageIdentityClient,
 ) bool {
 
 	caps, err := c.Capabilities(
-		setContextWithToken(context.Background(), users["admin"]),
+		setContextWithToken(internalContext(context.Background()), run.users["admin"]),
 		&api.SdkIdentityCapabilitiesRequest{})
 	Expect(err).NotTo(HaveOccurred())
 	Expect(caps).NotTo(BeNil())
@@ -333,7 +279,7 @@ func isCapabilitySupported(c api.OpenStorageIdentityClient,
 func createToken(claims *auth.Claims, options *auth.Options, sharedSecret string) string {
 
 	if len(claims.Issuer) == 0 {
-		claims.Issuer = config.Issuer
+		claims.Issuer = run.config.Issuer
 	}
 
 	// This never fails
@@ -0,0 +1,226 @@
+/*
+Copyright 2019 Portworx
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	api "github.com/libopenstorage/openstorage-sdk-clients/sdk/golang"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// clientSdkVersion is the version of the SDK client vendored by sdk-test
+var clientSdkVersion = &api.SdkVersion{
+	Major: int32(api.SdkVersion_Major),
+	Minor: int32(api.SdkVersion_Minor),
+	Patch: int32(api.SdkVersion_Patch),
+}
+
+func sdkVersionString(v *api.SdkVersion) string {
+	return fmt.Sprintf("%d.%d.%d", v.GetMajor(), v.GetMinor(), v.GetPatch())
+}
+
+// sdkVersionCompare returns -1, 0, or 1 if a is older, equal or newer than b
+func sdkVersionCompare(a, b *api.SdkVersion) int {
+	for _, d := range []int32{
+		a.GetMajor() - b.GetMajor(),
+		a.GetMinor() - b.GetMinor(),
+		a.GetPatch() - b.GetPatch(),
+	} {
+		if d < 0 {
+			return -1
+		} else if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+func getServerSdkVersion(ic api.OpenStorageIdentityClient) (*api.SdkVersion, error) {
+	res, err := ic.Version(
+		setContextWithToken(internalContext(context.Background()), run.users["admin"]),
+		&api.SdkIdentityVersionRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if res.GetSdkVersion() == nil {
+		return nil, fmt.Errorf("Server did not return an SDK version")
+	}
+	return res.GetSdkVersion(), nil
+}
+
+// sdkApi is an API added after the first SDK release
+type sdkApi struct {
+	// name is a service, like OpenStorageRole, or the prefix of its RPCs,
+	// like OpenStorageCloudBackup/Sched
+	name string
+	// version is the SDK version which introduced the API
+	version *api.SdkVersion
+	// tag and words match the texts of the specs which test the API
+	tag   string
+	words *regexp.Regexp
+}
+
+// sdkApis are the APIs whose specs must declare their SDK version with
+// sdkVersionTag, so they are skipped on older servers
+var sdkApis = []sdkApi{
+	{"OpenStorageCredentials/Validate", &api.SdkVersion{Major: 0, Minor: 12}, "[OpenStorageCredentials]", regexp.MustCompile(`(?i)validate|verify`)},
+	{"OpenStorageCloudBackup/History", &api.SdkVersion{Major: 0, Minor: 15}, "[OpenStorageCloudBackup]", regexp.MustCompile(`(?i)history`)},
+	{"OpenStorageCloudBackup/Sched", &api.SdkVersion{Major: 0, Minor: 17}, "[OpenStorageCloudBackup]", regexp.MustCompile(`(?i)sched`)},
+	{"OpenStorageClusterPair", &api.SdkVersion{Major: 0, Minor: 19}, "[OpenStorageClusterPair]", nil},
+	{"OpenStorageVolume/Ownership", &api.SdkVersion{Major: 0, Minor: 22}, "[OpenStorageVolume]", regexp.MustCompile(`(?i)\bown(ed|er|ers|ership)\b`)},
+	{"OpenStorageRole", &api.SdkVersion{Major: 0, Minor: 26}, "[OpenStorageRole]", nil},
+}
+
+var sdkVersionTagRegexp = regexp.MustCompile(`\[SDK (\d+)\.(\d+)\.(\d+)\]`)
+
+// sdkVersionTag returns the tag declaring the SDK version needed by the specs
+// of an API, a service or one of its RPCs, after a space, or an empty string
+// for the APIs of the first SDK release. It is appended to the text of a
+// Describe or It.
+func sdkVersionTag(name string) string {
+	version := sdkApiVersion(name)
+	if version == nil {
+		return ""
+	}
+	return " [SDK " + sdkVersionString(version) + "]"
+}
+
+// sdkApiVersion returns the SDK version which introduced an API, a service or
+// one of its RPCs, or nil for the APIs of the first SDK release
+func sdkApiVersion(name string) *api.SdkVersion {
+	var version *api.SdkVersion
+	for _, a := range sdkApis {
+		if strings.HasPrefix(name, a.name) &&
+			(version == nil || sdkVersionCompare(a.version, version) > 0) {
+			version = a.version
+		}
+	}
+	return version
+}
+
+// specSdkVersion returns the newest SDK version declared in the text of a
+// spec, or nil
+func specSdkVersion(text string) *api.SdkVersion {
+	var version *api.SdkVersion
+	for _, m := range sdkVersionTagRegexp.FindAllStringSubmatch(text, -1) {
+		major, _ := strconv.Atoi(m[1])
+		minor, _ := strconv.Atoi(m[2])
+		patch, _ := strconv.Atoi(m[3])
+		v := &api.SdkVersion{Major: int32(major), Minor: int32(minor), Patch: int32(patch)}
+		if version == nil || sdkVersionCompare(v, version) > 0 {
+			version = v
+		}
+	}
+	return version
+}
+
+// undeclaredSdkApis returns the APIs tested by a spec, according to its
+// text, whose SDK version is not declared by its tags
+func undeclaredSdkApis(text string) []string {
+	declared := specSdkVersion(text)
+	names := []string{}
+	for _, a := range sdkApis {
+		if !strings.Contains(text, a.tag) || (a.words != nil && !a.words.MatchString(text)) {
+			continue
+		}
+		if declared == nil || sdkVersionCompare(declared, a.version) < 0 {
+			names = append(names, a.name)
+		}
+	}
+	return names
+}
+
+// Every spec declaring an SDK version with sdkVersionTag requires it
+var _ = BeforeEach(func() {
+	if version := specSdkVersion(CurrentGinkgoTestDescription().FullTestText); version != nil {
+		requireSdkVersion(version.GetMajor(), version.GetMinor(), version.GetPatch())
+	}
+})
+
+// requireSdkVersion skips the current test if the server reports an SDK
+// version older than major.minor.patch. It is called for the tests tagged
+// with sdkVersionTag.
+func requireSdkVersion(major, minor, patch int32) {
+	required := &api.SdkVersion{
+		Major: major,
+		Minor: minor,
+		Patch: patch,
+	}
+	if sdkVersionCompare(run.serverSdkVersion, required) < 0 {
+		Skip(fmt.Sprintf("Requires SDK version %s or newer, server reports %s",
+			sdkVersionString(required),
+			sdkVersionString(run.serverSdkVersion)), 1)
+	}
+}
+
+// sdkVersionUnaryInterceptor catches servers which report an SDK version at
+// least as new as the one which introduced an RPC, but return
+// codes.Unimplemented for it. Such calls are recorded and reported at the
+// end of the suite.
+func (r *sanityRun) sdkVersionUnaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if status.Code(err) != codes.Unimplemented || r.serverSdkVersion == nil {
+		return err
+	}
+	// The method is like /openstorage.api.OpenStorageVolume/Create
+	name := method[strings.LastIndex(method, ".")+1:]
+	if introduced := sdkApiVersion(name); introduced != nil &&
+		sdkVersionCompare(r.serverSdkVersion, introduced) < 0 {
+		return err
+	}
+
+	r.lock.Lock()
+	r.unimplementedApis[method] = true
+	r.lock.Unlock()
+
+	return status.Errorf(codes.Unimplemented,
+		"Server reports SDK version %s which includes %s, but it is not implemented: %s",
+		sdkVersionString(r.serverSdkVersion),
+		method,
+		status.Convert(err).Message())
+}
+
+// getUnimplementedApis returns the sorted list of APIs recorded by
+// sdkVersionUnaryInterceptor
+func (r *sanityRun) getUnimplementedApis() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	methods := make([]string, 0, len(r.unimplementedApis))
+	for method := range r.unimplementedApis {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
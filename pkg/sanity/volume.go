@@ -17,9 +17,6 @@ limitations under the License.
 package sanity
 
 import (
-	"fmt"
-	"time"
-
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
@@ -39,9 +36,9 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 	)
 
 	BeforeEach(func() {
-		c = api.NewOpenStorageVolumeClient(conn)
-		ic = api.NewOpenStorageIdentityClient(conn)
-		ma = api.NewOpenStorageMountAttachClient(conn)
+		c = api.NewOpenStorageVolumeClient(run.conn)
+		ic = api.NewOpenStorageIdentityClient(run.conn)
+		ma = api.NewOpenStorageMountAttachClient(run.conn)
 
 		isSupported := isCapabilitySupported(
 			ic,
@@ -65,7 +62,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		AfterEach(func() {
 			if len(volID) != 0 {
 				err := deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					c,
 					volID)
 				Expect(err).NotTo(HaveOccurred())
@@ -75,7 +72,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		It("should create a volume and return the volume uuid", func() {
 
 			req := &api.SdkVolumeCreateRequest{
-				Name: fmt.Sprintf("sdk-vol-%v", time.Now().Unix()),
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					Shared:    false,
@@ -85,7 +82,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 					Format:    api.FSType_FS_TYPE_XFS,
 				},
 			}
-			createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(createResponse).NotTo(BeNil())
 			Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -95,7 +92,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			inspectReq := &api.SdkVolumeInspectRequest{
 				VolumeId: createResponse.VolumeId,
 			}
-			inspectResponse, err := c.Inspect(setContextWithToken(context.Background(), users["admin"]), inspectReq)
+			inspectResponse, err := c.Inspect(setContextWithToken(context.Background(), run.users["admin"]), inspectReq)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(inspectResponse).NotTo(BeNil())
 
@@ -110,7 +107,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 					Size: uint64(5 * GIGABYTE),
 				},
 			}
-			info, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			info, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(info).To(BeNil())
 			Expect(err).To(HaveOccurred())
 
@@ -121,12 +118,12 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 		It("Should return error if size is zero", func() {
 			req := &api.SdkVolumeCreateRequest{
-				Name: "zero-size-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size: uint64(0 * GIGABYTE),
 				},
 			}
-			info, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			info, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(info).To(BeNil())
 
 			Expect(err).To(HaveOccurred())
@@ -136,32 +133,8 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			Expect(serverError.Code()).To(BeEquivalentTo(codes.Internal))
 		})
 
-		// TODO: Fake Driver replaces the volume if a volume name already exists
-		// Error code that must be returned is codes.AlreadyExists
-
-		// It("Should return error if creating a volume with same name that exists", func() {
-
-		// 	By("Creating a volume")
-		// 	req := &api.SdkVolumeCreateRequest{
-		// 		Name: "already-exists-vol",
-		// 		Spec: &api.VolumeSpec{
-		// 			Size: uint64(5 * GIGABYTE),
-		// 		},
-		// 	}
-		// 	info, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
-		// 	Expect(err).NotTo(HaveOccurred())
-		// 	Expect(info.VolumeId).NotTo(BeEmpty())
-
-		// 	By("Creating a volume with existing volume name")
-		// 	info, err = c.Create(setContextWithToken(context.Background(), users["admin"]), req)
-
-		// 	Expect(err).To(HaveOccurred())
-		// 	Expect(info.VolumeId).To(BeEmpty())
-
-		// 	serverError, ok := status.FromError(err)
-		// 	Expect(ok).To(BeTrue())
-		// 	Expect(serverError.Code()).To(BeEquivalentTo(codes.AlreadyExists))
-		// })
+		// Creating a volume again with the same name is tested in
+		// idempotency.go
 	})
 
 	Describe("Volume Inspect", func() {
@@ -176,7 +149,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		AfterEach(func() {
 			if len(volID) != 0 {
 				err := deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					c,
 					volID)
 				Expect(err).NotTo(HaveOccurred())
@@ -187,13 +160,13 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			By("Creating a volume")
 			req := &api.SdkVolumeCreateRequest{
-				Name: "inspect-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:    uint64(5 * GIGABYTE),
 					HaLevel: 2,
 				},
 			}
-			createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(createResponse).NotTo(BeNil())
 			Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -201,7 +174,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			By("Inspecting the created volume")
 			resp, err := c.Inspect(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeInspectRequest{
 					VolumeId: volID,
 				},
@@ -219,7 +192,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 	// 	By("Using a volume id that doesn't exist")
 
 	// 	resp, err := c.Inspect(
-	// 		setContextWithToken(context.Background(), users["admin"]),
+	// 		setContextWithToken(context.Background(), run.users["admin"]),
 	// 		&api.SdkVolumeInspectRequest{
 	// 			VolumeId: "junk-id-doesnt-exist",
 	// 		},
@@ -245,13 +218,13 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			By("Creating the volume first")
 			req := &api.SdkVolumeCreateRequest{
-				Name: "delete-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:    uint64(5 * GIGABYTE),
 					HaLevel: 3,
 				},
 			}
-			createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(createResponse).NotTo(BeNil())
 			Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -259,7 +232,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			By("Deleting the created volume")
 			_, err = c.Delete(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeDeleteRequest{
 					VolumeId: volID,
 				},
@@ -274,7 +247,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// It("Should throw a error for deleting a non-existent volume", func() {
 
 		// 	_, err := c.Delete(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkVolumeDeleteRequest{
 		// 			VolumeId: "dummy-id",
 		// 		},
@@ -290,7 +263,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		It("Should throw a error for passing empty volume id", func() {
 
 			_, err := c.Delete(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeDeleteRequest{
 					VolumeId: "",
 				},
@@ -315,7 +288,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		AfterEach(func() {
 			for _, id := range volIDs {
 				err := deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					c,
 					id)
 				Expect(err).NotTo(HaveOccurred())
@@ -335,7 +308,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 				},
 		// 			},
 		// 		}
-		// 		createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+		// 		createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 		// 		Expect(err).NotTo(HaveOccurred())
 		// 		Expect(createResponse).NotTo(BeNil())
 		// 		Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -355,7 +328,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 				},
 		// 			},
 		// 		}
-		// 		createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+		// 		createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 		// 		Expect(err).NotTo(HaveOccurred())
 		// 		Expect(createResponse).NotTo(BeNil())
 		// 		Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -366,7 +339,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 	By("Enumerating the volumes that match the label")
 
 		// 	resp, err := c.Enumerate(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkVolumeEnumerateRequest{
 		// 			Locator: &api.VolumeLocator{
 		// 				VolumeLabels: map[string]string{
@@ -383,7 +356,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 	By("Enumerating all the volumes in the cluster")
 
 		// 	resp, err = c.Enumerate(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkVolumeEnumerateRequest{
 		// 			Locator: &api.VolumeLocator{
 		// 				VolumeLabels: map[string]string{},
@@ -397,7 +370,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// })
 
 		It("Should throw appropriate error when failed to enumerate", func() {
-			_, err := c.Enumerate(setContextWithToken(context.Background(), users["admin"]), nil)
+			_, err := c.Enumerate(setContextWithToken(context.Background(), run.users["admin"]), nil)
 			Expect(err).To(HaveOccurred())
 			serverError, ok := status.FromError(err)
 			Expect(ok).To(BeTrue())
@@ -420,7 +393,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 				// Detach the attached Volume
 				// before deleting
 				detachResponse, err := ma.Detach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeDetachRequest{
 						VolumeId: volID,
 						Options: &api.SdkVolumeDetachOptions{
@@ -431,7 +404,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(detachResponse).NotTo(BeNil())
 				err = deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					c,
 					volID)
 				Expect(err).NotTo(HaveOccurred())
@@ -442,13 +415,13 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			By("Creating the volume first")
 			req := &api.SdkVolumeCreateRequest{
-				Name: "attach-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:    uint64(5 * GIGABYTE),
 					HaLevel: 1,
 				},
 			}
-			createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(createResponse).NotTo(BeNil())
 			Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -457,7 +430,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			By("Attaching the created Volume")
 
 			resp, err := ma.Attach(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeAttachRequest{
 					VolumeId: volID,
 				},
@@ -475,7 +448,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 	By("Passing a non-existent volume id for Attach")
 
 		// 	resp, err := ma.Attach(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkVolumeAttachRequest{
 		// 			VolumeId: "attach-doesnt-exist",
 		// 		},
@@ -493,7 +466,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			By("Passing a empty volume id for Attach")
 
 			resp, err := ma.Attach(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeAttachRequest{
 					VolumeId: "",
 				},
@@ -520,7 +493,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		AfterEach(func() {
 			if volID != "" {
 				err := deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					c,
 					volID)
 				Expect(err).NotTo(HaveOccurred())
@@ -531,13 +504,13 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 			By("Creating the volume first")
 			req := &api.SdkVolumeCreateRequest{
-				Name: "detach-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:    uint64(5 * GIGABYTE),
 					HaLevel: 3,
 				},
 			}
-			createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(createResponse).NotTo(BeNil())
 			Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -546,7 +519,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			By("Attaching the created Volume")
 
 			resp, err := ma.Attach(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeAttachRequest{
 					VolumeId: volID,
 				},
@@ -558,7 +531,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			By("Detaching the attached volume")
 
 			detachResponse, err := ma.Detach(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeDetachRequest{
 					VolumeId: volID,
 					Options: &api.SdkVolumeDetachOptions{
@@ -582,7 +555,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 			Size: uint64(5 * GIGABYTE),
 		// 		},
 		// 	}
-		// 	createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+		// 	createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 		// 	Expect(err).NotTo(HaveOccurred())
 		// 	Expect(createResponse).NotTo(BeNil())
 		// 	Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -591,7 +564,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 	By("Detaching a non-attached volume")
 
 		// 	_, err = ma.Detach(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkVolumeDetachRequest{
 		// 			VolumeId: volID,
 		// 		},
@@ -608,7 +581,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 	By("Detaching a non-existent volume")
 
 		// 	_, err := ma.Detach(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkVolumeDetachRequest{
 		// 			VolumeId: "dummy-doesn't exist",
 		// 		},
@@ -625,7 +598,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			By("Detaching a non-existent volume")
 
 			_, err := ma.Detach(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeDetachRequest{
 					VolumeId: "",
 					Options: &api.SdkVolumeDetachOptions{
@@ -648,7 +621,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		BeforeEach(func() {
 			volID = ""
 
-			if len(config.MountPath) == 0 {
+			if len(run.config.MountPath) == 0 {
 				Skip("Mount path was not provided")
 			}
 		})
@@ -658,10 +631,10 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 				// Unmount the mounted volume first
 				// before deleting
 				unmountResponse, err := ma.Unmount(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeUnmountRequest{
 						VolumeId:  volID,
-						MountPath: config.MountPath,
+						MountPath: run.config.MountPath,
 					},
 				)
 				Expect(err).NotTo(HaveOccurred())
@@ -669,7 +642,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 				// Detach the attached Volume
 				// before deleting
 				detachResponse, err := ma.Detach(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					&api.SdkVolumeDetachRequest{
 						VolumeId: volID,
 						Options: &api.SdkVolumeDetachOptions{
@@ -681,7 +654,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 				Expect(detachResponse).NotTo(BeNil())
 
 				err = deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					c,
 					volID)
 				Expect(err).NotTo(HaveOccurred())
@@ -691,7 +664,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		It("Should Mount the volume successfully", func() {
 			By("Creating the volume first")
 			req := &api.SdkVolumeCreateRequest{
-				Name: "mount-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					HaLevel:   3,
@@ -700,7 +673,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 					Format:    api.FSType_FS_TYPE_XFS,
 				},
 			}
-			createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(createResponse).NotTo(BeNil())
 			Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -709,7 +682,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			By("Attaching the created Volume")
 
 			resp, err := ma.Attach(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeAttachRequest{
 					VolumeId: volID,
 				},
@@ -720,10 +693,10 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			By("Mounting the attached Volume")
 
 			mountResponse, err := ma.Mount(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeMountRequest{
 					VolumeId:  volID,
-					MountPath: config.MountPath,
+					MountPath: run.config.MountPath,
 				},
 			)
 			Expect(err).NotTo(HaveOccurred())
@@ -737,12 +710,12 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 		// 	By("Creating the volume first")
 		// 	req := &api.SdkVolumeCreateRequest{
-		// 		Name: "mount-vol",
+		// 		Name: genName("vol"),
 		// 		Spec: &api.VolumeSpec{
 		// 			Size: uint64(5 * GIGABYTE),
 		// 		},
 		// 	}
-		// 	createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+		// 	createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 		// 	Expect(err).NotTo(HaveOccurred())
 		// 	Expect(createResponse).NotTo(BeNil())
 		// 	Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -751,7 +724,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 	By("Attaching the created Volume")
 
 		// 	resp, err := ma.Attach(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkVolumeAttachRequest{
 		// 			VolumeId: volID,
 		// 		},
@@ -762,10 +735,10 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 	By("Mounting a non-attached Volume")
 
 		// 	_, err = ma.Mount(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkVolumeMountRequest{
 		// 			VolumeId:  volID,
-		// 			MountPath: config.MountPath,
+		// 			MountPath: run.config.MountPath,
 		// 		},
 		// 	)
 		// 	Expect(err).To(HaveOccurred())
@@ -782,10 +755,10 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 	By("Mounting a non-existent Volume")
 
 		// 	_, err := ma.Mount(
-		// 		setContextWithToken(context.Background(), users["admin"]),
+		// 		setContextWithToken(context.Background(), run.users["admin"]),
 		// 		&api.SdkVolumeMountRequest{
 		// 			VolumeId:  "dummy-doesnt-exist",
-		// 			MountPath: config.MountPath,
+		// 			MountPath: run.config.MountPath,
 		// 		},
 		// 	)
 		// 	Expect(err).To(HaveOccurred())
@@ -799,10 +772,10 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			By("Mounting a Volume with empty id")
 
 			_, err := ma.Mount(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeMountRequest{
 					VolumeId:  "",
-					MountPath: config.MountPath,
+					MountPath: run.config.MountPath,
 				},
 			)
 			Expect(err).To(HaveOccurred())
@@ -824,10 +797,10 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		})
 
 		AfterEach(func() {
-			for _, volid := range []string{volID, clonedID} {
+			for _, volid := range []string{clonedID, volID} {
 				if len(volid) != 0 {
 					err := deleteVol(
-						setContextWithToken(context.Background(), users["admin"]),
+						setContextWithToken(context.Background(), run.users["admin"]),
 						c,
 						volid)
 					Expect(err).NotTo(HaveOccurred())
@@ -838,13 +811,13 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		It("Should clone the volume successfully", func() {
 			By("Creating the volume first")
 			req := &api.SdkVolumeCreateRequest{
-				Name: "volume-to-be-cloned",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:    uint64(5 * GIGABYTE),
 					HaLevel: 2,
 				},
 			}
-			createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(createResponse).NotTo(BeNil())
 			Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -853,9 +826,9 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			By("Cloning the volume")
 
 			cloneRespose, err := c.Clone(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeCloneRequest{
-					Name:     "cloned-vol",
+					Name:     genName("clone"),
 					ParentId: volID,
 				},
 			)
@@ -874,12 +847,12 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 	It("Should fail to clone if clone size is different", func() {
 		// 		By("Creating the volume first")
 		// 		req := &api.SdkVolumeCreateRequest{
-		// 			Name: "volume-to-be-cloned",
+		// 			Name: genName("vol"),
 		// 			Spec: &api.VolumeSpec{
 		// 				Size: uint64(5 * GIGABYTE),
 		// 			},
 		// 		}
-		// 		createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+		// 		createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 		// 		Expect(err).NotTo(HaveOccurred())
 		// 		Expect(createResponse).NotTo(BeNil())
 		// 		Expect(createResponse.VolumeId).NotTo(BeEmpty())
@@ -888,9 +861,9 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		// 		By("Cloning the volume")
 
 		// 		cloneRespose, err := c.Clone(
-		// 			setContextWithToken(context.Background(), users["admin"]),
+		// 			setContextWithToken(context.Background(), run.users["admin"]),
 		// 			&api.SdkVolumeCloneRequest{
-		// 				Name:     "cloned-vol",
+		// 				Name:     genName("clone"),
 		// 				ParentId: volID,
 		// 				Spec: &api.VolumeSpec{
 		// 					Size: uint64(10 * GIGABYTE),
@@ -919,7 +892,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		AfterEach(func() {
 			if volID != "" {
 				err := deleteVol(
-					setContextWithToken(context.Background(), users["admin"]),
+					setContextWithToken(context.Background(), run.users["admin"]),
 					c,
 					volID)
 				Expect(err).NotTo(HaveOccurred())
@@ -929,7 +902,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		It("Should retrieve stats of volume successfully", func() {
 			By("Creating the volume first")
 			req := &api.SdkVolumeCreateRequest{
-				Name: "mount-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					HaLevel:   3,
@@ -938,14 +911,14 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 					Format:    api.FSType_FS_TYPE_XFS,
 				},
 			}
-			createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(createResponse).NotTo(BeNil())
 			Expect(createResponse.VolumeId).NotTo(BeEmpty())
 			volID = createResponse.VolumeId
 
 			statsResp, err := c.Stats(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeStatsRequest{
 					VolumeId:      volID,
 					NotCumulative: true,
@@ -959,7 +932,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 		It("Should retrieve stats of volume successfully for  cumulative", func() {
 			By("Creating the volume first")
 			req := &api.SdkVolumeCreateRequest{
-				Name: "stats-vol",
+				Name: genName("vol"),
 				Spec: &api.VolumeSpec{
 					Size:      uint64(5 * GIGABYTE),
 					HaLevel:   3,
@@ -968,14 +941,14 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 					Format:    api.FSType_FS_TYPE_XFS,
 				},
 			}
-			createResponse, err := c.Create(setContextWithToken(context.Background(), users["admin"]), req)
+			createResponse, err := c.Create(setContextWithToken(context.Background(), run.users["admin"]), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(createResponse).NotTo(BeNil())
 			Expect(createResponse.VolumeId).NotTo(BeEmpty())
 			volID = createResponse.VolumeId
 
 			statsResp, err := c.Stats(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeStatsRequest{
 					VolumeId:      volID,
 					NotCumulative: false,
@@ -990,7 +963,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 			Skip("PWX-6056")
 
 			statsResp, err := c.Stats(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeStatsRequest{
 					VolumeId:      "volID-doesnt-exist",
 					NotCumulative: true,
@@ -1003,7 +976,7 @@ var _ = Describe("Volume [OpenStorageVolume]", func() {
 
 		It("Should fail to retrieve stats of empty volume", func() {
 			statsResp, err := c.Stats(
-				setContextWithToken(context.Background(), users["admin"]),
+				setContextWithToken(context.Background(), run.users["admin"]),
 				&api.SdkVolumeStatsRequest{
 					VolumeId:      volID,
 					NotCumulative: true,